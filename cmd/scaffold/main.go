@@ -0,0 +1,82 @@
+// Command scaffold generates the Go code of a UPnP service from its SCPD,
+// see package scaffold.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/scaffold"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func main() {
+	scpdPath := flag.String("scpd", "", "the SCPD of the service, a file or an HTTP URL")
+	serviceType := flag.String("type", "", "the service type, e.g. urn:schemas-upnp-org:service:RenderingControl:1")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "the package of the generated file")
+	name := flag.String("name", "", "the prefix of the generated identifiers, taken from the type when empty")
+	output := flag.String("o", "", "the generated file, <name>_scaffold.go when empty")
+	clientOnly := flag.Bool("client", false, "generate only the client")
+	flag.Parse()
+
+	if err := run(*scpdPath, *serviceType, *pkg, *name, *output, *clientOnly); err != nil {
+		fmt.Fprintln(os.Stderr, "scaffold:", err)
+		os.Exit(1)
+	}
+}
+
+func run(scpdPath, serviceType, pkg, name, output string, clientOnly bool) error {
+	if scpdPath == "" || serviceType == "" {
+		return fmt.Errorf("-scpd and -type are required")
+	}
+
+	scpd, source, err := readSCPD(scpdPath)
+	if err != nil {
+		return err
+	}
+
+	code, err := scaffold.Generate(scaffold.Config{
+		Package:     pkg,
+		Name:        name,
+		ServiceType: serviceType,
+		Source:      source,
+		ClientOnly:  clientOnly,
+	}, scpd)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = strings.ToLower(strings.TrimSuffix(source, path.Ext(source))) + "_scaffold.go"
+	}
+	return os.WriteFile(output, code, 0o644)
+}
+
+// readSCPD reads the SCPD from the file or URL and returns it with the name
+// of the file.
+func readSCPD(location string) (*soap.SCPD, string, error) {
+	if scpdURL, err := url.Parse(location); err == nil && (scpdURL.Scheme == "http" || scpdURL.Scheme == "https") {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		scpd, err := soap.FetchSCPD(ctx, nil, scpdURL)
+		return scpd, path.Base(scpdURL.Path), err
+	}
+
+	file, err := os.Open(location)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	scpd, err := soap.ParseSCPD(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing %s: %w", location, err)
+	}
+	return scpd, filepath.Base(location), nil
+}
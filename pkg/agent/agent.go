@@ -0,0 +1,159 @@
+// Package agent forwards the SSDP observations of agents on remote subnets
+// to a central registry, for an inventory spanning networks that multicast
+// is not routed between.
+//
+// Agents stream their observations over the gRPC service of agent.proto,
+// which the central instance registers on its gRPC server:
+//
+//	server := grpc.NewServer(grpc.Creds(tlsCredentials), grpc.MaxRecvMsgSize(agent.MaxObservationSize))
+//	agent.Register(server, registry, agent.WithAuthenticate(agent.ClientCertificate))
+//
+// and agents open with Dial on a connection to it:
+//
+//	forwarder, err := agent.Dial(ctx, conn)
+//	for notify := range monitor.Notifications() {
+//		forwarder.Notify(notify)
+//	}
+//
+// Streams are refused unless the central instance authenticates them with
+// WithAuthenticate, which also names the agent. The devices reported are
+// recorded with that name in RegistryEntry.Agent.
+package agent
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MaxObservationSize is the size in bytes of the largest observation
+// accepted, well above that of any SSDP message. Servers should also pass it
+// to grpc.MaxRecvMsgSize so larger ones are not even received.
+const MaxObservationSize = 16 << 10
+
+// ErrClosed is returned when forwarding to a closed Forwarder.
+var ErrClosed = errors.New("agent: forwarder closed")
+
+type serverOptions struct {
+	authenticate func(ctx context.Context) (string, error)
+}
+
+type ServerOption interface {
+	apply(*serverOptions)
+}
+
+type authenticateOption func(ctx context.Context) (string, error)
+
+func (a authenticateOption) apply(opts *serverOptions) {
+	opts.authenticate = a
+}
+
+// WithAuthenticate authenticates each stream before reading it, from the
+// peer and metadata of the context, returning the name of the agent. Streams
+// it returns an error or no name for are refused with Unauthenticated.
+func WithAuthenticate(authenticate func(ctx context.Context) (agent string, err error)) ServerOption {
+	return authenticateOption(authenticate)
+}
+
+// ClientCertificate authenticates agents by their TLS client certificate,
+// verified by the server, named by its common name.
+func ClientCertificate(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", errors.New("no peer")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return "", errors.New("no TLS connection")
+	}
+	var certificate *x509.Certificate
+	if chains := info.State.VerifiedChains; len(chains) > 0 && len(chains[0]) > 0 {
+		certificate = chains[0][0]
+	}
+	if certificate == nil {
+		return "", errors.New("no verified client certificate")
+	}
+	return certificate.Subject.CommonName, nil
+}
+
+// agentService is the handler type of the service, which grpc requires to be
+// an interface.
+type agentService interface {
+	forward(stream grpc.ServerStream) error
+}
+
+type server struct {
+	registry *ssdp.Registry
+	opts     serverOptions
+}
+
+// Register registers the service recording the observations streamed to it
+// in the registry on the gRPC server.
+func Register(registrar grpc.ServiceRegistrar, registry *ssdp.Registry, opts ...ServerOption) {
+	s := &server{registry: registry}
+	for _, o := range opts {
+		o.apply(&s.opts)
+	}
+
+	stream := forwardStream
+	stream.Handler = func(srv interface{}, stream grpc.ServerStream) error {
+		return srv.(agentService).forward(stream)
+	}
+	registrar.RegisterService(&grpc.ServiceDesc{
+		ServiceName: ServiceName,
+		HandlerType: (*agentService)(nil),
+		Streams:     []grpc.StreamDesc{stream},
+		Metadata:    "agent.proto",
+	}, s)
+}
+
+func (s *server) forward(stream grpc.ServerStream) error {
+	if s.opts.authenticate == nil {
+		return status.Error(codes.Unauthenticated, "agent: streams are refused without WithAuthenticate")
+	}
+	agent, err := s.opts.authenticate(stream.Context())
+	if err == nil && agent == "" {
+		err = errors.New("no agent name")
+	}
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "agent: %v", err)
+	}
+
+	var count uint64
+	for {
+		observation := newMessage(observationType)
+		err := stream.RecvMsg(observation.Message)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if proto.Size(observation.Message) > MaxObservationSize {
+			return status.Errorf(codes.InvalidArgument, "agent: observation larger than %d bytes", MaxObservationSize)
+		}
+
+		response, notify := observation.decode()
+		if response != nil {
+			s.registry.AddResponseFrom(agent, *response)
+		}
+		if notify != nil {
+			s.registry.AddNotifyFrom(agent, *notify)
+		}
+		count++
+	}
+
+	summary := newMessage(summaryType)
+	summary.Set(summary.field("observations"), protoreflect.ValueOfUint64(count))
+	return stream.SendMsg(summary.Message)
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"google.golang.org/grpc"
+)
+
+// A Forwarder streams the observations of an agent to the central instance.
+type Forwarder struct {
+	stream grpc.ClientStream
+
+	mu     sync.Mutex
+	closed bool
+	err    error
+}
+
+// Dial opens a stream to the service on the connection. The credentials of
+// the connection, or metadata of the context, authenticate the agent. The
+// stream ends when the forwarder is closed or the context is done.
+func Dial(ctx context.Context, conn grpc.ClientConnInterface) (*Forwarder, error) {
+	stream, err := conn.NewStream(ctx, &forwardStream, forwardMethod)
+	if err != nil {
+		return nil, err
+	}
+	return &Forwarder{stream: stream}, nil
+}
+
+// Response forwards a search response.
+func (f *Forwarder) Response(response ssdp.SearchResponse) error {
+	return f.send(encodeResponse(response))
+}
+
+// Notify forwards an announcement.
+func (f *Forwarder) Notify(notify ssdp.Notify) error {
+	return f.send(encodeNotify(notify))
+}
+
+func (f *Forwarder) send(observation message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+	if f.err != nil {
+		return f.err
+	}
+	if err := f.stream.SendMsg(observation.Message); err != nil {
+		f.err = err
+		if err == io.EOF {
+			// The central instance ended the stream, its status tells why
+			f.err = f.stream.RecvMsg(newMessage(summaryType).Message)
+			if f.err == nil {
+				f.err = io.ErrUnexpectedEOF
+			}
+		}
+		return f.err
+	}
+	return nil
+}
+
+// Close ends the stream and returns the error of the central instance, if
+// any.
+func (f *Forwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return f.err
+	}
+	f.closed = true
+	if f.err != nil {
+		return f.err
+	}
+
+	if err := f.stream.CloseSend(); err != nil {
+		f.err = err
+		return err
+	}
+	f.err = f.stream.RecvMsg(newMessage(summaryType).Message)
+	return f.err
+}
@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ServiceName is the name of the gRPC service of agent.proto.
+const ServiceName = "gossdp.agent.v1.Agent"
+
+// The kinds of agent.proto.
+const (
+	kindResponse = 0
+	kindNotify   = 1
+)
+
+// The messages of agent.proto, built at run time instead of generated.
+var observationType, summaryType protoreflect.MessageDescriptor
+
+func init() {
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   kind.Enum(),
+		}
+	}
+	const (
+		str     = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		integer = descriptorpb.FieldDescriptorProto_TYPE_INT32
+	)
+
+	kind := field("kind", 1, descriptorpb.FieldDescriptorProto_TYPE_ENUM)
+	kind.TypeName = proto.String(".gossdp.agent.v1.Observation.Kind")
+	received := field("received_unix_nano", 14, descriptorpb.FieldDescriptorProto_TYPE_INT64)
+	observations := field("observations", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64)
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("agent.proto"),
+		Package: proto.String("gossdp.agent.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Observation"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					kind,
+					field("target", 2, str),
+					field("nts", 3, str),
+					field("usn", 4, str),
+					field("location", 5, str),
+					field("server", 6, str),
+					field("cache_control", 7, str),
+					field("nls", 8, str),
+					field("addr", 9, str),
+					field("search_port", 10, integer),
+					field("boot_id", 11, integer),
+					field("next_boot_id", 12, integer),
+					field("config_id", 13, integer),
+					received,
+					field("mac", 15, str),
+					field("hostname", 16, str),
+				},
+				EnumType: []*descriptorpb.EnumDescriptorProto{{
+					Name: proto.String("Kind"),
+					Value: []*descriptorpb.EnumValueDescriptorProto{
+						{Name: proto.String("KIND_RESPONSE"), Number: proto.Int32(kindResponse)},
+						{Name: proto.String("KIND_NOTIFY"), Number: proto.Int32(kindNotify)},
+					},
+				}},
+			},
+			{
+				Name:  proto.String("Summary"),
+				Field: []*descriptorpb.FieldDescriptorProto{observations},
+			},
+		},
+	}
+
+	descriptor, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		panic(err)
+	}
+	observationType = descriptor.Messages().ByName("Observation")
+	summaryType = descriptor.Messages().ByName("Summary")
+}
+
+// forwardStream is the stream of the Forward method.
+var forwardStream = grpc.StreamDesc{
+	StreamName:    "Forward",
+	ClientStreams: true,
+}
+
+const forwardMethod = "/" + ServiceName + "/Forward"
+
+// message wraps a message of agent.proto to get and set its fields by name.
+type message struct {
+	*dynamicpb.Message
+}
+
+func newMessage(descriptor protoreflect.MessageDescriptor) message {
+	return message{dynamicpb.NewMessage(descriptor)}
+}
+
+func (m message) field(name string) protoreflect.FieldDescriptor {
+	return m.Descriptor().Fields().ByName(protoreflect.Name(name))
+}
+
+func (m message) setString(name string, value string) {
+	if value != "" {
+		m.Set(m.field(name), protoreflect.ValueOfString(value))
+	}
+}
+
+func (m message) setInt(name string, value int) {
+	if value != 0 {
+		m.Set(m.field(name), protoreflect.ValueOfInt32(int32(value)))
+	}
+}
+
+func (m message) string(name string) string {
+	return m.Get(m.field(name)).String()
+}
+
+func (m message) int(name string) int {
+	return int(m.Get(m.field(name)).Int())
+}
+
+// observation encodes what both kinds of observations have in common.
+func observation(kind protoreflect.EnumNumber, target string, usn string, location *url.URL, server string, control string, nls string, addr *net.UDPAddr, searchPort int, bootID int, configID int) message {
+	m := newMessage(observationType)
+	m.Set(m.field("kind"), protoreflect.ValueOfEnum(kind))
+	m.setString("target", target)
+	m.setString("usn", usn)
+	if location != nil {
+		m.setString("location", location.String())
+	}
+	m.setString("server", server)
+	m.setString("cache_control", control)
+	m.setString("nls", nls)
+	if addr != nil {
+		m.setString("addr", addr.String())
+	}
+	m.setInt("search_port", searchPort)
+	m.setInt("boot_id", bootID)
+	m.setInt("config_id", configID)
+	return m
+}
+
+func encodeResponse(response ssdp.SearchResponse) message {
+	m := observation(kindResponse, response.ST, response.USN, response.Location, response.Server, response.Control, response.NLS, response.ResponseAddr, response.SearchPort, response.BootID, response.ConfigID)
+	if !response.Received.IsZero() {
+		m.Set(m.field("received_unix_nano"), protoreflect.ValueOfInt64(response.Received.UnixNano()))
+	}
+	if response.MAC != nil {
+		m.setString("mac", response.MAC.String())
+	}
+	m.setString("hostname", response.Hostname)
+	return m
+}
+
+func encodeNotify(notify ssdp.Notify) message {
+	m := observation(kindNotify, notify.NT, notify.USN, notify.Location, notify.Server, notify.Control, notify.NLS, notify.Addr, notify.SearchPort, notify.BootID, notify.ConfigID)
+	m.setString("nts", notify.NTS)
+	m.setInt("next_boot_id", notify.NextBootID)
+	return m
+}
+
+// decode returns the search response or announcement of the observation.
+// Malformed locations, addresses and MACs are left out.
+func (m message) decode() (*ssdp.SearchResponse, *ssdp.Notify) {
+	location, err := url.Parse(m.string("location"))
+	if err != nil || m.string("location") == "" {
+		location = nil
+	}
+	// Addresses are numeric, a name would be looked up on the central host
+	var addr *net.UDPAddr
+	if addrPort, err := netip.ParseAddrPort(m.string("addr")); err == nil {
+		addr = net.UDPAddrFromAddrPort(addrPort)
+	}
+
+	if m.Get(m.field("kind")).Enum() == kindNotify {
+		return nil, &ssdp.Notify{
+			Control:    m.string("cache_control"),
+			Server:     m.string("server"),
+			NT:         m.string("target"),
+			NTS:        m.string("nts"),
+			USN:        m.string("usn"),
+			Location:   location,
+			NLS:        m.string("nls"),
+			Addr:       addr,
+			SearchPort: m.int("search_port"),
+			BootID:     m.int("boot_id"),
+			NextBootID: m.int("next_boot_id"),
+			ConfigID:   m.int("config_id"),
+		}
+	}
+
+	response := &ssdp.SearchResponse{
+		Control:      m.string("cache_control"),
+		Server:       m.string("server"),
+		ST:           m.string("target"),
+		USN:          m.string("usn"),
+		Location:     location,
+		NLS:          m.string("nls"),
+		ResponseAddr: addr,
+		SearchPort:   m.int("search_port"),
+		BootID:       m.int("boot_id"),
+		ConfigID:     m.int("config_id"),
+		Hostname:     m.string("hostname"),
+	}
+	if received := m.Get(m.field("received_unix_nano")).Int(); received != 0 {
+		response.Received = time.Unix(0, received)
+	}
+	if mac, err := net.ParseMAC(m.string("mac")); err == nil {
+		response.MAC = mac
+	}
+	return response, nil
+}
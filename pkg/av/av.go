@@ -0,0 +1,37 @@
+// Package av provides helpers for the UPnP AV services of media servers and
+// renderers, built on the soap package. The HTTP client of the constructors
+// is best the HTTPClient of the ssdp client that found the device, nil uses
+// http.DefaultClient without its transport, proxy and request decorator.
+package av
+
+import (
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net/http"
+	"strconv"
+)
+
+// newServiceClient returns a SOAP client for the first service of the device
+// with the given type prefix.
+func newServiceClient(device ssdp.Device, typePrefix string, httpClient *http.Client) (*soap.Client, error) {
+	service, ok := device.ServiceByType(typePrefix)
+	if !ok {
+		return nil, fmt.Errorf("device %s has no %s service", device.UDN, typePrefix)
+	}
+
+	controlURL, err := device.ResolveURL(service.ControlURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return soap.NewClient(controlURL, service.ServiceType, httpClient), nil
+}
+
+func parseUint(args map[string]string, name string) (uint32, error) {
+	value, err := strconv.ParseUint(args[name], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, args[name])
+	}
+	return uint32(value), nil
+}
@@ -0,0 +1,116 @@
+package av
+
+import (
+	"context"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net/http"
+	"strconv"
+)
+
+const AVTransportServiceType = "urn:schemas-upnp-org:service:AVTransport:"
+
+// The units of the Seek action.
+type SeekUnit string
+
+const (
+	SeekRelTime  SeekUnit = "REL_TIME"
+	SeekAbsTime  SeekUnit = "ABS_TIME"
+	SeekTrackNr  SeekUnit = "TRACK_NR"
+	SeekRelCount SeekUnit = "REL_COUNT"
+	SeekAbsCount SeekUnit = "ABS_COUNT"
+)
+
+// AVTransport is a client for the AVTransport service of a media renderer.
+type AVTransport struct {
+	client *soap.Client
+	// The virtual instance to control, 0 for most renderers
+	InstanceID uint32
+}
+
+// PositionInfo is the result of the GetPositionInfo action.
+type PositionInfo struct {
+	Track         uint32
+	TrackDuration string
+	TrackMetaData string
+	TrackURI      string
+	RelTime       string
+	AbsTime       string
+	RelCount      int32
+	AbsCount      int32
+}
+
+// NewAVTransport returns a client for the AVTransport service of the device.
+// The HTTP client may be nil.
+func NewAVTransport(device ssdp.Device, httpClient *http.Client) (*AVTransport, error) {
+	client, err := newServiceClient(device, AVTransportServiceType, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &AVTransport{client: client}, nil
+}
+
+func (a *AVTransport) invoke(ctx context.Context, action string, args ...soap.Arg) (map[string]string, error) {
+	instance := soap.Arg{Name: "InstanceID", Value: strconv.FormatUint(uint64(a.InstanceID), 10)}
+	return a.client.Invoke(ctx, action, append([]soap.Arg{instance}, args...))
+}
+
+// SetAVTransportURI sets the URI to play, with optional DIDL-Lite metadata
+// describing it.
+func (a *AVTransport) SetAVTransportURI(ctx context.Context, uri string, metadata string) error {
+	_, err := a.invoke(ctx, "SetAVTransportURI",
+		soap.Arg{Name: "CurrentURI", Value: uri},
+		soap.Arg{Name: "CurrentURIMetaData", Value: metadata})
+	return err
+}
+
+// Play starts playback at the given speed, usually "1".
+func (a *AVTransport) Play(ctx context.Context, speed string) error {
+	_, err := a.invoke(ctx, "Play", soap.Arg{Name: "Speed", Value: speed})
+	return err
+}
+
+func (a *AVTransport) Pause(ctx context.Context) error {
+	_, err := a.invoke(ctx, "Pause")
+	return err
+}
+
+func (a *AVTransport) Stop(ctx context.Context) error {
+	_, err := a.invoke(ctx, "Stop")
+	return err
+}
+
+// Seek moves to the target, e.g. "0:01:30" for SeekRelTime.
+func (a *AVTransport) Seek(ctx context.Context, unit SeekUnit, target string) error {
+	_, err := a.invoke(ctx, "Seek",
+		soap.Arg{Name: "Unit", Value: string(unit)},
+		soap.Arg{Name: "Target", Value: target})
+	return err
+}
+
+func (a *AVTransport) GetPositionInfo(ctx context.Context) (*PositionInfo, error) {
+	args, err := a.invoke(ctx, "GetPositionInfo")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &PositionInfo{
+		TrackDuration: args["TrackDuration"],
+		TrackMetaData: args["TrackMetaData"],
+		TrackURI:      args["TrackURI"],
+		RelTime:       args["RelTime"],
+		AbsTime:       args["AbsTime"],
+	}
+
+	if info.Track, err = parseUint(args, "Track"); err != nil {
+		return nil, err
+	}
+
+	// Counts are optional and often reported as 2147483647 when unknown
+	relCount, _ := strconv.ParseInt(args["RelCount"], 10, 32)
+	absCount, _ := strconv.ParseInt(args["AbsCount"], 10, 32)
+	info.RelCount = int32(relCount)
+	info.AbsCount = int32(absCount)
+
+	return info, nil
+}
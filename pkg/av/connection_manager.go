@@ -0,0 +1,143 @@
+package av
+
+import (
+	"context"
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const ConnectionManagerServiceType = "urn:schemas-upnp-org:service:ConnectionManager:"
+
+// ProtocolInfo describes a protocol and format a device can send or receive,
+// e.g. "http-get:*:audio/mpeg:DLNA.ORG_PN=MP3".
+type ProtocolInfo struct {
+	Protocol       string
+	Network        string
+	ContentFormat  string
+	AdditionalInfo string
+}
+
+func (p ProtocolInfo) String() string {
+	return strings.Join([]string{p.Protocol, p.Network, p.ContentFormat, p.AdditionalInfo}, ":")
+}
+
+// ParseProtocolInfo parses a single protocol info entry.
+func ParseProtocolInfo(value string) (ProtocolInfo, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 4)
+	if len(parts) != 4 {
+		return ProtocolInfo{}, fmt.Errorf("invalid protocol info %q", value)
+	}
+
+	return ProtocolInfo{
+		Protocol:       parts[0],
+		Network:        parts[1],
+		ContentFormat:  parts[2],
+		AdditionalInfo: parts[3],
+	}, nil
+}
+
+// ParseProtocolInfoList parses a comma separated list of protocol info
+// entries, skipping malformed entries.
+func ParseProtocolInfoList(value string) []ProtocolInfo {
+	list := make([]ProtocolInfo, 0)
+	for _, entry := range strings.Split(value, ",") {
+		if info, err := ParseProtocolInfo(entry); err == nil {
+			list = append(list, info)
+		}
+	}
+	return list
+}
+
+// Profile returns the DLNA.ORG_PN profile of the entry, if any.
+func (p ProtocolInfo) Profile() string {
+	for _, param := range strings.Split(p.AdditionalInfo, ";") {
+		if strings.HasPrefix(param, "DLNA.ORG_PN=") {
+			return strings.TrimPrefix(param, "DLNA.ORG_PN=")
+		}
+	}
+	return ""
+}
+
+// Accepts reports whether a sink entry accepts content of the MIME type and
+// optional DLNA profile streamed over HTTP.
+func (p ProtocolInfo) Accepts(mimeType string, profile string) bool {
+	if p.Protocol != "http-get" && p.Protocol != "*" {
+		return false
+	}
+
+	if !matchMIME(p.ContentFormat, mimeType) {
+		return false
+	}
+
+	if profile == "" || p.AdditionalInfo == "*" {
+		return true
+	}
+
+	sinkProfile := p.Profile()
+	return sinkProfile == "" || sinkProfile == profile
+}
+
+func matchMIME(pattern string, mimeType string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	patternType, _, err := mime.ParseMediaType(pattern)
+	if err != nil {
+		return false
+	}
+	contentType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+
+	if patternType == contentType {
+		return true
+	}
+
+	// Wildcard subtypes like audio/*
+	return strings.HasSuffix(patternType, "/*") &&
+		strings.HasPrefix(contentType, strings.TrimSuffix(patternType, "*"))
+}
+
+// CanPlay returns the first sink entry that accepts the MIME type and
+// optional DLNA profile.
+func CanPlay(sinks []ProtocolInfo, mimeType string, profile string) (ProtocolInfo, bool) {
+	for _, sink := range sinks {
+		if sink.Accepts(mimeType, profile) {
+			return sink, true
+		}
+	}
+	return ProtocolInfo{}, false
+}
+
+// ConnectionManager is a client for the ConnectionManager service of a media
+// server or renderer.
+type ConnectionManager struct {
+	client *soap.Client
+}
+
+// NewConnectionManager returns a client for the ConnectionManager service of
+// the device. The HTTP client may be nil.
+func NewConnectionManager(device ssdp.Device, httpClient *http.Client) (*ConnectionManager, error) {
+	client, err := newServiceClient(device, ConnectionManagerServiceType, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &ConnectionManager{client: client}, nil
+}
+
+// GetProtocolInfo returns the formats the device can send (source) and
+// receive (sink).
+func (c *ConnectionManager) GetProtocolInfo(ctx context.Context) (source []ProtocolInfo, sink []ProtocolInfo, err error) {
+	args, err := c.client.Invoke(ctx, "GetProtocolInfo", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ParseProtocolInfoList(args["Source"]), ParseProtocolInfoList(args["Sink"]), nil
+}
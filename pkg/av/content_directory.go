@@ -0,0 +1,102 @@
+package av
+
+import (
+	"context"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net/http"
+	"strconv"
+)
+
+const ContentDirectoryServiceType = "urn:schemas-upnp-org:service:ContentDirectory:"
+
+// The BrowseFlag values of the Browse action.
+type BrowseFlag string
+
+const (
+	BrowseMetadata       BrowseFlag = "BrowseMetadata"
+	BrowseDirectChildren BrowseFlag = "BrowseDirectChildren"
+)
+
+// The object id of the root container.
+const RootObjectID = "0"
+
+// ContentDirectory is a client for the ContentDirectory service of a media
+// server.
+type ContentDirectory struct {
+	client *soap.Client
+}
+
+// BrowseResult is the result of a Browse or Search action.
+type BrowseResult struct {
+	DIDL           *DIDLLite
+	NumberReturned uint32
+	TotalMatches   uint32
+	UpdateID       uint32
+}
+
+// NewContentDirectory returns a client for the ContentDirectory service of the
+// device. The HTTP client may be nil.
+func NewContentDirectory(device ssdp.Device, httpClient *http.Client) (*ContentDirectory, error) {
+	client, err := newServiceClient(device, ContentDirectoryServiceType, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &ContentDirectory{client: client}, nil
+}
+
+// Browse returns the metadata of the object or its direct children. A count
+// of zero requests all children.
+func (c *ContentDirectory) Browse(ctx context.Context, objectID string, flag BrowseFlag, filter string, start uint32, count uint32, sortCriteria string) (*BrowseResult, error) {
+	args, err := c.client.Invoke(ctx, "Browse", []soap.Arg{
+		{Name: "ObjectID", Value: objectID},
+		{Name: "BrowseFlag", Value: string(flag)},
+		{Name: "Filter", Value: filter},
+		{Name: "StartingIndex", Value: strconv.FormatUint(uint64(start), 10)},
+		{Name: "RequestedCount", Value: strconv.FormatUint(uint64(count), 10)},
+		{Name: "SortCriteria", Value: sortCriteria},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseBrowseResult(args)
+}
+
+// Search returns the objects in the container matching the search criteria.
+func (c *ContentDirectory) Search(ctx context.Context, containerID string, criteria string, filter string, start uint32, count uint32, sortCriteria string) (*BrowseResult, error) {
+	args, err := c.client.Invoke(ctx, "Search", []soap.Arg{
+		{Name: "ContainerID", Value: containerID},
+		{Name: "SearchCriteria", Value: criteria},
+		{Name: "Filter", Value: filter},
+		{Name: "StartingIndex", Value: strconv.FormatUint(uint64(start), 10)},
+		{Name: "RequestedCount", Value: strconv.FormatUint(uint64(count), 10)},
+		{Name: "SortCriteria", Value: sortCriteria},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseBrowseResult(args)
+}
+
+func parseBrowseResult(args map[string]string) (*BrowseResult, error) {
+	didl, err := ParseDIDL(args["Result"])
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BrowseResult{DIDL: didl}
+
+	if result.NumberReturned, err = parseUint(args, "NumberReturned"); err != nil {
+		return nil, err
+	}
+	if result.TotalMatches, err = parseUint(args, "TotalMatches"); err != nil {
+		return nil, err
+	}
+	if result.UpdateID, err = parseUint(args, "UpdateID"); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package av
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// DIDLLite is a DIDL-Lite document describing content directory objects.
+type DIDLLite struct {
+	Containers []Container `xml:"container"`
+	Items      []Item      `xml:"item"`
+}
+
+// Container is a content directory object holding other objects.
+type Container struct {
+	ID         string `xml:"id,attr"`
+	ParentID   string `xml:"parentID,attr"`
+	Restricted bool   `xml:"restricted,attr"`
+	ChildCount int    `xml:"childCount,attr"`
+	Title      string `xml:"http://purl.org/dc/elements/1.1/ title"`
+	Class      string `xml:"urn:schemas-upnp-org:metadata-1-0/upnp/ class"`
+}
+
+// Item is a content directory object representing a media item.
+type Item struct {
+	ID          string     `xml:"id,attr"`
+	ParentID    string     `xml:"parentID,attr"`
+	Restricted  bool       `xml:"restricted,attr"`
+	Title       string     `xml:"http://purl.org/dc/elements/1.1/ title"`
+	Creator     string     `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Date        string     `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Class       string     `xml:"urn:schemas-upnp-org:metadata-1-0/upnp/ class"`
+	Artist      string     `xml:"urn:schemas-upnp-org:metadata-1-0/upnp/ artist"`
+	Album       string     `xml:"urn:schemas-upnp-org:metadata-1-0/upnp/ album"`
+	Genre       string     `xml:"urn:schemas-upnp-org:metadata-1-0/upnp/ genre"`
+	AlbumArtURI string     `xml:"urn:schemas-upnp-org:metadata-1-0/upnp/ albumArtURI"`
+	Resources   []Resource `xml:"res"`
+}
+
+// Resource is a way to retrieve the content of an item.
+type Resource struct {
+	URL          string `xml:",chardata"`
+	ProtocolInfo string `xml:"protocolInfo,attr"`
+	Size         uint64 `xml:"size,attr"`
+	Duration     string `xml:"duration,attr"`
+	Bitrate      uint32 `xml:"bitrate,attr"`
+	Resolution   string `xml:"resolution,attr"`
+}
+
+// IsAudio reports whether the item is of an audio class.
+func (i Item) IsAudio() bool {
+	return strings.HasPrefix(i.Class, "object.item.audioItem")
+}
+
+// IsVideo reports whether the item is of a video class.
+func (i Item) IsVideo() bool {
+	return strings.HasPrefix(i.Class, "object.item.videoItem")
+}
+
+// IsImage reports whether the item is of an image class.
+func (i Item) IsImage() bool {
+	return strings.HasPrefix(i.Class, "object.item.imageItem")
+}
+
+// ParseDIDL parses a DIDL-Lite document. An empty document has no objects.
+func ParseDIDL(document string) (*DIDLLite, error) {
+	didl := &DIDLLite{}
+
+	if strings.TrimSpace(document) == "" {
+		return didl, nil
+	}
+
+	if err := xml.Unmarshal([]byte(document), didl); err != nil {
+		return nil, err
+	}
+
+	return didl, nil
+}
@@ -0,0 +1,82 @@
+package av
+
+import (
+	"encoding/xml"
+)
+
+// The channel of variables that apply to all channels.
+const ChannelMaster = "Master"
+
+// LastChange is the decoded LastChange state variable evented by the
+// AVTransport and RenderingControl services.
+type LastChange struct {
+	Instances []LastChangeInstance
+}
+
+// LastChangeInstance holds the changed state variables of one instance.
+type LastChangeInstance struct {
+	ID        uint32
+	Variables []StateVariable
+}
+
+// StateVariable is a changed state variable. Channel is only set for
+// RenderingControl variables like Volume.
+type StateVariable struct {
+	Name    string
+	Value   string
+	Channel string
+}
+
+// Variable returns the value of the named variable of the instance, for the
+// Master channel when the variable has channels.
+func (i LastChangeInstance) Variable(name string) (string, bool) {
+	for _, variable := range i.Variables {
+		if variable.Name == name && (variable.Channel == "" || variable.Channel == ChannelMaster) {
+			return variable.Value, true
+		}
+	}
+	return "", false
+}
+
+// Instance returns the changes of the instance with the given id.
+func (l LastChange) Instance(id uint32) (LastChangeInstance, bool) {
+	for _, instance := range l.Instances {
+		if instance.ID == id {
+			return instance, true
+		}
+	}
+	return LastChangeInstance{}, false
+}
+
+// ParseLastChange decodes the value of a LastChange state variable.
+func ParseLastChange(value string) (*LastChange, error) {
+	var event struct {
+		Instances []struct {
+			ID        uint32 `xml:"val,attr"`
+			Variables []struct {
+				XMLName xml.Name
+				Value   string `xml:"val,attr"`
+				Channel string `xml:"channel,attr"`
+			} `xml:",any"`
+		} `xml:"InstanceID"`
+	}
+
+	if err := xml.Unmarshal([]byte(value), &event); err != nil {
+		return nil, err
+	}
+
+	lastChange := &LastChange{}
+	for _, instance := range event.Instances {
+		changed := LastChangeInstance{ID: instance.ID}
+		for _, variable := range instance.Variables {
+			changed.Variables = append(changed.Variables, StateVariable{
+				Name:    variable.XMLName.Local,
+				Value:   variable.Value,
+				Channel: variable.Channel,
+			})
+		}
+		lastChange.Instances = append(lastChange.Instances, changed)
+	}
+
+	return lastChange, nil
+}
@@ -0,0 +1,85 @@
+package av
+
+import (
+	"context"
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net/http"
+	"strconv"
+)
+
+const RenderingControlServiceType = "urn:schemas-upnp-org:service:RenderingControl:"
+
+// RenderingControl is a client for the RenderingControl service of a media
+// renderer. Channels are e.g. ChannelMaster, "LF" or "RF".
+type RenderingControl struct {
+	client *soap.Client
+	// The virtual instance to control, 0 for most renderers
+	InstanceID uint32
+}
+
+// NewRenderingControl returns a client for the RenderingControl service of
+// the device. The HTTP client may be nil.
+func NewRenderingControl(device ssdp.Device, httpClient *http.Client) (*RenderingControl, error) {
+	client, err := newServiceClient(device, RenderingControlServiceType, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &RenderingControl{client: client}, nil
+}
+
+func (r *RenderingControl) invoke(ctx context.Context, action string, channel string, args ...soap.Arg) (map[string]string, error) {
+	return r.client.Invoke(ctx, action, append([]soap.Arg{
+		{Name: "InstanceID", Value: strconv.FormatUint(uint64(r.InstanceID), 10)},
+		{Name: "Channel", Value: channel},
+	}, args...))
+}
+
+func (r *RenderingControl) GetVolume(ctx context.Context, channel string) (uint16, error) {
+	args, err := r.invoke(ctx, "GetVolume", channel)
+	if err != nil {
+		return 0, err
+	}
+
+	volume, err := strconv.ParseUint(args["CurrentVolume"], 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CurrentVolume %q", args["CurrentVolume"])
+	}
+
+	return uint16(volume), nil
+}
+
+func (r *RenderingControl) SetVolume(ctx context.Context, channel string, volume uint16) error {
+	_, err := r.invoke(ctx, "SetVolume", channel, soap.Arg{Name: "DesiredVolume", Value: strconv.FormatUint(uint64(volume), 10)})
+	return err
+}
+
+func (r *RenderingControl) GetMute(ctx context.Context, channel string) (bool, error) {
+	args, err := r.invoke(ctx, "GetMute", channel)
+	if err != nil {
+		return false, err
+	}
+
+	return parseBool(args["CurrentMute"])
+}
+
+func (r *RenderingControl) SetMute(ctx context.Context, channel string, mute bool) error {
+	value := "0"
+	if mute {
+		value = "1"
+	}
+	_, err := r.invoke(ctx, "SetMute", channel, soap.Arg{Name: "DesiredMute", Value: value})
+	return err
+}
+
+// parseBool parses the boolean representations of UPnP.
+func parseBool(value string) (bool, error) {
+	switch value {
+	case "1", "true", "yes", "True", "TRUE":
+		return true, nil
+	case "0", "false", "no", "False", "FALSE":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
@@ -0,0 +1,305 @@
+// Package certify drives a device under test through the checks of UDA
+// certification in a single automated run: discovery and description with
+// ssdp.Lint, control by invoking actions with a SOAP client and eventing by
+// subscribing to its services. The outcome is a report of passed and failed
+// checks:
+//
+//	report, err := certify.Run(ctx, ssdp.NewSSDP(), certify.Config{UDN: "uuid:..."})
+//	if err != nil {
+//		return err
+//	}
+//	report.WriteTo(os.Stdout)
+package certify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Oleaintueri/gossdp/pkg/gena"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+)
+
+// The action invoked to check that unknown actions are refused.
+const invalidAction = "X_CertifyNoSuchAction"
+
+// Phase is the part of the UDA a check covers.
+type Phase string
+
+const (
+	PhaseDiscovery   Phase = "discovery"
+	PhaseDescription Phase = "description"
+	PhaseControl     Phase = "control"
+	PhaseEventing    Phase = "eventing"
+)
+
+// Config selects the device under test and how long to wait for it.
+type Config struct {
+	// The UDN of the device, e.g. "uuid:4d696e69-444c-164e-9d41-b827eb54e939"
+	UDN string
+	// How long to listen for announcements, see ssdp.LintConfig
+	Listen time.Duration
+	// How long to wait for the initial events, 5 seconds when zero
+	EventTimeout time.Duration
+	// The client for descriptions, actions and subscriptions, the
+	// HTTPClient of the ssdp client if nil
+	HTTPClient *http.Client
+	// The options of the subscriber receiving the events
+	Subscriber []gena.Option
+}
+
+// A Result is the outcome of a check. It failed when one of its findings is
+// an error.
+type Result struct {
+	Phase    Phase
+	Check    string
+	Findings []ssdp.Finding
+}
+
+// Passed reports whether the check found no errors.
+func (r Result) Passed() bool {
+	for _, finding := range r.Findings {
+		if finding.Severity == ssdp.SeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+// A Report lists the results of the checks in the order they ran.
+type Report struct {
+	UDN     string
+	Results []*Result
+}
+
+// Passed reports whether every check passed.
+func (r *Report) Passed() bool {
+	for _, result := range r.Results {
+		if !result.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo writes a line per check, PASS or FAIL, followed by its findings.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, result := range r.Results {
+		status := "PASS"
+		if !result.Passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%s %s: %s\n", status, result.Phase, result.Check)
+		for _, finding := range result.Findings {
+			fmt.Fprintf(&b, "\t%s\n", finding)
+		}
+	}
+	if r.Passed() {
+		fmt.Fprintf(&b, "%s passed\n", r.UDN)
+	} else {
+		fmt.Fprintf(&b, "%s failed\n", r.UDN)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (r *Result) add(severity ssdp.Severity, subject string, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, ssdp.Finding{Severity: severity, Header: subject, Problem: fmt.Sprintf(format, args...)})
+}
+
+// check starts a check, its findings are added as it runs.
+func (r *Report) check(phase Phase, check string) *Result {
+	result := &Result{Phase: phase, Check: check}
+	r.Results = append(r.Results, result)
+	return result
+}
+
+// Run runs the checks against the device. Later phases are skipped when the
+// device cannot be found or its description fetched. The error is only set
+// when the checks could not run at all.
+func Run(ctx context.Context, s *ssdp.SSDP, config Config) (*Report, error) {
+	if config.EventTimeout == 0 {
+		config.EventTimeout = 5 * time.Second
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = s.HTTPClient()
+	}
+
+	lint, err := s.Lint(ctx, ssdp.LintConfig{UDN: config.UDN, Listen: config.Listen})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{UDN: config.UDN}
+	report.Results = append(report.Results, &Result{Phase: PhaseDiscovery, Check: "search, announcements and description", Findings: lint.Findings})
+
+	check := report.check(PhaseDescription, "fetch")
+	devices, err := s.SearchDevicesContext(ctx, config.UDN)
+	if err != nil {
+		check.add(ssdp.SeverityError, "description", "fetching the description of %s: %v", config.UDN, err)
+		return report, nil
+	}
+	var device *ssdp.Device
+	for i := range devices {
+		if devices[i].UDN == config.UDN {
+			device = &devices[i]
+		}
+	}
+	if device == nil {
+		check.add(ssdp.SeverityError, "description", "no description of %s could be fetched", config.UDN)
+		return report, nil
+	}
+
+	scpds := make(map[string]*soap.SCPD)
+	for _, service := range device.AllServices() {
+		if scpd := report.checkService(ctx, config, device, service); scpd != nil {
+			scpds[service.ServiceID] = scpd
+		}
+	}
+
+	report.checkEventing(ctx, config, device, scpds)
+	return report, nil
+}
+
+// checkService fetches the SCPD of the service and checks its actions,
+// returning the SCPD when it could be fetched.
+func (r *Report) checkService(ctx context.Context, config Config, device *ssdp.Device, service ssdp.Service) *soap.SCPD {
+	check := r.check(PhaseDescription, "SCPD of "+service.ServiceID)
+	scpdURL, err := device.ResolveURL(service.SCPDURL)
+	if err != nil {
+		check.add(ssdp.SeverityError, "SCPDURL", "invalid URL %q: %v", service.SCPDURL, err)
+		return nil
+	}
+	scpd, err := soap.FetchSCPD(ctx, config.HTTPClient, scpdURL)
+	if err != nil {
+		check.add(ssdp.SeverityError, "SCPDURL", "%v", err)
+		return nil
+	}
+	for _, action := range scpd.Actions {
+		for _, argument := range action.Arguments {
+			if _, ok := scpd.StateVariable(argument.RelatedStateVariable); !ok {
+				check.add(ssdp.SeverityError, action.Name, "argument %s relates to the undeclared state variable %q", argument.Name, argument.RelatedStateVariable)
+			}
+		}
+	}
+
+	controlURL, err := device.ResolveURL(service.ControlURL)
+	if err != nil || service.ControlURL == "" {
+		check = r.check(PhaseControl, "actions of "+service.ServiceID)
+		check.add(ssdp.SeverityError, "controlURL", "invalid URL %q", service.ControlURL)
+		return scpd
+	}
+	client := soap.NewClient(controlURL, service.ServiceType, config.HTTPClient)
+
+	check = r.check(PhaseControl, "invalid action on "+service.ServiceID)
+	var upnpErr *soap.UPnPError
+	switch _, err := client.Invoke(ctx, invalidAction, nil); {
+	case err == nil:
+		check.add(ssdp.SeverityError, invalidAction, "an unknown action succeeded")
+	case !errors.As(err, &upnpErr):
+		check.add(ssdp.SeverityError, invalidAction, "no UPnP error: %v", err)
+	case upnpErr.Code != soap.ErrorInvalidAction:
+		check.add(ssdp.SeverityError, invalidAction, "error %d instead of %d", upnpErr.Code, soap.ErrorInvalidAction)
+	}
+
+	// Actions reading state without arguments are safe to invoke on any
+	// device
+	for _, action := range scpd.Actions {
+		if !strings.HasPrefix(action.Name, "Get") || len(action.In()) > 0 {
+			continue
+		}
+		check := r.check(PhaseControl, action.Name+" on "+service.ServiceID)
+		out, err := client.Invoke(ctx, action.Name, nil)
+		if err != nil {
+			check.add(ssdp.SeverityError, action.Name, "%v", err)
+			continue
+		}
+		for _, argument := range action.Out() {
+			if _, ok := out[argument.Name]; !ok {
+				check.add(ssdp.SeverityError, action.Name, "output argument %s missing", argument.Name)
+			}
+		}
+	}
+	return scpd
+}
+
+// checkEventing subscribes to the evented services and checks their initial
+// events carry every evented state variable.
+func (r *Report) checkEventing(ctx context.Context, config Config, device *ssdp.Device, scpds map[string]*soap.SCPD) {
+	evented := make(map[string][]string)
+	for _, service := range device.AllServices() {
+		scpd, ok := scpds[service.ServiceID]
+		if !ok || service.EventSubURL == "" {
+			continue
+		}
+		for _, variable := range scpd.StateVariables {
+			// sendEvents defaults to "yes"
+			if variable.SendEvents != "no" {
+				evented[service.ServiceID] = append(evented[service.ServiceID], variable.Name)
+			}
+		}
+	}
+	if len(evented) == 0 {
+		return
+	}
+
+	check := r.check(PhaseEventing, "subscription")
+	opts := config.Subscriber
+	if config.HTTPClient != nil {
+		opts = append([]gena.Option{gena.WithHTTPClient(config.HTTPClient)}, opts...)
+	}
+	subscriber, err := gena.NewSubscriber(opts...)
+	if err != nil {
+		check.add(ssdp.SeverityError, "SUBSCRIBE", "%v", err)
+		return
+	}
+	defer subscriber.Close(context.Background())
+
+	if err := subscriber.SubscribeDevice(ctx, *device); err != nil {
+		check.add(ssdp.SeverityError, "SUBSCRIBE", "%v", err)
+		return
+	}
+
+	initial := make(map[string]gena.Event)
+	timeout := time.After(config.EventTimeout)
+wait:
+	for len(initial) < len(evented) {
+		select {
+		case event := <-subscriber.Events():
+			if event.Err != nil {
+				check.add(ssdp.SeverityError, event.ServiceID, "subscription lost: %v", event.Err)
+			} else if event.Seq == 0 {
+				initial[event.ServiceID] = event
+			}
+		case <-timeout:
+			break wait
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	for _, service := range device.AllServices() {
+		variables, ok := evented[service.ServiceID]
+		if !ok {
+			continue
+		}
+		check := r.check(PhaseEventing, "initial event of "+service.ServiceID)
+		event, ok := initial[service.ServiceID]
+		if !ok {
+			check.add(ssdp.SeverityError, "NOTIFY", "no initial event within %v", config.EventTimeout)
+			continue
+		}
+		for _, name := range variables {
+			if _, ok := event.Variables[name]; !ok {
+				check.add(ssdp.SeverityError, "NOTIFY", "evented state variable %s missing", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package config loads SSDP discovery settings from the environment or from
+// YAML and TOML files.
+package config
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The environment variables read by FromEnv.
+const (
+	EnvInterface      = "SSDP_INTERFACE"
+	EnvPort           = "SSDP_PORT"
+	EnvMulticastGroup = "SSDP_MULTICAST_GROUP"
+	EnvTimeout        = "SSDP_TIMEOUT"
+	EnvSearchTargets  = "SSDP_SEARCH_TARGETS"
+)
+
+// Config holds the discovery settings. Zero values keep the defaults of the
+// SSDP client.
+type Config struct {
+	// The name of the network interface to search on
+	Interface string `yaml:"interface" toml:"interface"`
+	// The port for SSDP discovery
+	Port int `yaml:"port" toml:"port"`
+	// The multicast group searches are sent to
+	MulticastGroup string `yaml:"multicast_group" toml:"multicast_group"`
+	// How long to wait for responses, e.g. "2s"
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+	// The search targets to search for
+	SearchTargets []string `yaml:"search_targets" toml:"search_targets"`
+}
+
+// Duration is a time.Duration that is read from strings like "1500ms".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.UnmarshalText([]byte(value.Value))
+}
+
+// Options returns the SSDP options for the configuration.
+func (c Config) Options() []ssdp.OptionSSDP {
+	opts := make([]ssdp.OptionSSDP, 0, 4)
+
+	if c.Interface != "" {
+		opts = append(opts, ssdp.WithInterface(c.Interface))
+	}
+	if c.Port != 0 {
+		opts = append(opts, ssdp.WithPort(c.Port))
+	}
+	if c.MulticastGroup != "" {
+		opts = append(opts, ssdp.WithBroadcast(c.MulticastGroup))
+	}
+	if c.Timeout != 0 {
+		opts = append(opts, ssdp.WithTimeout(int(time.Duration(c.Timeout)/time.Millisecond)))
+	}
+
+	return opts
+}
+
+// FromEnv reads the configuration from the SSDP_* environment variables.
+// Search targets are separated by commas.
+func FromEnv() (Config, error) {
+	config := Config{
+		Interface:      os.Getenv(EnvInterface),
+		MulticastGroup: os.Getenv(EnvMulticastGroup),
+	}
+
+	if port := os.Getenv(EnvPort); port != "" {
+		value, err := strconv.Atoi(port)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", EnvPort, err)
+		}
+		config.Port = value
+	}
+
+	if timeout := os.Getenv(EnvTimeout); timeout != "" {
+		if err := config.Timeout.UnmarshalText([]byte(timeout)); err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", EnvTimeout, err)
+		}
+	}
+
+	if targets := os.Getenv(EnvSearchTargets); targets != "" {
+		for _, target := range strings.Split(targets, ",") {
+			if target = strings.TrimSpace(target); target != "" {
+				config.SearchTargets = append(config.SearchTargets, target)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// SitesConfig holds the settings of independent discovery sites, such as
+// the networks of several customers reached over VPN, keyed by site name.
+type SitesConfig struct {
+	Sites map[string]Config `yaml:"sites" toml:"sites"`
+}
+
+// FromFile reads the configuration from a YAML (.yaml, .yml) or TOML (.toml)
+// file.
+func FromFile(path string) (Config, error) {
+	config := Config{}
+	if err := readFile(path, &config); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// SitesFromFile reads the site configurations from a YAML or TOML file,
+// holding a Config for each site under "sites".
+func SitesFromFile(path string) (SitesConfig, error) {
+	config := SitesConfig{}
+	if err := readFile(path, &config); err != nil {
+		return SitesConfig{}, err
+	}
+	return config, nil
+}
+
+func readFile(path string, config interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, config)
+	case ".toml":
+		err = toml.Unmarshal(data, config)
+	default:
+		return fmt.Errorf("unsupported config file %s", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package fingerprint derives an identity for devices that survives a change
+// of UDN, such as after a factory reset, from what the device says about its
+// make and hardware:
+//
+//	print := fingerprint.FromEntry(entry)
+//	if print.Unique() {
+//		known[print.Hash()] = entry
+//	}
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"strings"
+)
+
+// A Fingerprint holds the normalized parts of a device identity.
+type Fingerprint struct {
+	// The product tokens of the SERVER header without their versions, which
+	// change with firmware updates, e.g. "linux upnp sonos"
+	Server       string
+	Manufacturer string
+	ModelName    string
+	ModelNumber  string
+	SerialNumber string
+	// The MAC address found in the UDN, empty when there is none
+	MAC string
+}
+
+// FromDevice returns the fingerprint of a described device and the SERVER
+// header it answered with.
+func FromDevice(device ssdp.Device, server string) Fingerprint {
+	f := Fingerprint{
+		Server:       serverProducts(server),
+		Manufacturer: normalize(device.Manufacturer),
+		ModelName:    normalize(device.ModelName),
+		ModelNumber:  normalize(device.ModelNumber),
+		SerialNumber: normalize(device.SerialNumber),
+	}
+	if mac, ok := MACFromUDN(device.UDN); ok {
+		f.MAC = mac.String()
+	}
+	return f
+}
+
+// FromEntry returns the fingerprint of a registry entry. Entries without a
+// description only have their SERVER header and UDN to go by.
+func FromEntry(entry ssdp.RegistryEntry) Fingerprint {
+	device := ssdp.Device{UDN: entry.UDN}
+	if entry.Device != nil {
+		device = *entry.Device
+	}
+	return FromDevice(device, entry.Server)
+}
+
+// Unique reports whether the fingerprint has a serial number or MAC address,
+// without which identical models share their fingerprint.
+func (f Fingerprint) Unique() bool {
+	return f.SerialNumber != "" || f.MAC != ""
+}
+
+// Hash returns the hex encoded SHA-256 of the fingerprint.
+func (f Fingerprint) Hash() string {
+	parts := []string{f.Server, f.Manufacturer, f.ModelName, f.ModelNumber, f.SerialNumber, f.MAC}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// MACFromUDN returns the node of a time based (version 1) UUID, which is
+// the MAC address of the device unless it had none to use.
+func MACFromUDN(udn string) (net.HardwareAddr, bool) {
+	uuid := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(udn), "uuid:"))
+	groups := strings.Split(uuid, "-")
+	if len(groups) != 5 || len(groups[2]) != 4 || len(groups[3]) != 4 || len(groups[4]) != 12 {
+		return nil, false
+	}
+	if groups[2][0] != '1' {
+		return nil, false
+	}
+	// Only the RFC 4122 variant has a version
+	if !strings.ContainsRune("89ab", rune(groups[3][0])) {
+		return nil, false
+	}
+
+	node, err := hex.DecodeString(groups[4])
+	if err != nil {
+		return nil, false
+	}
+	// The multicast bit marks a random node
+	if node[0]&1 != 0 {
+		return nil, false
+	}
+	return net.HardwareAddr(node), true
+}
+
+// serverProducts returns the product names of a SERVER header like
+// "Linux/5.10 UPnP/1.0 Sonos/70.3".
+func serverProducts(server string) string {
+	var products []string
+	for _, token := range strings.Fields(server) {
+		if i := strings.IndexByte(token, '/'); i >= 0 {
+			token = token[:i]
+		}
+		if token = normalize(token); token != "" {
+			products = append(products, token)
+		}
+	}
+	return strings.Join(products, " ")
+}
+
+func normalize(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), " "))
+}
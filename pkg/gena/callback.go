@@ -0,0 +1,92 @@
+package gena
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+)
+
+type callbackHostOption string
+
+func (c callbackHostOption) apply(opts *options) {
+	opts.callbackHost = string(c)
+}
+
+// WithCallbackHost sets the host advertised in the callback URL, for when the
+// subscriber is only reachable through an address it cannot see itself, such
+// as the host address of a container.
+func WithCallbackHost(host string) Option {
+	return callbackHostOption(host)
+}
+
+type callbackPortOption int
+
+func (c callbackPortOption) apply(opts *options) {
+	opts.callbackPort = int(c)
+}
+
+// WithCallbackPort sets the port advertised in the callback URL, for when the
+// listen port is mapped to a different one.
+func WithCallbackPort(port int) Option {
+	return callbackPortOption(port)
+}
+
+type callbackInterfaceOption string
+
+func (c callbackInterfaceOption) apply(opts *options) {
+	opts.callbackInterface = string(c)
+}
+
+// WithCallbackInterface advertises the IPv4 address of the named interface
+// in the callback URL.
+func WithCallbackInterface(name string) Option {
+	return callbackInterfaceOption(name)
+}
+
+// callbackURL returns the URL the device should send events for the path to.
+// Unless configured otherwise it uses the listen address, or when listening
+// on all addresses the local address that routes to the device.
+func (s *Subscriber) callbackURL(eventURL *url.URL, path string) (string, error) {
+	listenAddr := s.listener.Addr().(*net.TCPAddr)
+
+	port := listenAddr.Port
+	if s.callbackPort != 0 {
+		port = s.callbackPort
+	}
+
+	host, err := s.callbackIP(eventURL, listenAddr.IP)
+	if err != nil {
+		return "", err
+	}
+
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(port)) + path, nil
+}
+
+func (s *Subscriber) callbackIP(eventURL *url.URL, listenIP net.IP) (string, error) {
+	if s.callbackHost != "" {
+		return s.callbackHost, nil
+	}
+
+	if s.callbackInterface != "" {
+		ip, err := ssdp.InterfaceIP(s.callbackInterface)
+		if err != nil {
+			return "", err
+		}
+		return ip.String(), nil
+	}
+
+	if !listenIP.IsUnspecified() {
+		return listenIP.String(), nil
+	}
+
+	// Dialing UDP sends nothing, it only picks the route.
+	conn, err := net.Dial("udp", net.JoinHostPort(eventURL.Hostname(), "1900"))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
@@ -0,0 +1,621 @@
+// Package gena implements UPnP eventing (GENA). On the control point side a
+// Subscriber subscribes to device services and receives their state variable
+// changes, on the device side a Publisher sends them.
+package gena
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+)
+
+// The largest event body that is read.
+const maxEventSize = 1 << 20
+
+// How long an event waits for the response to its SUBSCRIBE, which carries
+// the SID it is checked against.
+const sidWait = 10 * time.Second
+
+// How long resubscribing to a rebooted device may take.
+const resubscribeTimeout = 30 * time.Second
+
+var ErrClosed = errors.New("gena: subscriber closed")
+
+// Event is a state variable change of a subscribed service. When a
+// subscription is lost Err is set and Variables is empty.
+type Event struct {
+	UDN       string
+	ServiceID string
+	SID       string
+	Seq       uint32
+	Variables map[string]string
+	Err       error
+}
+
+type options struct {
+	httpClient *http.Client
+	listenAddr string
+	timeout    time.Duration
+	buffer     int
+	store      Store
+	bus        *ssdp.EventBus
+
+	callbackHost      string
+	callbackPort      int
+	callbackInterface string
+}
+
+type Option interface {
+	apply(*options)
+}
+
+type httpClientOption struct {
+	client *http.Client
+}
+
+func (h httpClientOption) apply(opts *options) {
+	opts.httpClient = h.client
+}
+
+// WithHTTPClient sets the client for SUBSCRIBE requests, e.g. the HTTPClient
+// of the ssdp client with its transport, proxy and request decorator. By
+// default http.DefaultClient sends them, bypassing those.
+func WithHTTPClient(client *http.Client) Option {
+	return httpClientOption{client}
+}
+
+type listenAddrOption string
+
+func (l listenAddrOption) apply(opts *options) {
+	opts.listenAddr = string(l)
+}
+
+// WithListenAddr sets the address the callback server listens on, ":0" by
+// default.
+func WithListenAddr(addr string) Option {
+	return listenAddrOption(addr)
+}
+
+type timeoutOption time.Duration
+
+func (t timeoutOption) apply(opts *options) {
+	opts.timeout = time.Duration(t)
+}
+
+// WithTimeout sets the subscription duration requested from devices.
+// Subscriptions are renewed halfway through the duration granted.
+func WithTimeout(timeout time.Duration) Option {
+	return timeoutOption(timeout)
+}
+
+type bufferOption int
+
+func (b bufferOption) apply(opts *options) {
+	opts.buffer = int(b)
+}
+
+// WithEventBuffer sets the number of events buffered for a slow consumer.
+// Devices are kept waiting for a reply while the buffer is full.
+func WithEventBuffer(size int) Option {
+	return bufferOption(size)
+}
+
+type eventBusOption struct {
+	bus *ssdp.EventBus
+}
+
+func (e eventBusOption) apply(opts *options) {
+	opts.bus = e.bus
+}
+
+// WithEventBus publishes the renewals and failures of subscriptions to the
+// bus, see ssdp.EventBus.
+func WithEventBus(bus *ssdp.EventBus) Option {
+	return eventBusOption{bus}
+}
+
+type subscription struct {
+	udn       string
+	serviceID string
+	eventURL  *url.URL
+	path      string
+	sid       string
+	expires   time.Time
+	timer     *time.Timer
+	// closed once the SUBSCRIBE was answered, nil when it has been before
+	ready chan struct{}
+}
+
+// Subscriber manages event subscriptions and the HTTP server that receives
+// the events.
+type Subscriber struct {
+	*options
+
+	listener net.Listener
+	server   *http.Server
+	events   chan Event
+
+	mu            sync.Mutex
+	subscriptions map[string]*subscription
+	nextPath      int
+	closed        bool
+	closing       chan struct{}
+	// the events being delivered, which Close waits for
+	delivering sync.WaitGroup
+}
+
+// NewSubscriber starts the callback server.
+func NewSubscriber(opts ...Option) (*Subscriber, error) {
+	options := &options{
+		httpClient: http.DefaultClient,
+		listenAddr: ":0",
+		timeout:    30 * time.Minute,
+		buffer:     64,
+	}
+
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	listener, err := net.Listen("tcp", options.listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriber := &Subscriber{
+		options:       options,
+		listener:      listener,
+		events:        make(chan Event, options.buffer),
+		subscriptions: make(map[string]*subscription),
+		closing:       make(chan struct{}),
+	}
+
+	var records []Record
+	if options.store != nil {
+		if records, err = options.store.Load(); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("loading subscriptions: %w", err)
+		}
+	}
+
+	subscriber.server = &http.Server{Handler: http.HandlerFunc(subscriber.handleNotify)}
+	go subscriber.server.Serve(listener)
+
+	subscriber.resume(records)
+
+	return subscriber, nil
+}
+
+// Events returns the channel events are delivered on. It is closed by Close.
+func (s *Subscriber) Events() <-chan Event {
+	return s.events
+}
+
+// SubscribeDevice subscribes to the services of the device and its embedded
+// devices whose type starts with one of the given prefixes, or to all
+// services when none are given.
+func (s *Subscriber) SubscribeDevice(ctx context.Context, device ssdp.Device, serviceTypes ...string) error {
+	subscribed := 0
+
+	for _, service := range device.AllServices() {
+		if !matchesType(service.ServiceType, serviceTypes) || service.EventSubURL == "" {
+			continue
+		}
+
+		eventURL, err := device.ResolveURL(service.EventSubURL)
+		if err != nil {
+			return err
+		}
+
+		if err := s.subscribe(ctx, device.UDN, service.ServiceID, eventURL); err != nil {
+			return fmt.Errorf("subscribing to %s of %s: %w", service.ServiceID, device.UDN, err)
+		}
+		subscribed++
+	}
+
+	if subscribed == 0 {
+		return fmt.Errorf("device %s has no matching evented services", device.UDN)
+	}
+
+	return nil
+}
+
+// Resubscribe replaces the subscriptions to the services of the device with
+// new ones, for when the device rebooted and forgot them, see
+// ssdp.EventDeviceRebooted and ResubscribeOnReboot. Every service is tried,
+// the error lists those that failed.
+func (s *Subscriber) Resubscribe(ctx context.Context, udn string) error {
+	s.mu.Lock()
+	var stale []*subscription
+	for path, sub := range s.subscriptions {
+		if sub.udn != udn {
+			continue
+		}
+		if sub.timer != nil {
+			sub.timer.Stop()
+		}
+		delete(s.subscriptions, path)
+		stale = append(stale, sub)
+	}
+	s.persist()
+	s.mu.Unlock()
+
+	var errs joinedError
+	for _, sub := range stale {
+		if err := s.subscribe(ctx, sub.udn, sub.serviceID, sub.eventURL); err != nil {
+			errs = append(errs, fmt.Errorf("subscribing to %s of %s: %w", sub.serviceID, sub.udn, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ResubscribeOnReboot returns the registry option resubscribing to the
+// services of the devices the registry sees reboot. Failures are delivered
+// as events with Err set.
+func (s *Subscriber) ResubscribeOnReboot() ssdp.OptionRegistry {
+	return ssdp.WithRebootHandler(func(udn string) {
+		ctx, cancel := context.WithTimeout(context.Background(), resubscribeTimeout)
+		defer cancel()
+
+		if err := s.Resubscribe(ctx, udn); err != nil && !errors.Is(err, ErrClosed) {
+			s.deliver(Event{UDN: udn, Err: err})
+		}
+	})
+}
+
+// joinedError is the errors of several subscriptions.
+type joinedError []error
+
+func (e joinedError) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is and As match any of the errors, in place of the Unwrap() []error that
+// errors only supports from Go 1.20.
+func (e joinedError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e joinedError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(serviceType string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(serviceType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Subscriber) subscribe(ctx context.Context, udn string, serviceID string, eventURL *url.URL) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	s.nextPath++
+	sub := &subscription{
+		udn:       udn,
+		serviceID: serviceID,
+		eventURL:  eventURL,
+		path:      fmt.Sprintf("/events/%d", s.nextPath),
+		ready:     make(chan struct{}),
+	}
+	// Devices send the initial event right away, possibly before the
+	// response has been read, so the subscription is known by its path first
+	// and its events wait for the SID.
+	s.subscriptions[sub.path] = sub
+	s.mu.Unlock()
+
+	sid, timeout, err := s.requestSubscription(ctx, sub)
+
+	s.mu.Lock()
+	close(sub.ready)
+	if err != nil {
+		delete(s.subscriptions, sub.path)
+		s.persist()
+		s.mu.Unlock()
+		return err
+	}
+
+	sub.sid = sid
+	if s.closed {
+		// Close did not see the SID, so nobody else ends the subscription
+		s.mu.Unlock()
+		s.unsubscribe(ctx, sub)
+		return ErrClosed
+	}
+	s.scheduleRenewal(sub, timeout)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// requestSubscription sends the SUBSCRIBE request of a new subscription and
+// returns the SID and granted timeout.
+func (s *Subscriber) requestSubscription(ctx context.Context, sub *subscription) (string, time.Duration, error) {
+	callback, err := s.callbackURL(sub.eventURL, sub.path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "SUBSCRIBE", sub.eventURL.String(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	request.Header.Set("CALLBACK", "<"+callback+">")
+	request.Header.Set("NT", "upnp:event")
+	request.Header.Set("TIMEOUT", formatTimeout(s.timeout))
+
+	return s.send(request)
+}
+
+// send sends a SUBSCRIBE request and returns the SID and granted timeout.
+func (s *Subscriber) send(request *http.Request) (string, time.Duration, error) {
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return "", 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("unexpected status %q", response.Status)
+	}
+
+	sid := response.Header.Get("SID")
+	if sid == "" {
+		return "", 0, fmt.Errorf("missing SID")
+	}
+
+	return sid, parseTimeout(response.Header.Get("TIMEOUT"), s.timeout), nil
+}
+
+// scheduleRenewal renews the subscription halfway through its timeout. The
+// caller must hold the lock.
+func (s *Subscriber) scheduleRenewal(sub *subscription, timeout time.Duration) {
+	sub.expires = time.Now().Add(timeout)
+	sub.timer = time.AfterFunc(timeout/2, func() {
+		s.renew(sub)
+	})
+	s.persist()
+}
+
+func (s *Subscriber) renew(sub *subscription) {
+	s.mu.Lock()
+	if s.closed || s.subscriptions[sub.path] != sub {
+		s.mu.Unlock()
+		return
+	}
+	sid := sub.sid
+	s.mu.Unlock()
+
+	request, err := http.NewRequest("SUBSCRIBE", sub.eventURL.String(), nil)
+	if err != nil {
+		return
+	}
+	request.Header.Set("SID", sid)
+	request.Header.Set("TIMEOUT", formatTimeout(s.timeout))
+
+	_, timeout, err := s.send(request)
+	if err == nil {
+		s.mu.Lock()
+		if !s.closed {
+			s.scheduleRenewal(sub, timeout)
+		}
+		s.mu.Unlock()
+		s.bus.Publish(ssdp.Event{Type: ssdp.EventSubscriptionRenewed, UDN: sub.udn, ServiceID: sub.serviceID, SID: sid})
+		return
+	}
+
+	// The device may have forgotten the subscription, e.g. after a reboot,
+	// so subscribe again from scratch.
+	s.mu.Lock()
+	delete(s.subscriptions, sub.path)
+	s.persist()
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.subscribe(ctx, sub.udn, sub.serviceID, sub.eventURL); err != nil && !errors.Is(err, ErrClosed) {
+		s.bus.Publish(ssdp.Event{Type: ssdp.EventSubscriptionFailed, UDN: sub.udn, ServiceID: sub.serviceID, SID: sid, Err: err})
+		s.deliver(Event{UDN: sub.udn, ServiceID: sub.serviceID, SID: sid, Err: err})
+	}
+}
+
+func (s *Subscriber) handleNotify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "NOTIFY" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	sid := r.Header.Get("SID")
+
+	s.mu.Lock()
+	sub, ok := s.subscriptions[r.URL.Path]
+	var ready chan struct{}
+	if ok {
+		ready = sub.ready
+	}
+	s.mu.Unlock()
+
+	if ready != nil {
+		timeout := time.NewTimer(sidWait)
+		defer timeout.Stop()
+		select {
+		case <-ready:
+		case <-timeout.C:
+		case <-r.Context().Done():
+		case <-s.closing:
+		}
+	}
+
+	s.mu.Lock()
+	ok = ok && s.subscriptions[r.URL.Path] == sub && sub.sid != "" && sub.sid == sid
+	s.mu.Unlock()
+
+	if !ok || r.Header.Get("NT") != "upnp:event" {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	seq, _ := strconv.ParseUint(r.Header.Get("SEQ"), 10, 32)
+
+	variables, err := parsePropertySet(io.LimitReader(r.Body, maxEventSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.deliver(Event{
+		UDN:       sub.udn,
+		ServiceID: sub.serviceID,
+		SID:       sid,
+		Seq:       uint32(seq),
+		Variables: variables,
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// deliver sends the event on the channel unless the subscriber is closed.
+func (s *Subscriber) deliver(event Event) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.delivering.Add(1)
+	s.mu.Unlock()
+	defer s.delivering.Done()
+
+	select {
+	case s.events <- event:
+	case <-s.closing:
+	}
+}
+
+// Close unsubscribes from all services, stops the callback server and closes
+// the events channel. With a store the subscriptions are left in place on
+// the devices instead, to be resumed by the next subscriber.
+func (s *Subscriber) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.closing)
+
+	subscriptions := make([]*subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		if sub.timer != nil {
+			sub.timer.Stop()
+		}
+		if sub.sid != "" {
+			subscriptions = append(subscriptions, sub)
+		}
+	}
+	s.subscriptions = map[string]*subscription{}
+	s.mu.Unlock()
+
+	if s.store != nil {
+		subscriptions = nil
+	}
+
+	for _, sub := range subscriptions {
+		s.unsubscribe(ctx, sub)
+	}
+
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		s.server.Close()
+	}
+	// Handlers still running deliver nothing once closed
+	s.delivering.Wait()
+	close(s.events)
+	return err
+}
+
+// unsubscribe sends the UNSUBSCRIBE request of the subscription, ignoring
+// failures as the subscription expires anyway.
+func (s *Subscriber) unsubscribe(ctx context.Context, sub *subscription) {
+	request, err := http.NewRequestWithContext(ctx, "UNSUBSCRIBE", sub.eventURL.String(), nil)
+	if err != nil {
+		return
+	}
+	request.Header.Set("SID", sub.sid)
+	if response, err := s.httpClient.Do(request); err == nil {
+		response.Body.Close()
+	}
+}
+
+func parsePropertySet(body io.Reader) (map[string]string, error) {
+	var propertySet struct {
+		Properties []struct {
+			Variables []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"property"`
+	}
+
+	if err := xml.NewDecoder(body).Decode(&propertySet); err != nil {
+		return nil, err
+	}
+
+	variables := make(map[string]string)
+	for _, property := range propertySet.Properties {
+		for _, variable := range property.Variables {
+			variables[variable.XMLName.Local] = variable.Value
+		}
+	}
+
+	return variables, nil
+}
+
+func formatTimeout(timeout time.Duration) string {
+	return "Second-" + strconv.Itoa(int(timeout/time.Second))
+}
+
+// parseTimeout parses a TIMEOUT header like "Second-1800", falling back to the
+// requested timeout.
+func parseTimeout(value string, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(value), "Second-"))
+	if err != nil || seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,438 @@
+package gena
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The number of events queued for a subscriber that is slow to accept them,
+// further events are dropped and show up as a gap in its sequence numbers.
+const publisherQueue = 16
+
+// How long a control point has to accept an event, as callback URLs that
+// never answer would hold up the subscriber's other events and Close.
+const notifyTimeout = 30 * time.Second
+
+var ErrUnknownVariable = errors.New("gena: unknown state variable")
+
+type publisherOptions struct {
+	httpClient *http.Client
+	moderation time.Duration
+	maxTimeout time.Duration
+}
+
+type PublisherOption interface {
+	apply(*publisherOptions)
+}
+
+type notifyClientOption struct {
+	client *http.Client
+}
+
+func (n notifyClientOption) apply(opts *publisherOptions) {
+	opts.httpClient = n.client
+}
+
+// WithNotifyClient sets the client for NOTIFY requests.
+func WithNotifyClient(client *http.Client) PublisherOption {
+	return notifyClientOption{client}
+}
+
+type moderationOption time.Duration
+
+func (m moderationOption) apply(opts *publisherOptions) {
+	opts.moderation = time.Duration(m)
+}
+
+// WithModeration sets how long changes are collected before they are sent
+// in a single event, 200 milliseconds by default.
+func WithModeration(interval time.Duration) PublisherOption {
+	return moderationOption(interval)
+}
+
+type maxTimeoutOption time.Duration
+
+func (m maxTimeoutOption) apply(opts *publisherOptions) {
+	opts.maxTimeout = time.Duration(m)
+}
+
+// WithMaxTimeout caps the subscription duration granted to control points,
+// 30 minutes by default.
+func WithMaxTimeout(timeout time.Duration) PublisherOption {
+	return maxTimeoutOption(timeout)
+}
+
+// Publisher is the device side of eventing for a service. Served at the
+// event URL of the service it accepts subscriptions, sends the evented
+// state variables to each new subscriber and notifies the subscribers of
+// the variables changed with Set, in batches numbered by SEQ.
+type Publisher struct {
+	*publisherOptions
+
+	mu          sync.Mutex
+	variables   map[string]string
+	pending     map[string]bool
+	flush       *time.Timer
+	subscribers map[string]*subscriber
+	closed      bool
+	workers     sync.WaitGroup
+	// cancels the events being sent on Close
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type subscriber struct {
+	sid       string
+	callbacks []*url.URL
+	seq       uint32
+	expiry    *time.Timer
+	queue     chan notification
+	// whether the initial event was queued, which comes first
+	started bool
+}
+
+type notification struct {
+	seq  uint32
+	body []byte
+}
+
+// NewPublisher returns a publisher for the evented state variables with
+// their initial values.
+func NewPublisher(variables map[string]string, opts ...PublisherOption) *Publisher {
+	options := &publisherOptions{
+		httpClient: http.DefaultClient,
+		moderation: 200 * time.Millisecond,
+		maxTimeout: 30 * time.Minute,
+	}
+
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Publisher{
+		publisherOptions: options,
+		ctx:              ctx,
+		cancel:           cancel,
+		variables:        make(map[string]string, len(variables)),
+		pending:          make(map[string]bool),
+		subscribers:      make(map[string]*subscriber),
+	}
+	for name, value := range variables {
+		p.variables[name] = value
+	}
+
+	return p
+}
+
+// Set changes the state variable. The subscribers are notified once the
+// moderation interval passed, along with the other changes made meanwhile.
+func (p *Publisher) Set(name string, value string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current, ok := p.variables[name]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownVariable, name)
+	}
+	if current == value || p.closed {
+		return nil
+	}
+
+	p.variables[name] = value
+	p.pending[name] = true
+	if p.flush == nil {
+		p.flush = time.AfterFunc(p.moderation, p.notifyPending)
+	}
+	return nil
+}
+
+// Get returns the value of the state variable.
+func (p *Publisher) Get(name string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	value, ok := p.variables[name]
+	return value, ok
+}
+
+// Subscribers returns the number of current subscriptions.
+func (p *Publisher) Subscribers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.subscribers)
+}
+
+// notifyPending sends the variables changed since the last event.
+func (p *Publisher) notifyPending() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.flush = nil
+	if p.closed || len(p.pending) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(p.pending))
+	for name := range p.pending {
+		names = append(names, name)
+	}
+	p.pending = make(map[string]bool)
+
+	body := p.propertySet(names)
+	for _, sub := range p.subscribers {
+		// The others get the changes with their initial event
+		if sub.started {
+			p.enqueue(sub, body)
+		}
+	}
+}
+
+// enqueue numbers the event and queues it for the subscriber. The caller
+// must hold the lock.
+func (p *Publisher) enqueue(sub *subscriber, body []byte) {
+	select {
+	case sub.queue <- notification{seq: sub.seq, body: body}:
+	default:
+	}
+	// SEQ wraps to 1, as 0 is reserved for the initial event
+	sub.seq++
+	if sub.seq == 0 {
+		sub.seq = 1
+	}
+}
+
+// propertySet returns the event body with the named variables. The caller
+// must hold the lock.
+func (p *Publisher) propertySet(names []string) []byte {
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0"?>` + "\n")
+	b.WriteString(`<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">` + "\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "<e:property><%s>", name)
+		xml.EscapeText(&b, []byte(p.variables[name]))
+		fmt.Fprintf(&b, "</%s></e:property>\n", name)
+	}
+	b.WriteString("</e:propertyset>\n")
+	return b.Bytes()
+}
+
+// ServeHTTP handles the SUBSCRIBE and UNSUBSCRIBE requests of control points.
+func (p *Publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sid := r.Header.Get("SID")
+	isNew := r.Header.Get("CALLBACK") != "" || r.Header.Get("NT") != ""
+
+	switch {
+	case r.Method == "SUBSCRIBE" && sid != "" && isNew,
+		r.Method == "UNSUBSCRIBE" && isNew:
+		w.WriteHeader(http.StatusBadRequest)
+	case r.Method == "SUBSCRIBE" && sid == "":
+		p.subscribe(w, r)
+	case r.Method == "SUBSCRIBE":
+		p.renew(w, r, sid)
+	case r.Method == "UNSUBSCRIBE":
+		if !p.unsubscribe(sid) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (p *Publisher) subscribe(w http.ResponseWriter, r *http.Request) {
+	callbacks := parseCallbacks(r.Header.Get("CALLBACK"))
+	if r.Header.Get("NT") != "upnp:event" || len(callbacks) == 0 {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	sid, err := newSID()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	timeout := p.grantedTimeout(r.Header.Get("TIMEOUT"))
+
+	sub := &subscriber{
+		sid:       sid,
+		callbacks: callbacks,
+		queue:     make(chan notification, publisherQueue),
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	sub.expiry = time.AfterFunc(timeout, func() { p.unsubscribe(sid) })
+	p.subscribers[sid] = sub
+	p.workers.Add(1)
+	go p.send(sub)
+	p.mu.Unlock()
+
+	w.Header().Set("SID", sid)
+	w.Header().Set("TIMEOUT", formatTimeout(timeout))
+	w.WriteHeader(http.StatusOK)
+	// The control point has to know the SID before the initial event arrives
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.subscribers[sid] != sub {
+		return
+	}
+	names := make([]string, 0, len(p.variables))
+	for name := range p.variables {
+		names = append(names, name)
+	}
+	// The initial event carries every variable with SEQ 0
+	p.enqueue(sub, p.propertySet(names))
+	sub.started = true
+}
+
+func (p *Publisher) renew(w http.ResponseWriter, r *http.Request, sid string) {
+	timeout := p.grantedTimeout(r.Header.Get("TIMEOUT"))
+
+	p.mu.Lock()
+	sub, ok := p.subscribers[sid]
+	if ok {
+		sub.expiry.Reset(timeout)
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	w.Header().Set("SID", sid)
+	w.Header().Set("TIMEOUT", formatTimeout(timeout))
+	w.WriteHeader(http.StatusOK)
+}
+
+// unsubscribe ends the subscription and reports whether it existed.
+func (p *Publisher) unsubscribe(sid string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sub, ok := p.subscribers[sid]
+	if !ok {
+		return false
+	}
+	delete(p.subscribers, sid)
+	sub.expiry.Stop()
+	close(sub.queue)
+	return true
+}
+
+// grantedTimeout returns the requested subscription duration within the
+// maximum, the maximum when none or infinite was requested.
+func (p *Publisher) grantedTimeout(requested string) time.Duration {
+	timeout := parseTimeout(requested, p.maxTimeout)
+	if timeout > p.maxTimeout {
+		return p.maxTimeout
+	}
+	return timeout
+}
+
+// send delivers the queued events of the subscriber, trying its callbacks in
+// order until one accepts.
+func (p *Publisher) send(sub *subscriber) {
+	defer p.workers.Done()
+
+	for event := range sub.queue {
+		for _, callback := range sub.callbacks {
+			if p.notify(sub, callback, event) == nil {
+				break
+			}
+		}
+	}
+}
+
+// notify sends the event to the callback within notifyTimeout.
+func (p *Publisher) notify(sub *subscriber, callback *url.URL, event notification) error {
+	ctx, cancel := context.WithTimeout(p.ctx, notifyTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, "NOTIFY", callback.String(), bytes.NewReader(event.body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	request.Header.Set("NT", "upnp:event")
+	request.Header.Set("NTS", "upnp:propchange")
+	request.Header.Set("SID", sub.sid)
+	request.Header.Set("SEQ", fmt.Sprint(event.seq))
+
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// Close ends all subscriptions, cancels the events being sent and waits for
+// them.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	if p.flush != nil {
+		p.flush.Stop()
+	}
+	for sid, sub := range p.subscribers {
+		delete(p.subscribers, sid)
+		sub.expiry.Stop()
+		close(sub.queue)
+	}
+	p.mu.Unlock()
+
+	p.cancel()
+	p.workers.Wait()
+	return nil
+}
+
+// parseCallbacks returns the HTTP URLs of a CALLBACK header, e.g.
+// "<http://192.168.1.2:8058/events/1><http://10.0.0.2:8058/events/1>".
+func parseCallbacks(header string) []*url.URL {
+	var callbacks []*url.URL
+	for _, field := range strings.Split(header, ">") {
+		field = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(field), "<"))
+		callback, err := url.Parse(field)
+		if err != nil || callback.Scheme != "http" || callback.Host == "" {
+			continue
+		}
+		callbacks = append(callbacks, callback)
+	}
+	return callbacks
+}
+
+func newSID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	// A version 4 UUID
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
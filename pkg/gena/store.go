@@ -0,0 +1,145 @@
+package gena
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is the persisted state of a subscription.
+type Record struct {
+	UDN       string    `json:"udn"`
+	ServiceID string    `json:"serviceId"`
+	EventURL  string    `json:"eventUrl"`
+	Path      string    `json:"path"`
+	SID       string    `json:"sid"`
+	Expires   time.Time `json:"expires"`
+}
+
+// Store persists subscriptions so that a restarted subscriber renews them
+// rather than subscribing anew.
+type Store interface {
+	Load() ([]Record, error)
+	Save([]Record) error
+}
+
+type storeOption struct {
+	store Store
+}
+
+func (s storeOption) apply(opts *options) {
+	opts.store = s.store
+}
+
+// WithStore persists subscriptions to the store and resumes those found in
+// it. The callback URL has to stay the same across restarts, so a fixed
+// listen address should be used as well.
+func WithStore(store Store) Option {
+	return storeOption{store}
+}
+
+// FileStore stores subscriptions as JSON in a file.
+type FileStore string
+
+func (f FileStore) Load() ([]Record, error) {
+	data, err := ioutil.ReadFile(string(f))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Save writes the records to a temporary file first so that a crash never
+// leaves a truncated file behind.
+func (f FileStore) Save(records []Record) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(string(f)), filepath.Base(string(f))+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), string(f))
+}
+
+// persist saves the current subscriptions. The caller must hold the lock.
+func (s *Subscriber) persist() {
+	if s.store == nil || s.closed {
+		return
+	}
+
+	records := make([]Record, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		if sub.sid == "" {
+			continue
+		}
+		records = append(records, Record{
+			UDN:       sub.udn,
+			ServiceID: sub.serviceID,
+			EventURL:  sub.eventURL.String(),
+			Path:      sub.path,
+			SID:       sub.sid,
+			Expires:   sub.expires,
+		})
+	}
+
+	// There is no one to report the error to; the subscriptions are saved
+	// again on the next renewal.
+	_ = s.store.Save(records)
+}
+
+// resume renews the stored subscriptions. Expired ones fail to renew and
+// are subscribed to again.
+func (s *Subscriber) resume(records []Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		eventURL, err := url.Parse(record.EventURL)
+		if err != nil {
+			continue
+		}
+
+		sub := &subscription{
+			udn:       record.UDN,
+			serviceID: record.ServiceID,
+			eventURL:  eventURL,
+			path:      record.Path,
+			sid:       record.SID,
+			expires:   record.Expires,
+		}
+		s.subscriptions[sub.path] = sub
+
+		if n, err := strconv.Atoi(strings.TrimPrefix(sub.path, "/events/")); err == nil && n > s.nextPath {
+			s.nextPath = n
+		}
+
+		go s.renew(sub)
+	}
+}
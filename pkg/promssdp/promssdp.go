@@ -0,0 +1,119 @@
+// Package promssdp exports metrics of SSDP discovery to Prometheus:
+//
+//	collector := promssdp.NewCollector(registry)
+//	prometheus.MustRegister(collector)
+//
+// Pass collector.SearchMiddleware() to ssdp.WithSearchMiddleware to record
+// the search latencies as well.
+package promssdp
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+	"time"
+)
+
+const namespace = "ssdp"
+
+var (
+	devicesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "registry", "devices"),
+		"Number of devices in the registry.",
+		nil, nil,
+	)
+	appearedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "registry", "devices_appeared_total"),
+		"Number of devices that appeared in the registry, sampled at each scrape.",
+		nil, nil,
+	)
+	disappearedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "registry", "devices_disappeared_total"),
+		"Number of devices that disappeared from the registry, sampled at each scrape.",
+		nil, nil,
+	)
+	lastSeenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "device", "last_seen_age_seconds"),
+		"Time since the device was last seen.",
+		[]string{"udn", "server"}, nil,
+	)
+)
+
+// Collector is a prometheus.Collector for a registry and the searches it
+// observes. Devices appearing and disappearing between two scrapes are not
+// counted.
+type Collector struct {
+	registry *ssdp.Registry
+	latency  prometheus.Histogram
+
+	mu          sync.Mutex
+	known       map[string]bool
+	appeared    uint64
+	disappeared uint64
+}
+
+// NewCollector returns a collector for the registry.
+func NewCollector(registry *ssdp.Registry) *Collector {
+	return &Collector{
+		registry: registry,
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "search",
+			Name:      "response_seconds",
+			Help:      "Time between sending a search and receiving a response.",
+			Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2, 3, 5},
+		}),
+		known: make(map[string]bool),
+	}
+}
+
+// SearchMiddleware returns middleware recording the latency of the search
+// responses, see ssdp.WithSearchMiddleware.
+func (c *Collector) SearchMiddleware() ssdp.SearchMiddleware {
+	return ssdp.SearchMiddleware{
+		Receive: func(next ssdp.SearchReceiver) ssdp.SearchReceiver {
+			return func(response *ssdp.SearchResponse) {
+				c.latency.Observe(response.RTT.Seconds())
+				next(response)
+			}
+		},
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- devicesDesc
+	ch <- appearedDesc
+	ch <- disappearedDesc
+	ch <- lastSeenDesc
+	c.latency.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	entries := c.registry.Snapshot()
+	now := time.Now()
+
+	c.mu.Lock()
+	current := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		current[entry.Key] = true
+		if !c.known[entry.Key] {
+			c.appeared++
+		}
+	}
+	for key := range c.known {
+		if !current[key] {
+			c.disappeared++
+		}
+	}
+	c.known = current
+	appeared, disappeared := c.appeared, c.disappeared
+	c.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(devicesDesc, prometheus.GaugeValue, float64(len(entries)))
+	ch <- prometheus.MustNewConstMetric(appearedDesc, prometheus.CounterValue, float64(appeared))
+	ch <- prometheus.MustNewConstMetric(disappearedDesc, prometheus.CounterValue, float64(disappeared))
+	for _, entry := range entries {
+		ch <- prometheus.MustNewConstMetric(lastSeenDesc, prometheus.GaugeValue, now.Sub(entry.LastSeen).Seconds(), entry.UDN, entry.Server)
+	}
+	c.latency.Collect(ch)
+}
@@ -0,0 +1,210 @@
+// Package scaffold generates Go code for a UPnP service from its SCPD: the
+// argument structs of its actions, the interface a device host implements
+// with a stub to embed, and a typed client for control points:
+//
+//	//go:generate go run github.com/Oleaintueri/gossdp/cmd/scaffold -scpd RenderingControl.xml -type urn:schemas-upnp-org:service:RenderingControl:1 -package renderer
+//
+// Control points of a known device class can generate only the client, from
+// the SCPD the device serves:
+//
+//	//go:generate go run github.com/Oleaintueri/gossdp/cmd/scaffold -client -scpd http://192.168.1.30:1400/xml/RenderingControl1.xml -type urn:schemas-upnp-org:service:RenderingControl:1 -package sonos
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+)
+
+// Config describes the code to generate.
+type Config struct {
+	// The package of the generated file
+	Package string
+	// The prefix of the generated identifiers, e.g. "RenderingControl",
+	// taken from ServiceType when empty
+	Name string
+	// The service type, e.g. "urn:schemas-upnp-org:service:RenderingControl:1"
+	ServiceType string
+	// Where the SCPD was read from, mentioned in the header
+	Source string
+	// Whether to leave out the code of the device host
+	ClientOnly bool
+}
+
+// goTypes maps the UPnP data types to Go types, others are strings.
+var goTypes = map[string]string{
+	"ui1":     "uint8",
+	"ui2":     "uint16",
+	"ui4":     "uint32",
+	"ui8":     "uint64",
+	"i1":      "int8",
+	"i2":      "int16",
+	"i4":      "int32",
+	"i8":      "int64",
+	"int":     "int64",
+	"r4":      "float32",
+	"r8":      "float64",
+	"number":  "float64",
+	"float":   "float64",
+	"boolean": "bool",
+}
+
+type field struct {
+	Name string
+	Type string
+	Tag  string
+}
+
+type action struct {
+	Name string
+	Go   string
+	In   []field
+	Out  []field
+}
+
+// Generate returns the gofmt'ed source for the service.
+func Generate(config Config, scpd *soap.SCPD) ([]byte, error) {
+	if config.Name == "" {
+		config.Name = nameOf(config.ServiceType)
+	}
+	if config.Package == "" || config.Name == "" {
+		return nil, fmt.Errorf("scaffold: a package and a service name or type are needed")
+	}
+
+	var actions []action
+	for _, a := range scpd.Actions {
+		generated := action{Name: a.Name, Go: identifier(a.Name)}
+		for _, argument := range a.Arguments {
+			variable, _ := scpd.StateVariable(argument.RelatedStateVariable)
+			f := field{Name: identifier(argument.Name), Type: "string"}
+			if goType, ok := goTypes[variable.DataType]; ok {
+				f.Type = goType
+			}
+			if f.Name != argument.Name {
+				f.Tag = fmt.Sprintf("`soap:%q`", argument.Name)
+			}
+			if argument.Direction == "out" {
+				generated.Out = append(generated.Out, f)
+			} else {
+				generated.In = append(generated.In, f)
+			}
+		}
+		actions = append(actions, generated)
+	}
+
+	var b bytes.Buffer
+	err := scaffoldTemplate.Execute(&b, struct {
+		Config
+		Actions []action
+	}{config, actions})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(b.Bytes())
+}
+
+// nameOf returns the name in a service type, e.g. "RenderingControl".
+func nameOf(serviceType string) string {
+	parts := strings.Split(serviceType, ":")
+	if len(parts) < 2 {
+		return ""
+	}
+	return identifier(parts[len(parts)-2])
+}
+
+// identifier returns an exported Go identifier for the name.
+func identifier(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case i == 0 && unicode.IsLetter(r):
+			b.WriteRune(unicode.ToUpper(r))
+		case i == 0:
+			b.WriteString("X")
+			if unicode.IsDigit(r) {
+				b.WriteRune(r)
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+var scaffoldTemplate = template.Must(template.New("scaffold").Parse(`// Code generated by scaffold{{if .Source}} from {{.Source}}{{end}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+)
+
+// {{.Name}}Type is the service type of {{.Name}}.
+const {{.Name}}Type = {{printf "%q" .ServiceType}}
+{{range .Actions}}
+// {{$.Name}}{{.Go}}In are the input arguments of {{.Name}}.
+type {{$.Name}}{{.Go}}In struct {
+{{- range .In}}
+	{{.Name}} {{.Type}} {{.Tag}}
+{{- end}}
+}
+
+// {{$.Name}}{{.Go}}Out are the output arguments of {{.Name}}.
+type {{$.Name}}{{.Go}}Out struct {
+{{- range .Out}}
+	{{.Name}} {{.Type}} {{.Tag}}
+{{- end}}
+}
+{{end}}
+{{- if not .ClientOnly}}
+// {{.Name}} is implemented by device hosts offering the service. Embed
+// Unimplemented{{.Name}} to only implement some of the actions.
+type {{.Name}} interface {
+{{- range .Actions}}
+	{{.Go}}(ctx context.Context, in {{$.Name}}{{.Go}}In) ({{$.Name}}{{.Go}}Out, error)
+{{- end}}
+}
+
+// Unimplemented{{.Name}} fails every action with 602 Optional Action Not
+// Implemented.
+type Unimplemented{{.Name}} struct{}
+{{range .Actions}}
+func (Unimplemented{{$.Name}}) {{.Go}}(ctx context.Context, in {{$.Name}}{{.Go}}In) ({{$.Name}}{{.Go}}Out, error) {
+	return {{$.Name}}{{.Go}}Out{}, &soap.UPnPError{Code: soap.ErrorNotImplemented, Description: "Optional Action Not Implemented"}
+}
+{{end}}
+// New{{.Name}}Service returns the service calling the actions of the
+// implementation, validated against the SCPD when not nil.
+func New{{.Name}}Service(implementation {{.Name}}, scpd *soap.SCPD) (*soap.Service, error) {
+	service := soap.NewService({{.Name}}Type, scpd)
+{{- range .Actions}}
+	if err := soap.Handle(service, {{printf "%q" .Name}}, implementation.{{.Go}}); err != nil {
+		return nil, err
+	}
+{{- end}}
+	return service, nil
+}
+{{end}}
+// {{.Name}}Client invokes the actions of the service on a device.
+type {{.Name}}Client struct {
+	*soap.Client
+}
+
+// New{{.Name}}Client returns a client for the service at the control URL.
+func New{{.Name}}Client(client *soap.Client) *{{.Name}}Client {
+	return &{{.Name}}Client{client}
+}
+{{range .Actions}}
+func (c *{{$.Name}}Client) {{.Go}}(ctx context.Context, in {{$.Name}}{{.Go}}In) ({{$.Name}}{{.Go}}Out, error) {
+	return soap.Call[{{$.Name}}{{.Go}}In, {{$.Name}}{{.Go}}Out](ctx, c.Client, {{printf "%q" .Name}}, in)
+}
+{{end}}`))
@@ -0,0 +1,143 @@
+// Package site runs independent discovery pipelines for several networks in
+// one process, such as a controller managing customer sites over VPN. Each
+// site searches with its own client and sockets, tracks devices in its own
+// registry and exports its metrics labeled with its name:
+//
+//	sites, _ := config.SitesFromFile("sites.yaml")
+//	group := site.NewGroup(sites)
+//	group.Register(prometheus.DefaultRegisterer)
+//	group.Run(ctx, time.Minute, func(s *site.Site, err error) {
+//		log.Printf("%s: %v", s.Name, err)
+//	})
+package site
+
+import (
+	"context"
+	"github.com/Oleaintueri/gossdp/pkg/config"
+	"github.com/Oleaintueri/gossdp/pkg/promssdp"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"github.com/prometheus/client_golang/prometheus"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Site is the discovery pipeline of one network.
+type Site struct {
+	Name string
+	// The targets searched each round, upnp:rootdevice when empty
+	Targets   []string
+	SSDP      *ssdp.SSDP
+	Registry  *ssdp.Registry
+	Collector *promssdp.Collector
+}
+
+// New returns the site for the configuration. The options are applied after
+// those of the configuration.
+func New(name string, cfg config.Config, opts ...ssdp.OptionSSDP) *Site {
+	registry := ssdp.NewRegistry()
+	collector := promssdp.NewCollector(registry)
+
+	options := append(cfg.Options(), ssdp.WithSearchMiddleware(collector.SearchMiddleware()))
+	options = append(options, opts...)
+
+	return &Site{
+		Name:      name,
+		Targets:   cfg.SearchTargets,
+		SSDP:      ssdp.NewSSDP(options...),
+		Registry:  registry,
+		Collector: collector,
+	}
+}
+
+// Round searches for the targets and records the responses as a rediscovery
+// round, see Registry.AddRound. It returns the keys of the devices removed.
+// No round is recorded when a search fails.
+func (s *Site) Round(ctx context.Context) ([]string, error) {
+	targets := s.Targets
+	if len(targets) == 0 {
+		targets = []string{ssdp.RootDevice}
+	}
+
+	var responses []ssdp.SearchResponse
+	for _, target := range targets {
+		found, err := s.SSDP.SearchContext(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, found...)
+	}
+
+	return s.Registry.AddRound(responses), nil
+}
+
+// Run runs a round right away and then at every interval until the context
+// is done, passing the errors of failed rounds to onError when it is not
+// nil.
+func (s *Site) Run(ctx context.Context, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Round(ctx); err != nil && ctx.Err() == nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// A Group is a set of sites run together.
+type Group struct {
+	// The sites, sorted by name
+	Sites []*Site
+}
+
+// NewGroup returns the sites of the configuration. The options are applied
+// to every site.
+func NewGroup(cfg config.SitesConfig, opts ...ssdp.OptionSSDP) *Group {
+	group := &Group{}
+	for name, siteConfig := range cfg.Sites {
+		group.Sites = append(group.Sites, New(name, siteConfig, opts...))
+	}
+	sort.Slice(group.Sites, func(i, j int) bool {
+		return group.Sites[i].Name < group.Sites[j].Name
+	})
+	return group
+}
+
+// Register registers the collector of each site, its metrics labeled with
+// site="<name>".
+func (g *Group) Register(registerer prometheus.Registerer) error {
+	for _, site := range g.Sites {
+		labeled := prometheus.WrapRegistererWith(prometheus.Labels{"site": site.Name}, registerer)
+		if err := labeled.Register(site.Collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run runs every site concurrently until the context is done, see Site.Run.
+func (g *Group) Run(ctx context.Context, interval time.Duration, onError func(*Site, error)) error {
+	var wg sync.WaitGroup
+	for _, site := range g.Sites {
+		wg.Add(1)
+		go func(site *Site) {
+			defer wg.Done()
+
+			var siteError func(error)
+			if onError != nil {
+				siteError = func(err error) { onError(site, err) }
+			}
+			site.Run(ctx, interval, siteError)
+		}(site)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
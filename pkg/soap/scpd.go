@@ -0,0 +1,119 @@
+package soap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// The largest service description that is read.
+const maxSCPDSize = 1 << 20
+
+// SCPD is a service description, listing the actions of a service and its
+// state variables.
+type SCPD struct {
+	Actions        []Action        `xml:"actionList>action"`
+	StateVariables []StateVariable `xml:"serviceStateTable>stateVariable"`
+}
+
+// Action is an action of a service with its arguments in order.
+type Action struct {
+	Name      string     `xml:"name"`
+	Arguments []Argument `xml:"argumentList>argument"`
+}
+
+// Argument is an input or output argument of an action.
+type Argument struct {
+	Name string `xml:"name"`
+	// "in" or "out"
+	Direction string `xml:"direction"`
+	// The state variable giving the type of the argument
+	RelatedStateVariable string `xml:"relatedStateVariable"`
+}
+
+// StateVariable is a state variable of a service.
+type StateVariable struct {
+	Name string `xml:"name"`
+	// The UPnP data type, e.g. "string", "ui4" or "boolean"
+	DataType string `xml:"dataType"`
+	// "yes" when changes are evented
+	SendEvents    string   `xml:"sendEvents,attr"`
+	AllowedValues []string `xml:"allowedValueList>allowedValue"`
+}
+
+// ParseSCPD decodes a service description.
+func ParseSCPD(scpd io.Reader) (*SCPD, error) {
+	result := &SCPD{}
+	if err := xml.NewDecoder(io.LimitReader(scpd, maxSCPDSize)).Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FetchSCPD fetches and decodes the service description at the URL, e.g. the
+// SCPDURL of a service resolved with ssdp.Device.ResolveURL. The client is
+// http.DefaultClient when nil, bypassing the transport, proxy and request
+// decorator of the ssdp client its HTTPClient would bring along.
+func FetchSCPD(ctx context.Context, httpClient *http.Client, scpdURL *url.URL) (*SCPD, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, scpdURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status %q", scpdURL, response.Status)
+	}
+	return ParseSCPD(response.Body)
+}
+
+// Action returns the named action.
+func (s *SCPD) Action(name string) (Action, bool) {
+	for _, action := range s.Actions {
+		if action.Name == name {
+			return action, true
+		}
+	}
+	return Action{}, false
+}
+
+// StateVariable returns the named state variable.
+func (s *SCPD) StateVariable(name string) (StateVariable, bool) {
+	for _, variable := range s.StateVariables {
+		if variable.Name == name {
+			return variable, true
+		}
+	}
+	return StateVariable{}, false
+}
+
+// In returns the input arguments of the action.
+func (a Action) In() []Argument {
+	return a.arguments("in")
+}
+
+// Out returns the output arguments of the action.
+func (a Action) Out() []Argument {
+	return a.arguments("out")
+}
+
+func (a Action) arguments(direction string) []Argument {
+	var arguments []Argument
+	for _, argument := range a.Arguments {
+		if argument.Direction == direction {
+			arguments = append(arguments, argument)
+		}
+	}
+	return arguments
+}
@@ -0,0 +1,348 @@
+package soap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The largest SOAP request that is read.
+const maxRequestSize = 1 << 20
+
+// UPnP error codes of the control protocol.
+const (
+	ErrorInvalidAction        = 401
+	ErrorInvalidArgs          = 402
+	ErrorActionFailed         = 501
+	ErrorArgumentValueInvalid = 600
+	ErrorNotImplemented       = 602
+)
+
+// Service is the device side of control for a service: served at the
+// control URL of the service, e.g. with ssdp.Advertiser.Handle, it decodes
+// the action requests, calls the handler of the action and encodes its
+// result or error.
+type Service struct {
+	ServiceType string
+
+	scpd *SCPD
+
+	mu      sync.RWMutex
+	actions map[string]actionHandler
+}
+
+// actionHandler handles an action with its input arguments by name and
+// returns the output arguments in order.
+type actionHandler func(ctx context.Context, in map[string]string) ([]Arg, error)
+
+// NewService returns a service without actions. With an SCPD the actions
+// and their arguments are validated against it.
+func NewService(serviceType string, scpd *SCPD) *Service {
+	return &Service{
+		ServiceType: serviceType,
+		scpd:        scpd,
+		actions:     make(map[string]actionHandler),
+	}
+}
+
+// Handle registers the handler of the action. The exported fields of In and
+// Out are bound to the arguments of the same name, or the name in their
+// `soap:"..."` tag. Fields may be strings, booleans or numbers. Handlers
+// return a *UPnPError to fail with its code, other errors fail with 501
+// Action Failed. With an SCPD the fields must match the arguments of the
+// action, the output being sent in the order it lists them.
+func Handle[In, Out any](service *Service, action string, handler func(ctx context.Context, in In) (Out, error)) error {
+	inType, outType := reflect.TypeOf((*In)(nil)).Elem(), reflect.TypeOf((*Out)(nil)).Elem()
+	if inType.Kind() != reflect.Struct || outType.Kind() != reflect.Struct {
+		return fmt.Errorf("%s: arguments must be bound to structs", action)
+	}
+	inFields, err := bindFields(inType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	outFields, err := bindFields(outType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+
+	outNames := make([]string, 0, len(outFields))
+	for _, field := range outFields {
+		outNames = append(outNames, field.name)
+	}
+
+	var scpdAction Action
+	if service.scpd != nil {
+		var ok bool
+		if scpdAction, ok = service.scpd.Action(action); !ok {
+			return fmt.Errorf("%s: not an action of the service", action)
+		}
+		if err := matchArguments(scpdAction.In(), inFields, "input"); err != nil {
+			return fmt.Errorf("%s: %w", action, err)
+		}
+		if err := matchArguments(scpdAction.Out(), outFields, "output"); err != nil {
+			return fmt.Errorf("%s: %w", action, err)
+		}
+		outNames = outNames[:0]
+		for _, argument := range scpdAction.Out() {
+			outNames = append(outNames, argument.Name)
+		}
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	service.actions[action] = func(ctx context.Context, args map[string]string) ([]Arg, error) {
+		if service.scpd != nil {
+			if err := service.validate(scpdAction, args); err != nil {
+				return nil, err
+			}
+		}
+
+		var in In
+		inValue := reflect.ValueOf(&in).Elem()
+		for _, field := range inFields {
+			value, ok := args[field.name]
+			if !ok {
+				return nil, &UPnPError{Code: ErrorInvalidArgs, Description: "Invalid Args"}
+			}
+			if err := setField(inValue.Field(field.index), value); err != nil {
+				return nil, &UPnPError{Code: ErrorArgumentValueInvalid, Description: "Argument Value Invalid"}
+			}
+		}
+
+		out, err := handler(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+
+		outValue := reflect.ValueOf(out)
+		byName := make(map[string]string, len(outFields))
+		for _, field := range outFields {
+			byName[field.name] = formatField(outValue.Field(field.index))
+		}
+		result := make([]Arg, 0, len(outNames))
+		for _, name := range outNames {
+			result = append(result, Arg{Name: name, Value: byName[name]})
+		}
+		return result, nil
+	}
+	return nil
+}
+
+type boundField struct {
+	name  string
+	index int
+}
+
+// bindFields returns the argument names of the exported fields of the struct.
+func bindFields(structType reflect.Type) ([]boundField, error) {
+	var fields []boundField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.String, reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		default:
+			return nil, fmt.Errorf("field %s has unsupported type %s", field.Name, field.Type)
+		}
+		name := field.Name
+		if tag := field.Tag.Get("soap"); tag != "" {
+			name = tag
+		}
+		fields = append(fields, boundField{name: name, index: i})
+	}
+	return fields, nil
+}
+
+// matchArguments checks that the fields are bound to exactly the arguments.
+func matchArguments(arguments []Argument, fields []boundField, direction string) error {
+	bound := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		bound[field.name] = true
+	}
+	for _, argument := range arguments {
+		if !bound[argument.Name] {
+			return fmt.Errorf("%s argument %s is not bound", direction, argument.Name)
+		}
+		delete(bound, argument.Name)
+	}
+	for name := range bound {
+		return fmt.Errorf("%s argument %s is not in the SCPD", direction, name)
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(strings.TrimSpace(value), 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(strings.TrimSpace(value), 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	}
+	return nil
+}
+
+func formatField(field reflect.Value) string {
+	if field.Kind() == reflect.Bool {
+		if field.Bool() {
+			return "1"
+		}
+		return "0"
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// parseBool parses the values UPnP allows for booleans.
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes":
+		return true, nil
+	case "0", "false", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+// validate checks the input arguments against the SCPD.
+func (s *Service) validate(action Action, args map[string]string) error {
+	for _, argument := range action.In() {
+		value, ok := args[argument.Name]
+		if !ok {
+			return &UPnPError{Code: ErrorInvalidArgs, Description: "Invalid Args"}
+		}
+		variable, ok := s.scpd.StateVariable(argument.RelatedStateVariable)
+		if !ok || len(variable.AllowedValues) == 0 {
+			continue
+		}
+		allowed := false
+		for _, allowedValue := range variable.AllowedValues {
+			allowed = allowed || value == allowedValue
+		}
+		if !allowed {
+			return &UPnPError{Code: ErrorArgumentValueInvalid, Description: "Argument Value Invalid"}
+		}
+	}
+	return nil
+}
+
+// ServeHTTP handles the action requests of control points.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, args, err := parseCall(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		writeFault(w, &UPnPError{Code: ErrorInvalidAction, Description: "Invalid Action"})
+		return
+	}
+	// The SOAPACTION header names the action too, "type#Action" in quotes
+	if header := strings.Trim(r.Header.Get("SOAPAction"), `"`); header != "" && header != s.ServiceType+"#"+name {
+		writeFault(w, &UPnPError{Code: ErrorInvalidAction, Description: "Invalid Action"})
+		return
+	}
+
+	s.mu.RLock()
+	handler, ok := s.actions[name]
+	s.mu.RUnlock()
+	if !ok {
+		writeFault(w, &UPnPError{Code: ErrorInvalidAction, Description: "Invalid Action"})
+		return
+	}
+
+	out, err := handler(r.Context(), args)
+	if err != nil {
+		var upnpErr *UPnPError
+		if !errors.As(err, &upnpErr) {
+			upnpErr = &UPnPError{Code: ErrorActionFailed, Description: "Action Failed"}
+		}
+		writeFault(w, upnpErr)
+		return
+	}
+
+	b := &bytes.Buffer{}
+	b.WriteString(`<?xml version="1.0"?>`)
+	b.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(b, `<u:%sResponse xmlns:u="%s">`, name, escape(s.ServiceType))
+	for _, arg := range out {
+		fmt.Fprintf(b, "<%s>%s</%s>", arg.Name, escape(arg.Value), arg.Name)
+	}
+	fmt.Fprintf(b, `</u:%sResponse>`, name)
+	b.WriteString(`</s:Body></s:Envelope>`)
+
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("EXT", "")
+	w.Write(b.Bytes())
+}
+
+// parseCall returns the action and the input arguments of a request.
+func parseCall(body io.Reader) (string, map[string]string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	env := &envelope{}
+	if err := xml.Unmarshal(data, env); err != nil {
+		return "", nil, err
+	}
+
+	var call struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(env.Body.Content, &call); err != nil {
+		return "", nil, err
+	}
+
+	args, err := parseArgs(env.Body.Content)
+	if err != nil {
+		return "", nil, err
+	}
+	return call.XMLName.Local, args, nil
+}
+
+func writeFault(w http.ResponseWriter, upnpErr *UPnPError) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<?xml version="1.0"?>`+
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`+
+		`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`+
+		`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError>`+
+		`</detail></s:Fault></s:Body></s:Envelope>`, upnpErr.Code, escape(upnpErr.Description))
+}
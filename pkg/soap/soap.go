@@ -0,0 +1,197 @@
+// Package soap implements UPnP control over SOAP. A Client invokes actions
+// on device services, a Service handles them on the device side.
+package soap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// The largest SOAP response that is read.
+const maxResponseSize = 4 << 20
+
+// Arg is a named action argument. Arguments are sent in order, as the UPnP
+// specification requires.
+type Arg struct {
+	Name  string
+	Value string
+}
+
+// Client invokes actions on a single service.
+type Client struct {
+	// The HTTP client to send requests with, http.DefaultClient if nil.
+	// Pass the HTTPClient of the ssdp client to use its transport, proxy
+	// and request decorator, which nil bypasses.
+	HTTPClient *http.Client
+	// The control URL of the service
+	URL *url.URL
+	// The service type, e.g. urn:schemas-upnp-org:service:AVTransport:1
+	ServiceType string
+}
+
+func NewClient(controlURL *url.URL, serviceType string, httpClient *http.Client) *Client {
+	return &Client{
+		HTTPClient:  httpClient,
+		URL:         controlURL,
+		ServiceType: serviceType,
+	}
+}
+
+// UPnPError is the error returned by a device for a failed action.
+type UPnPError struct {
+	Code        int    `xml:"errorCode"`
+	Description string `xml:"errorDescription"`
+}
+
+func (e *UPnPError) Error() string {
+	return fmt.Sprintf("upnp error %d: %s", e.Code, e.Description)
+}
+
+type envelope struct {
+	Body struct {
+		Content []byte `xml:",innerxml"`
+		Fault   *struct {
+			Detail struct {
+				UPnPError *UPnPError `xml:"UPnPError"`
+			} `xml:"detail"`
+			String string `xml:"faultstring"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+// Invoke calls the action with the arguments and returns the output
+// arguments by name.
+func (c *Client) Invoke(ctx context.Context, action string, args []Arg) (map[string]string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL.String(), bytes.NewReader(c.buildRequest(action, args)))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	request.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, c.ServiceType, action))
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envelope{}
+	if err := xml.Unmarshal(body, env); err != nil {
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: unexpected status %q", action, response.Status)
+		}
+		return nil, err
+	}
+
+	if fault := env.Body.Fault; fault != nil {
+		if fault.Detail.UPnPError != nil {
+			return nil, fault.Detail.UPnPError
+		}
+		return nil, fmt.Errorf("%s: soap fault %q", action, fault.String)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %q", action, response.Status)
+	}
+
+	return parseArgs(env.Body.Content)
+}
+
+func (c *Client) buildRequest(action string, args []Arg) []byte {
+	b := &bytes.Buffer{}
+
+	b.WriteString(`<?xml version="1.0"?>`)
+	b.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(b, `<u:%s xmlns:u="%s">`, action, escape(c.ServiceType))
+	for _, arg := range args {
+		fmt.Fprintf(b, "<%s>%s</%s>", arg.Name, escape(arg.Value), arg.Name)
+	}
+	fmt.Fprintf(b, `</u:%s>`, action)
+	b.WriteString(`</s:Body></s:Envelope>`)
+
+	return b.Bytes()
+}
+
+// parseArgs reads the child elements of the action response element.
+func parseArgs(content []byte) (map[string]string, error) {
+	var response struct {
+		Args []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		} `xml:",any"`
+	}
+
+	if err := xml.Unmarshal(content, &response); err != nil {
+		return nil, err
+	}
+
+	args := make(map[string]string, len(response.Args))
+	for _, arg := range response.Args {
+		args[arg.XMLName.Local] = arg.Value
+	}
+
+	return args, nil
+}
+
+func escape(value string) string {
+	b := &bytes.Buffer{}
+	_ = xml.EscapeText(b, []byte(value))
+	return b.String()
+}
+
+// Call invokes the action like Invoke with the arguments bound to the fields
+// of In and Out, see Handle. Output arguments the device left out keep their
+// zero value.
+func Call[In, Out any](ctx context.Context, c *Client, action string, in In) (Out, error) {
+	var out Out
+
+	inValue, outValue := reflect.ValueOf(in), reflect.ValueOf(&out).Elem()
+	if inValue.Kind() != reflect.Struct || outValue.Kind() != reflect.Struct {
+		return out, fmt.Errorf("%s: arguments must be bound to structs", action)
+	}
+	inFields, err := bindFields(inValue.Type())
+	if err != nil {
+		return out, fmt.Errorf("%s: %w", action, err)
+	}
+	outFields, err := bindFields(outValue.Type())
+	if err != nil {
+		return out, fmt.Errorf("%s: %w", action, err)
+	}
+
+	args := make([]Arg, 0, len(inFields))
+	for _, field := range inFields {
+		args = append(args, Arg{Name: field.name, Value: formatField(inValue.Field(field.index))})
+	}
+
+	result, err := c.Invoke(ctx, action, args)
+	if err != nil {
+		return out, err
+	}
+
+	for _, field := range outFields {
+		value, ok := result[field.name]
+		if !ok {
+			continue
+		}
+		if err := setField(outValue.Field(field.index), value); err != nil {
+			return out, fmt.Errorf("%s: output argument %s: %w", action, field.name, err)
+		}
+	}
+	return out, nil
+}
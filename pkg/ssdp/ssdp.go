@@ -3,9 +3,10 @@
 package ssdp
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -13,6 +14,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,8 +23,90 @@ type options struct {
 	port int
 	// The IP for SSDP broadcast
 	broadcastIp string
+	// The name of the network interface to search on
+	iface string
 	// timeout in milliseconds
 	timeout time.Duration
+	// progress is called periodically while a search is running
+	progress func(Progress)
+	// clock is the time source for deadlines and expiry
+	clock Clock
+	// quirks of known devices to tolerate
+	quirks []Quirk
+	// whether to return responses sent by the local machine
+	includeSelf bool
+	// pool of sockets shared between searches
+	pool *ConnPool
+	// retry policy for description fetches
+	retry RetryPolicy
+	// transport for HTTP requests to devices
+	transport http.RoundTripper
+	// proxy for HTTP requests to devices, nil for the environment
+	proxy func(*http.Request) (*url.URL, error)
+	// tuning of the connection reuse, see WithHTTPTuning
+	tuning *HTTPTuning
+	// the devices whose connections are not reused, see Quirk.NoKeepAlive
+	noKeepAlive *hostSet
+	// the quirks learned from the model names of the descriptions
+	hostQuirks *hostQuirks
+	// destinations rejecting probes, see Unreachables
+	unreachable *unreachables
+	// the concurrent description requests per host, see
+	// WithMaxFetchesPerHost
+	fetchesPerHost int
+	hostLimiter    *hostLimiter
+	// decorate modifies HTTP requests to devices before they are sent
+	decorate func(*http.Request) error
+	// numeric disables hostname lookups
+	numeric bool
+	// further multicast groups searched alongside broadcastIp
+	groups []string
+	// whether to drop responses from outside the local prefixes
+	onLinkOnly bool
+	// the most responses retained per search, zero for no cap
+	maxResponses int
+	// the UDA version to follow, zero for lenient
+	udaVersion UDAVersion
+	// the control point identification sent in UDA 2.0 searches
+	cpFriendlyName string
+	cpUUID         string
+	// whether to validate responses, see WithStrict
+	strict bool
+	// filters of the responses, see WithExclude and WithIncludeOnly
+	exclude     *DeviceFilter
+	includeOnly *DeviceFilter
+	// learns the search window, see WithAdaptiveTimeout
+	adaptive *adaptiveTimeout
+	// limits outgoing searches, see WithSearchRateLimit
+	limiter *searchLimiter
+	// wraps searches, see WithSearchMiddleware
+	middleware []SearchMiddleware
+	// retrieves descriptions, nil for HTTP
+	fetcher Fetcher
+	// keeps fetched descriptions, see WithDescriptionCache
+	cache *DescriptionCache
+	// logs the progress of searches, see WithLogger
+	logger Logger
+	// maps locations before fetching, see WithLocationRewriter
+	rewrite LocationRewriter
+	// looks up the MAC of responders, see WithResolveMAC
+	resolveMAC bool
+	// looks up the hostname of responders, see WithReverseDNS
+	reverseDNS *reverseDNS
+	// how long to wait for locations to accept a connection, zero to not
+	// check them, see WithReachabilityCheck
+	reachTimeout time.Duration
+	// logs the wire text, see WithWireLog
+	wireLog   bool
+	redactors []Redactor
+	// publishes what happens, see WithEventBus
+	events *EventBus
+	// enrich fetched descriptions, see WithDescriptionHooks
+	descriptionHooks []DescriptionHook
+	// how advertisers announce, see WithPowerProfile
+	power PowerProfile
+	// receives the searches of the advertiser, see WithAdvertiserConn
+	advertiserConn *net.UDPConn
 }
 
 type OptionSSDP interface {
@@ -55,6 +139,9 @@ func WithBroadcast(broadcast string) OptionSSDP {
 	return broadcastOption(broadcast)
 }
 
+// The search timeout when not set with WithTimeout, sent as an MX of 3.
+const defaultTimeout = 3 * time.Second
+
 func WithTimeout(timeout int) OptionSSDP {
 	return timeoutOption(timeout)
 }
@@ -67,43 +154,140 @@ func NewSSDP(opts ...OptionSSDP) *SSDP {
 	options := &options{
 		port:        9000,
 		broadcastIp: "239.235.255.250",
+		timeout:     defaultTimeout,
+		clock:       realClock{},
+		quirks:      DefaultQuirks,
+
+		fetchesPerHost: defaultFetchesPerHost,
 	}
 
 	for _, o := range opts {
 		o.apply(options)
 	}
 
+	options.transport = options.tuneTransport()
+	options.noKeepAlive = &hostSet{}
+	options.hostQuirks = &hostQuirks{}
+	options.unreachable = &unreachables{}
+	options.hostLimiter = newHostLimiter(options.fetchesPerHost)
+
 	return &SSDP{options}
 }
 
 // The search response from a device implementing SSDP.
 type SearchResponse struct {
-	Control      string
-	Server       string
-	ST           string
-	Ext          string
-	USN          string
-	Location     *url.URL
-	Date         time.Time
+	Control  string
+	Server   string
+	ST       string
+	Ext      string
+	USN      string
+	Location *url.URL
+	// The HTTPS location of the description of devices that support
+	// Device Protection
+	SecureLocation *url.URL
+	Date           time.Time
+	// The network location signature, which changes when the device moves
+	// to another network
+	NLS          string
 	ResponseAddr *net.UDPAddr
+	// The port the device answers unicast searches on, zero when it did
+	// not send SEARCHPORT.UPNP.ORG, see SearchAddr
+	SearchPort int
+	// The BOOTID.UPNP.ORG, increased by the device each time it reboots,
+	// zero when it did not send one
+	BootID int
+	// The CONFIGID.UPNP.ORG, changed by the device whenever its description
+	// changes, zero when it did not send one. Searches only refetch cached
+	// descriptions when it changes, see WithDescriptionCache.
+	ConfigID int
+	// The multicast group the search was sent to, see WithGroups
+	Group string
+	// The index of the interface the response was received on, zero when
+	// unknown
+	InterfaceIndex int
+	// The local address the response was received on, nil when unknown
+	LocalAddr *net.UDPAddr
+	// The time the response was received
+	Received time.Time
+	// The time between sending the search and receiving the response
+	RTT time.Duration
+	// The problems found validating the response, see WithStrict
+	Findings []Finding
+	// The number of identical responses, from the same address with the
+	// same USN and ST, received earlier in the search. Searches return
+	// every copy, a nonzero count points at a device answering twice or a
+	// multicast loop.
+	Duplicate int
+	// The MAC address of the responder, nil unless resolved, see
+	// WithResolveMAC
+	MAC net.HardwareAddr
+	// The hostname of the responder, empty unless resolved, see
+	// WithReverseDNS
+	Hostname string
+	// The target the search was sent with, which the ST of the response
+	// answers, see MatchesST
+	SearchST string
+	// The protocol version and status line as sent, e.g. "HTTP/1.1" and
+	// "HTTP/1.1 200 OK", for spotting devices answering HTTP/1.0 or with
+	// their own reason phrase
+	Proto      string
+	StatusLine string
+}
+
+// MaxAge returns the max-age directive of the Cache-Control header, or zero
+// when it is missing or malformed.
+func (r SearchResponse) MaxAge() time.Duration {
+	return parseMaxAge(r.Control)
+}
+
+// Expires returns the time the response stops being valid according to its
+// max-age, relative to the time it was received.
+func (r SearchResponse) Expires() time.Time {
+	return r.Received.Add(r.MaxAge())
+}
+
+// Expired reports whether the response is no longer valid at the given time.
+func (r SearchResponse) Expired(now time.Time) bool {
+	return !now.Before(r.Expires())
+}
+
+func parseMaxAge(control string) time.Duration {
+	for _, directive := range strings.Split(control, ",") {
+		parts := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if len(parts) != 2 || !strings.EqualFold(strings.TrimSpace(parts[0]), "max-age") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
 }
 
 type Device struct {
-	SpecVersion      SpecVersion `xml:"specVersion"`
-	URLBase          string      `xml:"URLBase"`
-	DeviceType       string      `xml:"device>deviceType"`
-	FriendlyName     string      `xml:"device>friendlyName"`
-	Manufacturer     string      `xml:"device>manufacturer"`
-	ManufacturerURL  string      `xml:"device>manufacturerURL"`
-	ModelDescription string      `xml:"device>modelDescription"`
-	ModelName        string      `xml:"device>modelName"`
-	ModelNumber      string      `xml:"device>modelNumber"`
-	ModelURL         string      `xml:"device>modelURL"`
-	SerialNumber     string      `xml:"device>serialNumber"`
-	UDN              string      `xml:"device>UDN"`
-	UPC              string      `xml:"device>UPC"`
-	PresentationURL  string      `xml:"device>presentationURL"`
-	Icons            []Icon      `xml:"device>iconList>icon"`
+	SpecVersion      SpecVersion      `xml:"specVersion"`
+	URLBase          string           `xml:"URLBase"`
+	DeviceType       string           `xml:"device>deviceType"`
+	FriendlyName     string           `xml:"device>friendlyName"`
+	Manufacturer     string           `xml:"device>manufacturer"`
+	ManufacturerURL  string           `xml:"device>manufacturerURL"`
+	ModelDescription string           `xml:"device>modelDescription"`
+	ModelName        string           `xml:"device>modelName"`
+	ModelNumber      string           `xml:"device>modelNumber"`
+	ModelURL         string           `xml:"device>modelURL"`
+	SerialNumber     string           `xml:"device>serialNumber"`
+	UDN              string           `xml:"device>UDN"`
+	UPC              string           `xml:"device>UPC"`
+	PresentationURL  string           `xml:"device>presentationURL"`
+	Icons            []Icon           `xml:"device>iconList>icon"`
+	Services         []Service        `xml:"device>serviceList>service"`
+	Devices          []EmbeddedDevice `xml:"device>deviceList>device"`
+	// The description URL the device was fetched from
+	Location *url.URL `xml:"-"`
+	// The problems found validating the description, see WithStrict
+	Findings []Finding `xml:"-"`
 }
 
 type SpecVersion struct {
@@ -119,6 +303,25 @@ type Icon struct {
 	URL      string `xml:"url"`
 }
 
+// A device embedded in a root device.
+type EmbeddedDevice struct {
+	DeviceType   string           `xml:"deviceType"`
+	FriendlyName string           `xml:"friendlyName"`
+	Manufacturer string           `xml:"manufacturer"`
+	ModelName    string           `xml:"modelName"`
+	UDN          string           `xml:"UDN"`
+	Services     []Service        `xml:"serviceList>service"`
+	Devices      []EmbeddedDevice `xml:"deviceList>device"`
+}
+
+type Service struct {
+	ServiceType string `xml:"serviceType"`
+	ServiceID   string `xml:"serviceId"`
+	SCPDURL     string `xml:"SCPDURL"`
+	ControlURL  string `xml:"controlURL"`
+	EventSubURL string `xml:"eventSubURL"`
+}
+
 // The search reader interface to read UDP packets on the wire with a timeout
 // period specified.
 type searchReader interface {
@@ -130,87 +333,244 @@ type searchReader interface {
 // to discover new devices. This function will return an array of SearchReponses
 // discovered.
 func (ssdp *SSDP) Search(search string) ([]SearchResponse, error) {
-	conn, err := ssdp.listenForSearchResponses()
+	return ssdp.SearchContext(context.Background(), search)
+}
+
+// SearchContext searches like Search, but gives up with the error of the
+// context when it is done before the search window closes.
+func (ssdp *SSDP) SearchContext(ctx context.Context, search string) ([]SearchResponse, error) {
+	responses, err := ssdp.AppendResponses(ctx, make([]SearchResponse, 0, 10), search)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
+	return responses, nil
+}
 
-	searchBytes, broadcastAddr, err := ssdp.buildSearchRequest(search)
+// AppendResponses searches like SearchContext, appending the responses to dst
+// and returning the extended slice. Scanners searching continuously can pass
+// the result of the previous search, truncated with dst[:0], to reuse its
+// backing array instead of growing a new one each time. On error dst is
+// returned unchanged.
+func (ssdp *SSDP) AppendResponses(ctx context.Context, dst []SearchResponse, search string) ([]SearchResponse, error) {
+	ctx, scope := ssdp.withSearchScope(ctx, search)
 
+	readers, sent, release, err := ssdp.sendSearch(ctx, search)
 	if err != nil {
-		return nil, err
+		ssdp.publishSearch(ctx, EventSearchFinished, 0, err)
+		return dst, scope.wrap(err)
 	}
+	defer release()
 
-	// Write search bytes on the wire so all devices can respond
-	_, err = conn.WriteTo(searchBytes, broadcastAddr)
+	responses, err := ssdp.readSearchResponses(ctx, dst, search, readers, sent)
 	if err != nil {
-		return nil, err
+		return dst, scope.wrap(err)
 	}
+	return responses, nil
+}
 
-	return ssdp.readSearchResponses(conn)
+// sendSearch sends the search to each group and returns the readers for the
+// responses, the time the search was sent and a function releasing the
+// sockets.
+func (ssdp *SSDP) sendSearch(ctx context.Context, search string) ([]groupReader, time.Time, func(), error) {
+	ssdp.publishSearch(ctx, EventSearchStarted, 0, nil)
+
+	conns, release, err := ssdp.listenForGroups()
+	if err != nil {
+		return nil, time.Time{}, nil, err
+	}
+
+	// Write search bytes on the wire so all devices can respond
+	var sent time.Time
+	for _, conn := range conns {
+		request, broadcastAddr, err := ssdp.buildSearchRequest(search, conn.group)
+
+		if err != nil {
+			release()
+			return nil, time.Time{}, nil, err
+		}
+
+		if err := ssdp.limiter.wait(ctx, ssdp.clock); err != nil {
+			release()
+			return nil, time.Time{}, nil, err
+		}
+		if sent.IsZero() {
+			sent = ssdp.clock.Now()
+		}
+
+		conn := conn
+		send := ssdp.searchSender(func(request *http.Request) error {
+			searchBytes, err := encodeSearchRequest(request)
+			if err != nil {
+				return err
+			}
+			ssdp.logWire(ctx, "search wire", searchBytes, "to", broadcastAddr.String())
+			_, err = conn.WriteTo(searchBytes, broadcastAddr)
+			return err
+		})
+		err = send(request)
+		ssdp.log(ctx, "search sent", "group", conn.group, "to", broadcastAddr.String(), "error", err)
+		if err != nil {
+			release()
+			return nil, time.Time{}, nil, err
+		}
+	}
+
+	readers := make([]groupReader, len(conns))
+	for i, conn := range conns {
+		readers[i] = conn.groupReader
+	}
+
+	return readers, sent, release, nil
 }
 
 func (ssdp *SSDP) SearchDevices(search string) ([]Device, error) {
-	responses, err := ssdp.Search(search)
+	return ssdp.SearchDevicesContext(context.Background(), search)
+}
+
+// SearchDevicesContext searches like SearchDevices, with the context bounding
+// both the search and the description fetches. To leave time for the
+// fetches, the deadline of the context should be well after the search
+// window closes.
+func (ssdp *SSDP) SearchDevicesContext(ctx context.Context, search string) ([]Device, error) {
+	responses, err := ssdp.SearchContext(ctx, search)
 
 	if err != nil {
 		return nil, err
 	}
 
-	uniqueLocations := make(map[url.URL]bool)
+	// The response of each location is kept to look up its quirks and for
+	// the description hooks
+	uniqueLocations := make(map[url.URL]SearchResponse)
 
 	for _, response := range responses {
-		uniqueLocations[*response.Location] = true
+		if response.Location == nil {
+			continue
+		}
+		uniqueLocations[*response.Location] = response
+		if ssdp.cache != nil {
+			ssdp.cache.checkConfigID(ssdp.rewriteLocation(*response.Location), response.ConfigID)
+		}
 	}
 
 	locations := make([]url.URL, 0, len(uniqueLocations))
 	for location, _ := range uniqueLocations {
 		locations = append(locations, location)
 	}
+	if ssdp.reachTimeout > 0 {
+		locations = ssdp.reachableLocations(ctx, locations)
+	}
+
+	// Fetch concurrently, bounded per host, giving up on the first error
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fetched := make([]*Device, len(locations))
+	errs := make([]error, len(locations))
+	var wg sync.WaitGroup
+	for i, location := range locations {
+		wg.Add(1)
+		go func(i int, location url.URL) {
+			defer wg.Done()
+			response := uniqueLocations[location]
+			fetched[i], errs[i] = ssdp.fetchDescription(ctx, location, ssdp.Quirks(response.Server, ""))
+			if errs[i] == nil {
+				errs[i] = ssdp.postProcess(fetched[i], &response)
+			}
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, location)
+	}
+	wg.Wait()
 
 	devices := make([]Device, 0, len(locations))
-	for _, location := range locations {
-		device, err := parseDescriptionXml(location)
-		if err != nil {
-			return nil, err
+	for i := range locations {
+		if errs[i] != nil && !errors.Is(errs[i], context.Canceled) {
+			return nil, errs[i]
+		}
+	}
+	for i := range locations {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
-		devices = append(devices, *device)
+		devices = append(devices, *fetched[i])
 	}
 
+	SortDevicesByAddress(devices)
+
 	return devices, nil
 }
 
-func (ssdp *SSDP) listenForSearchResponses() (*net.UDPConn, error) {
-	serverAddr, _ := net.ResolveUDPAddr("udp", fmt.Sprintf("0.0.0.0:%d", ssdp.port))
-	return net.ListenUDP("udp", serverAddr)
+// listenForSearchResponses returns a socket to search on, either borrowed from
+// the pool or newly bound, and a function that releases it afterwards.
+func (ssdp *SSDP) listenForSearchResponses() (*net.UDPConn, func(), error) {
+	ip := net.IPv4zero
+	if ssdp.iface != "" {
+		var err error
+		ip, err = InterfaceIP(ssdp.iface)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	addr := &net.UDPAddr{IP: ip, Port: ssdp.port}
+
+	if ssdp.pool != nil {
+		conn, err := ssdp.pool.get(addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, func() { ssdp.pool.put(addr, conn) }, nil
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conn, func() { conn.Close() }, nil
 }
 
-func (ssdp *SSDP) buildSearchRequest(st string) ([]byte, *net.UDPAddr, error) {
+func (ssdp *SSDP) buildSearchRequest(st string, group string) (*http.Request, *net.UDPAddr, error) {
 	// Placeholder to replace with * later on
 	// replaceMePlaceHolder := "/replacemewithstar"
 
-	broadcastAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", ssdp.broadcastIp, ssdp.port))
+	broadcastAddr, err := ssdp.resolveUDPAddr(group, ssdp.port)
 
 	if err != nil {
 		return nil, nil, err
 	}
 
+	request := ssdp.newSearchRequest(st, broadcastAddr)
+	request.Header.Set("mx", strconv.Itoa(int(ssdp.timeout/time.Second)))
+
+	return request, broadcastAddr, nil
+}
+
+// newSearchRequest returns an M-SEARCH request to the address without an MX,
+// which only multicast searches carry.
+func (ssdp *SSDP) newSearchRequest(st string, addr *net.UDPAddr) *http.Request {
 	request, _ := http.NewRequest("M-SEARCH",
-		fmt.Sprintf("http://%s/*", broadcastAddr.String()), strings.NewReader(""))
+		fmt.Sprintf("http://%s/*", addr.String()), strings.NewReader(""))
 
 	headers := request.Header
 	headers.Set("User-Agent", "")
 	headers.Set("st", st)
 	headers.Set("man", `"ssdp:discover"`)
-	headers.Set("mx", strconv.Itoa(int(ssdp.timeout/time.Second)))
+	for name, value := range ssdp.searchHeaders() {
+		headers[name] = []string{value}
+	}
+
+	return request
+}
 
+func encodeSearchRequest(request *http.Request) ([]byte, error) {
 	searchBytes := make([]byte, 0, 1024)
 	buffer := bytes.NewBuffer(searchBytes)
-	err = request.Write(buffer)
+	err := request.Write(buffer)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("error writing to buffer")
+		return nil, fmt.Errorf("error writing to buffer")
 	}
 
 	searchBytes = buffer.Bytes()
@@ -218,40 +578,222 @@ func (ssdp *SSDP) buildSearchRequest(st string) ([]byte, *net.UDPAddr, error) {
 	// Replace placeholder with *. Needed because request always escapes * when it shouldn't
 	// searchBytes = bytes.Replace(searchBytes, []byte(replaceMePlaceHolder), []byte("*"), 1)
 
-	return searchBytes, broadcastAddr, nil
+	return searchBytes, nil
 }
 
-func (ssdp *SSDP) readSearchResponses(reader searchReader) ([]SearchResponse, error) {
-	responses := make([]SearchResponse, 0, 10)
-	// Only listen for responses for duration amount of time.
-	err := reader.SetReadDeadline(time.Now().Add(ssdp.timeout))
+// readSearchResponses appends the responses to the search to dst.
+func (ssdp *SSDP) readSearchResponses(ctx context.Context, dst []SearchResponse, search string, readers []groupReader, sent time.Time) ([]SearchResponse, error) {
+	responses := dst
 
+	err := ssdp.searchLoop(ctx, search, readers, sent, func(response SearchResponse, _ []byte) {
+		responses = append(responses, response)
+	}, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	buf := make([]byte, 1024)
-	for {
-		rlen, addr, err := reader.ReadFromUDP(buf)
-		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
-			break // duration reached, return what we've found
+	ssdp.resolveMACs(ctx, responses[len(dst):])
+	ssdp.resolveHostnames(ctx, responses[len(dst):])
+	return responses, nil
+}
+
+// searchLoop passes each response read until the search window closes to
+// deliver, with its raw message. Packets that cannot be parsed go to
+// packetError when it is not nil. It returns the error of the context when
+// it is done first.
+func (ssdp *SSDP) searchLoop(ctx context.Context, search string, readers []groupReader, sent time.Time, deliver func(SearchResponse, []byte), packetError func(*PacketError)) error {
+	progress := newSearchProgress(ssdp.progress, ssdp.clock)
+	delivered := 0
+	var lastRTT time.Duration
+
+	var local map[string]bool
+	if !ssdp.includeSelf {
+		local = localAddrs()
+	}
+
+	var prefixes []*net.IPNet
+	if ssdp.onLinkOnly {
+		prefixes = localPrefixes()
+	}
+
+	// The message of the response being received
+	var raw []byte
+	copies := make(map[string]int)
+	receive := ssdp.searchReceiver(func(response *SearchResponse) {
+		if ssdp.filtered(response) {
+			progress.filtered++
+			progress.report()
+			return
 		}
-		if err != nil {
-			return nil, err
+		if ssdp.maxResponses > 0 && delivered >= ssdp.maxResponses {
+			progress.overflow++
+			progress.report()
+			return
+		}
+		key := addrString(response.ResponseAddr) + " " + response.USN + " " + response.ST
+		response.Duplicate = copies[key]
+		copies[key]++
+		progress.seen(response)
+		progress.report()
+		delivered++
+		lastRTT = response.RTT
+		if ssdp.events != nil {
+			received := *response
+			ssdp.events.Publish(Event{Type: EventResponseReceived, Time: received.Received, Response: &received})
 		}
+		deliver(*response, raw)
+	})
 
-		response, err := parseSearchResponse(bytes.NewReader(buf[:rlen]), addr)
-		if err != nil {
-			return nil, err
+	packets := make(chan packet)
+	for _, reader := range readers {
+		stop := readPackets(reader, packets)
+		defer stop()
+	}
+
+	// Only listen for responses for duration amount of time.
+	duration := ssdp.searchWindow()
+	window := ssdp.clock.After(duration)
+	for {
+		select {
+		case <-window:
+			progress.report()
+			ssdp.adaptive.record(lastRTT, duration)
+			ssdp.log(ctx, "search done", "responses", delivered, "packets", progress.packets, "window", duration)
+			ssdp.publishSearch(ctx, EventSearchFinished, delivered, nil)
+			return nil // duration reached, return what we've found
+		case <-ctx.Done():
+			progress.report()
+			ssdp.log(ctx, "search canceled", "responses", delivered, "error", ctx.Err())
+			ssdp.publishSearch(ctx, EventSearchFinished, delivered, ctx.Err())
+			return ctx.Err()
+		case <-progress.tick():
+			progress.report()
+		case p := <-packets:
+			if p.transient {
+				ssdp.log(ctx, "transient read error", "error", p.err)
+				continue
+			}
+			if p.err != nil {
+				ssdp.log(ctx, "search failed", "error", p.err)
+				ssdp.publishSearch(ctx, EventSearchFinished, delivered, p.err)
+				return p.err
+			}
+
+			progress.packets++
+			ssdp.logWire(ctx, "packet received", p.data, "from", addrString(p.addr))
+			if !ssdp.includeSelf && isSelf(local, p.addr) {
+				continue
+			}
+			if ssdp.onLinkOnly && !isOnLink(prefixes, p.addr) {
+				progress.offLink++
+				progress.report()
+				continue
+			}
+
+			response, err := ParseSearchResponse(bytes.NewReader(p.data), p.addr)
+			if err != nil {
+				// Skip malformed responses so a single misbehaving device can't
+				// abort the whole search.
+				progress.parseErrors++
+				progress.report()
+				ssdp.log(ctx, "malformed response", "from", addrString(p.addr), "error", err)
+				if packetError != nil {
+					packetError(&PacketError{Addr: p.addr, Err: err})
+				}
+				continue
+			}
+			response.Findings = ssdp.validate(p.data)
+			response.Group = p.group
+			response.InterfaceIndex = p.ifIndex
+			response.LocalAddr = p.local
+			response.Received = ssdp.clock.Now()
+			response.RTT = response.Received.Sub(sent)
+			response.SearchST = search
+			raw = p.data
+			receive(response)
 		}
-		responses = append(responses, *response)
 	}
+}
 
-	return responses, nil
+// A single read from a searchReader.
+type packet struct {
+	data    []byte
+	addr    *net.UDPAddr
+	group   string
+	ifIndex int
+	local   *net.UDPAddr
+	err     error
+	// whether the error was transient and reading goes on
+	transient bool
+}
+
+// readPackets reads from the reader into packets in the background until the
+// returned stop function is called. Transient errors are passed on and
+// reading goes on, until too many of them follow each other.
+func readPackets(reader groupReader, packets chan<- packet) func() {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	_ = reader.SetReadDeadline(time.Time{})
+
+	go func() {
+		defer close(finished)
+		transient := 0
+		for {
+			buf := make([]byte, MaxMessageSize)
+			rlen, addr, ifIndex, local, err := readFrom(reader.searchReader, buf)
+
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if err != nil && isTransient(err) && transient < maxTransientErrors {
+				transient++
+				select {
+				case packets <- packet{err: err, transient: true}:
+				case <-done:
+					return
+				}
+				time.Sleep(transientErrorDelay)
+				continue
+			}
+			if err != nil {
+				select {
+				case packets <- packet{err: err}:
+				case <-done:
+				}
+				return
+			}
+			transient = 0
+
+			select {
+			case packets <- packet{data: buf[:rlen], addr: addr, group: reader.group, ifIndex: ifIndex, local: local}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	stop := func() {
+		close(done)
+		// Unblock the pending read
+		_ = reader.SetReadDeadline(time.Now())
+		<-finished
+	}
+
+	return stop
 }
 
-func parseSearchResponse(httpResponse io.Reader, responseAddr *net.UDPAddr) (*SearchResponse, error) {
-	reader := bufio.NewReader(httpResponse)
+// ParseSearchResponse parses a raw M-SEARCH response received from the given
+// address. Messages exceeding the size limits are rejected.
+func ParseSearchResponse(httpResponse io.Reader, responseAddr *net.UDPAddr) (*SearchResponse, error) {
+	reader, err := limitMessage(httpResponse)
+	if err != nil {
+		return nil, err
+	}
+
 	request := &http.Request{} // Needed for ReadResponse but doesn't have to be real
 	response, err := http.ReadResponse(reader, request)
 	if err != nil {
@@ -261,11 +803,17 @@ func parseSearchResponse(httpResponse io.Reader, responseAddr *net.UDPAddr) (*Se
 
 	res := &SearchResponse{}
 
+	res.Proto = response.Proto
+	res.StatusLine = response.Proto + " " + response.Status
 	res.Control = headers.Get("cache-control")
 	res.Server = headers.Get("server")
 	res.ST = headers.Get("st")
 	res.Ext = headers.Get("ext")
 	res.USN = headers.Get("usn")
+	res.NLS = parseNLS(headers)
+	res.SearchPort = parseSearchPort(headers.Get("searchport.upnp.org"))
+	res.BootID = parseBootID(headers.Get("bootid.upnp.org"))
+	res.ConfigID = parseConfigID(headers.Get("configid.upnp.org"))
 	res.ResponseAddr = responseAddr
 
 	if headers.Get("location") != "" {
@@ -273,6 +821,15 @@ func parseSearchResponse(httpResponse io.Reader, responseAddr *net.UDPAddr) (*Se
 		if err != nil {
 			return nil, err
 		}
+		res.Location = addZone(res.Location, responseAddr)
+	}
+
+	if secureLocation := headers.Get("securelocation.upnp.org"); secureLocation != "" {
+		res.SecureLocation, err = url.Parse(secureLocation)
+		if err != nil {
+			return nil, err
+		}
+		res.SecureLocation = addZone(res.SecureLocation, responseAddr)
 	}
 
 	date := headers.Get("date")
@@ -286,22 +843,140 @@ func parseSearchResponse(httpResponse io.Reader, responseAddr *net.UDPAddr) (*Se
 	return res, nil
 }
 
-func parseDescriptionXml(url url.URL) (*Device, error) {
-	response, err := http.Get(url.String())
+// ParseDescription decodes a UPnP device description document. At most
+// MaxDescriptionSize bytes are read from the reader.
+func ParseDescription(description io.Reader) (*Device, error) {
+	limited := &io.LimitedReader{R: description, N: MaxDescriptionSize + 1}
+	decoder := xml.NewDecoder(limited)
+	decoder.CharsetReader = charsetReader
+
+	device := &Device{}
+
+	err := decoder.Decode(device)
+
+	if limited.N <= 0 {
+		return nil, ErrDescriptionTooLarge
+	}
+
 	if err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
-
-	decoder := xml.NewDecoder(response.Body)
 
-	device := &Device{}
+	return device, nil
+}
 
-	err = decoder.Decode(device)
+// FetchDescription fetches and decodes the device description at the
+// location, e.g. one taken from a NOTIFY announcement.
+func (ssdp *SSDP) FetchDescription(location *url.URL) (*Device, error) {
+	return ssdp.FetchDescriptionContext(context.Background(), location)
+}
 
+// FetchDescriptionContext fetches like FetchDescription, giving up when the
+// context is done, retries and backoff included.
+func (ssdp *SSDP) FetchDescriptionContext(ctx context.Context, location *url.URL) (*Device, error) {
+	device, err := ssdp.fetchDescription(ctx, *location, Quirk{})
 	if err != nil {
 		return nil, err
 	}
-
+	if err := ssdp.postProcess(device, nil); err != nil {
+		return nil, err
+	}
 	return device, nil
 }
+
+// fetchDescription fetches the description from the rewritten location,
+// falling back to the alternative locations of the quirk and retrying
+// according to the retry policy.
+func (ssdp *SSDP) fetchDescription(ctx context.Context, location url.URL, quirk Quirk) (*Device, error) {
+	location = ssdp.rewriteLocation(location)
+	quirk = quirk.combine(ssdp.hostQuirks.of(location.Hostname()))
+	if quirk.NoKeepAlive {
+		ssdp.noKeepAlive.add(location.Hostname())
+	}
+	candidates := quirk.descriptionLocations(location)
+
+	for attempt := 1; ; attempt++ {
+		var attemptErr error
+
+		for _, candidate := range candidates {
+			release, err := ssdp.hostLimiter.acquire(ctx, candidate.Host)
+			if err != nil {
+				return nil, &FetchError{URL: candidate.String(), Attempts: attempt, Err: err}
+			}
+			device, retry, err := ssdp.parseDescriptionXml(ctx, candidate)
+			release()
+			if err == nil {
+				deviceLocation := candidate
+				device.Location = &deviceLocation
+				ssdp.learnQuirks(location.Hostname(), device)
+				if ssdp.strict {
+					device.Findings = ValidateDescription(device)
+				}
+				return device, nil
+			}
+			if !retry {
+				return nil, &FetchError{URL: candidate.String(), Attempts: attempt, Err: err}
+			}
+			if attemptErr == nil {
+				attemptErr = err
+			}
+		}
+
+		if attempt >= ssdp.retry.Attempts {
+			return nil, &FetchError{URL: location.String(), Attempts: attempt, Err: attemptErr}
+		}
+
+		select {
+		case <-ssdp.clock.After(ssdp.retry.delay(attempt)):
+		case <-ctx.Done():
+			return nil, &FetchError{URL: location.String(), Attempts: attempt, Err: ctx.Err()}
+		}
+	}
+}
+
+// parseDescriptionXml fetches and decodes the description at the url. The
+// returned bool reports whether a failed fetch is worth retrying.
+func (ssdp *SSDP) parseDescriptionXml(ctx context.Context, url url.URL) (*Device, bool, error) {
+	if err := ssdp.checkLocation(url); err != nil {
+		return nil, false, err
+	}
+
+	if ssdp.cache != nil {
+		if body, ok := ssdp.cache.open(url); ok {
+			defer body.Close()
+			device, err := ParseDescription(body)
+			if err != nil {
+				// A file left by an earlier cache may be broken
+				ssdp.cache.Remove(url)
+			}
+			return device, false, err
+		}
+	}
+
+	body, err := ssdp.activeFetcher().Fetch(ctx, url)
+	if err != nil {
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return nil, false, permanent.err
+		}
+		// Retrying is pointless once the context is done
+		return nil, ctx.Err() == nil, err
+	}
+	defer body.Close()
+
+	if ssdp.cache == nil {
+		device, err := ParseDescription(body)
+		return device, false, err
+	}
+
+	// Only descriptions that parse are cached
+	data, err := io.ReadAll(io.LimitReader(body, MaxDescriptionSize+1))
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	device, err := ParseDescription(bytes.NewReader(data))
+	if err == nil {
+		ssdp.cache.store(cacheKey(url), data)
+	}
+	return device, false, err
+}
@@ -0,0 +1,95 @@
+package ssdp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"golang.org/x/net/ipv4"
+)
+
+// The first file descriptor passed by systemd socket activation.
+const listenFDsStart = 3
+
+// ActivationConns returns the UDP sockets passed by systemd socket
+// activation, in the order of the ListenDatagram lines of the socket unit,
+// or none when the process wasn't socket activated. The variables
+// describing them are removed from the environment so child processes don't
+// inherit them.
+func ActivationConns() ([]*net.UDPConn, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	conns := make([]*net.UDPConn, 0, count)
+	for fd := listenFDsStart; fd < listenFDsStart+count; fd++ {
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		conn, err := net.FilePacketConn(file)
+		file.Close()
+		if err != nil {
+			closeAll(conns)
+			return nil, fmt.Errorf("inherited socket %d: %w", fd, err)
+		}
+		udpConn, ok := conn.(*net.UDPConn)
+		if !ok {
+			conn.Close()
+			closeAll(conns)
+			return nil, fmt.Errorf("inherited socket %d is not a UDP socket", fd)
+		}
+		conns = append(conns, udpConn)
+	}
+
+	return conns, nil
+}
+
+func closeAll(conns []*net.UDPConn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+type monitorConnOption struct {
+	conn *net.UDPConn
+}
+
+func (m monitorConnOption) apply(opts *monitorOptions) {
+	opts.conn = m.conn
+}
+
+// WithMonitorConn makes the monitor listen on a socket already bound to the
+// SSDP port, such as one of ActivationConns, instead of binding its own. The
+// monitor joins the multicast group on it and closes it when closed.
+func WithMonitorConn(conn *net.UDPConn) OptionMonitor {
+	return monitorConnOption{conn}
+}
+
+type advertiserConnOption struct {
+	conn *net.UDPConn
+}
+
+func (a advertiserConnOption) apply(opts *options) {
+	opts.advertiserConn = a.conn
+}
+
+// WithAdvertiserConn makes the advertiser of the client receive searches on
+// a socket already bound to the SSDP port, such as one of ActivationConns,
+// instead of binding its own. The advertiser joins the multicast group on it
+// and closes it when shut down, so it serves a single advertiser.
+func WithAdvertiserConn(conn *net.UDPConn) OptionSSDP {
+	return advertiserConnOption{conn}
+}
+
+// joinGroup joins the multicast group on a socket that was bound elsewhere.
+func joinGroup(conn *net.UDPConn, iface *net.Interface, group *net.UDPAddr) error {
+	return ipv4.NewPacketConn(conn).JoinGroup(iface, group)
+}
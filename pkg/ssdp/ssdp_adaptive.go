@@ -0,0 +1,86 @@
+package ssdp
+
+import (
+	"sync"
+	"time"
+)
+
+// The number of searches the adaptive window is learned from.
+const adaptiveHistory = 8
+
+type adaptiveTimeout struct {
+	floor time.Duration
+
+	mu sync.Mutex
+	// the time of the last response of recent searches
+	last []time.Duration
+}
+
+type adaptiveTimeoutOption time.Duration
+
+func (a adaptiveTimeoutOption) apply(opts *options) {
+	opts.adaptive = &adaptiveTimeout{floor: time.Duration(a)}
+}
+
+// WithAdaptiveTimeout shortens the search window to half again the latest
+// last response of recent searches, but not below the floor. On a stable
+// network searches then end soon after the slowest device answered. A search
+// whose last response arrives late in the window makes the next searches use
+// the full timeout again while the history is rebuilt.
+func WithAdaptiveTimeout(floor time.Duration) OptionSSDP {
+	return adaptiveTimeoutOption(floor)
+}
+
+// searchWindow returns how long to wait for responses.
+func (opts *options) searchWindow() time.Duration {
+	if opts.adaptive == nil {
+		return opts.timeout
+	}
+	return opts.adaptive.window(opts.timeout)
+}
+
+func (a *adaptiveTimeout) window(timeout time.Duration) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.last) < adaptiveHistory {
+		return timeout
+	}
+
+	var slowest time.Duration
+	for _, last := range a.last {
+		if last > slowest {
+			slowest = last
+		}
+	}
+
+	window := slowest * 3 / 2
+	if window < a.floor {
+		window = a.floor
+	}
+	if window > timeout {
+		window = timeout
+	}
+	return window
+}
+
+// record adds the time of the last response of a search with the window. A
+// search without responses tells nothing about the latencies.
+func (a *adaptiveTimeout) record(last time.Duration, window time.Duration) {
+	if a == nil || last <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if last > window*4/5 {
+		a.last = nil
+		return
+	}
+
+	a.last = append(a.last, last)
+	if len(a.last) > adaptiveHistory {
+		a.last = a.last[1:]
+	}
+}
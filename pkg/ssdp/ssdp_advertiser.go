@@ -0,0 +1,438 @@
+package ssdp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often an Advertiser checks whether the addresses of the interfaces
+// changed, announcing the new locations when they did.
+const addrCheckInterval = 10 * time.Second
+
+// How long Close waits for the requests being served.
+const closeTimeout = 5 * time.Second
+
+// A HostedDevice is a root device served and announced by an Advertiser.
+type HostedDevice struct {
+	// The unique device name, e.g. "uuid:..."
+	UDN string
+	// The device type, e.g. "urn:schemas-upnp-org:device:MediaServer:1"
+	DeviceType string
+	// The device description served at the location
+	Description []byte
+	// The SERVER header, "OS/version UPnP/1.0 product/version"
+	Server string
+	// How long the announcements are valid, the max-age of the
+	// PowerProfile when zero
+	MaxAge time.Duration
+}
+
+// announcements returns the announcements of a root device: one for the
+// root device, one for its UDN and one for its type.
+func (d HostedDevice) announcements(location string) []AnnounceConfig {
+	config := AnnounceConfig{Location: location, MaxAge: d.MaxAge, Server: d.Server}
+
+	root, udn, deviceType := config, config, config
+	root.NT, root.USN = RootDevice, d.UDN+"::"+RootDevice
+	udn.NT, udn.USN = d.UDN, d.UDN
+	deviceType.NT, deviceType.USN = d.DeviceType, d.UDN+"::"+d.DeviceType
+	return []AnnounceConfig{root, udn, deviceType}
+}
+
+// An Advertiser serves the descriptions of root devices from an embedded
+// HTTP server and announces them until closed, like a bridge exposing one
+// device per bridged physical device. The server listens on a free port and
+// the location announced on each interface carries the address of that
+// interface, see LocationIfAddr. The announcements are repeated before they
+// expire and whenever the addresses of the interfaces change, see
+// WithPowerProfile to tune them for battery powered devices. Searches sent
+// to the multicast group are answered after a random delay of up to their
+// MX.
+type Advertiser struct {
+	ssdp     *SSDP
+	listener net.Listener
+	server   *http.Server
+	// receives the searches sent to the multicast group
+	groupConn *net.UDPConn
+	// answers the multicast searches
+	replyConn *net.UDPConn
+	// answers unicast searches, nil unless the PowerProfile asks to
+	searchConn *net.UDPConn
+	// the network location signature of the announcements and responses
+	nls string
+	// the goroutines reading searches and the delayed responses
+	searching sync.WaitGroup
+	pending   sync.WaitGroup
+
+	mu       sync.Mutex
+	devices  map[string]HostedDevice
+	handlers map[string]http.Handler
+	closed   bool
+	// when each USN was last announced and when the devices are renewed,
+	// see Schedule
+	sent    map[string]time.Time
+	renewAt time.Time
+
+	changed  chan struct{}
+	done     chan struct{}
+	finished chan struct{}
+}
+
+// NewAdvertiser starts serving and announcing the devices. More can be added
+// with Add.
+func (ssdp *SSDP) NewAdvertiser(devices ...HostedDevice) (*Advertiser, error) {
+	for _, device := range devices {
+		if err := device.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	var conns []io.Closer
+	fail := func(err error) (*Advertiser, error) {
+		listener.Close()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return nil, err
+	}
+
+	groupConn, err := ssdp.listenGroup()
+	if err != nil {
+		return fail(err)
+	}
+	conns = append(conns, groupConn)
+	replyConn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return fail(err)
+	}
+	conns = append(conns, replyConn)
+	var searchConn *net.UDPConn
+	if ssdp.power.UnicastSearches {
+		if searchConn, err = ssdp.power.listenSearchPort(); err != nil {
+			return fail(err)
+		}
+	}
+
+	a := &Advertiser{
+		ssdp:       ssdp,
+		listener:   listener,
+		groupConn:  groupConn,
+		replyConn:  replyConn,
+		searchConn: searchConn,
+		nls:        newNLS(),
+		devices:    make(map[string]HostedDevice),
+		handlers:   make(map[string]http.Handler),
+		sent:       make(map[string]time.Time),
+		changed:    make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		finished:   make(chan struct{}),
+	}
+	for _, device := range devices {
+		a.devices[device.UDN] = a.withDefaults(device)
+	}
+	a.server = &http.Server{Handler: http.HandlerFunc(a.serveHTTP)}
+	go a.server.Serve(listener)
+	a.searching.Add(1)
+	go a.serveSearches(groupConn, true)
+	if searchConn != nil {
+		a.searching.Add(1)
+		go a.serveSearches(searchConn, false)
+	}
+	go a.run()
+
+	return a, nil
+}
+
+func (d HostedDevice) validate() error {
+	if d.UDN == "" || d.DeviceType == "" {
+		return fmt.Errorf("advertising a device needs a UDN and a device type")
+	}
+	return nil
+}
+
+func (a *Advertiser) withDefaults(device HostedDevice) HostedDevice {
+	if device.MaxAge <= 0 {
+		device.MaxAge = a.ssdp.power.maxAge()
+	}
+	return device
+}
+
+// Add starts serving and announcing the device, replacing the one with the
+// same UDN.
+func (a *Advertiser) Add(device HostedDevice) error {
+	if err := device.validate(); err != nil {
+		return err
+	}
+	device = a.withDefaults(device)
+
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return fmt.Errorf("advertiser closed")
+	}
+	a.devices[device.UDN] = device
+	a.mu.Unlock()
+
+	a.announce(device)
+	// Its max-age may shorten the renewal interval
+	select {
+	case a.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Remove stops serving the device and revokes its announcements. It reports
+// whether the device was advertised.
+func (a *Advertiser) Remove(udn string) bool {
+	a.mu.Lock()
+	device, ok := a.devices[udn]
+	delete(a.devices, udn)
+	for _, config := range device.announcements("") {
+		delete(a.sent, config.USN)
+	}
+	a.mu.Unlock()
+
+	if ok {
+		a.revoke(context.Background(), device)
+	}
+	return ok
+}
+
+// Handle serves the path with the handler, e.g. the control or event URL of
+// a service of a device, see gena.Publisher.
+func (a *Advertiser) Handle(path string, handler http.Handler) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.handlers[path] = handler
+}
+
+// URL returns the URL of the path on the advertiser's server, with
+// LocationIfAddr in place of the address of each interface.
+func (a *Advertiser) URL(path string) string {
+	return fmt.Sprintf("http://%s:%d%s", LocationIfAddr, a.Port(), path)
+}
+
+// Devices returns the advertised devices.
+func (a *Advertiser) Devices() []HostedDevice {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	devices := make([]HostedDevice, 0, len(a.devices))
+	for _, device := range a.devices {
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].UDN < devices[j].UDN
+	})
+	return devices
+}
+
+// Port returns the port the descriptions are served on.
+func (a *Advertiser) Port() int {
+	return a.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Location returns the announced location of the description of the device,
+// with LocationIfAddr in place of the address of each interface.
+func (a *Advertiser) Location(udn string) string {
+	return a.URL(descriptionPath(udn))
+}
+
+func descriptionPath(udn string) string {
+	return "/" + strings.TrimPrefix(udn, "uuid:") + "/description.xml"
+}
+
+func (a *Advertiser) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	handler, ok := a.handlers[r.URL.Path]
+	a.mu.Unlock()
+
+	if ok {
+		handler.ServeHTTP(w, r)
+		return
+	}
+	a.serveDescription(w, r)
+}
+
+func (a *Advertiser) serveDescription(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	var description []byte
+	found := false
+	for udn, device := range a.devices {
+		if r.URL.Path == descriptionPath(udn) {
+			description, found = device.Description, true
+			break
+		}
+	}
+	a.mu.Unlock()
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Write(description)
+}
+
+// run announces the devices until the advertiser is closed.
+func (a *Advertiser) run() {
+	defer close(a.finished)
+
+	// Checking the addresses wakes a sleeping device too
+	checkInterval := addrCheckInterval
+	if a.ssdp.power.WakeInterval > checkInterval {
+		checkInterval = a.ssdp.power.WakeInterval
+	}
+
+	addrs := a.ssdp.announceAddrs()
+	a.announceAll()
+	renew := a.scheduleRenewal()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-renew:
+			a.announceAll()
+		case <-a.changed:
+		case <-a.ssdp.clock.After(checkInterval):
+			current := a.ssdp.announceAddrs()
+			if current == addrs {
+				continue
+			}
+			addrs = current
+			a.announceAll()
+		}
+		renew = a.scheduleRenewal()
+	}
+}
+
+// scheduleRenewal returns when to announce the devices again, in a wake
+// window of the PowerProfile.
+func (a *Advertiser) scheduleRenewal() <-chan time.Time {
+	now := a.ssdp.clock.Now()
+	interval := a.renewInterval()
+	renewAt := a.ssdp.power.align(now.Add(interval), now, now.Add(2*interval))
+
+	a.mu.Lock()
+	a.renewAt = renewAt
+	a.mu.Unlock()
+
+	return a.ssdp.clock.After(renewAt.Sub(now))
+}
+
+// renewInterval returns half the shortest max-age of the devices.
+func (a *Advertiser) renewInterval() time.Duration {
+	shortest := a.ssdp.power.maxAge()
+	for i, device := range a.Devices() {
+		if i == 0 || device.MaxAge < shortest {
+			shortest = device.MaxAge
+		}
+	}
+	return shortest / 2
+}
+
+func (a *Advertiser) announceAll() {
+	for _, device := range a.Devices() {
+		a.announce(device)
+	}
+}
+
+func (a *Advertiser) announce(device HostedDevice) {
+	ctx := context.Background()
+	for _, config := range device.announcements(a.Location(device.UDN)) {
+		config.SearchPort = a.SearchPort()
+		config.NLS = a.nls
+		if err := a.ssdp.Announce(ctx, config); err != nil {
+			a.ssdp.log(ctx, "announcement failed", "usn", config.USN, "error", err)
+			continue
+		}
+		a.mu.Lock()
+		a.sent[config.USN] = a.ssdp.clock.Now()
+		a.mu.Unlock()
+	}
+}
+
+func (a *Advertiser) revoke(ctx context.Context, device HostedDevice) {
+	for _, config := range device.announcements("") {
+		config.NLS = a.nls
+		if err := a.ssdp.Revoke(ctx, config); err != nil {
+			a.ssdp.log(ctx, "revocation failed", "usn", config.USN, "error", err)
+		}
+	}
+}
+
+// announceAddrs returns the addresses of the interfaces announced on, for
+// spotting changes.
+func (ssdp *SSDP) announceAddrs() string {
+	ifaces, err := ssdp.announceInterfaces()
+	if err != nil {
+		return ""
+	}
+
+	var addrs []string
+	for _, iface := range ifaces {
+		if ip, err := InterfaceIP(iface.Name); err == nil {
+			addrs = append(addrs, iface.Name+"="+ip.String())
+		}
+	}
+	sort.Strings(addrs)
+	return strings.Join(addrs, ",")
+}
+
+// Shutdown stops the advertiser gracefully, like http.Server.Shutdown: it
+// stops answering searches, sends the pending responses right away, revokes
+// the announcements of the devices and waits for the requests being served.
+// When the context ends first the remaining connections are closed and its
+// error is returned.
+func (a *Advertiser) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.mu.Unlock()
+
+	a.groupConn.Close()
+	if a.searchConn != nil {
+		a.searchConn.Close()
+	}
+	a.searching.Wait()
+	close(a.done)
+	a.pending.Wait()
+	<-a.finished
+	a.replyConn.Close()
+
+	for _, device := range a.Devices() {
+		a.revoke(ctx, device)
+	}
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		a.server.Close()
+		return err
+	}
+	return nil
+}
+
+// Close shuts the advertiser down, giving the requests being served
+// closeTimeout to finish.
+func (a *Advertiser) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	return a.Shutdown(ctx)
+}
@@ -0,0 +1,197 @@
+package ssdp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// How often each announcement is sent, as UDP may drop any single copy.
+const announceCopies = 2
+
+// LocationIfAddr is replaced by the address of the interface a location is
+// sent on, e.g. "http://{ifaddr}:8080/description.xml", so hosts on several
+// networks announce the address reachable from each.
+const LocationIfAddr = "{ifaddr}"
+
+// ExpandLocation replaces LocationIfAddr in the location with the address,
+// for responders answering on the interface with that address.
+func ExpandLocation(location string, ifaddr net.IP) string {
+	host := ifaddr.String()
+	if ifaddr.To4() == nil {
+		host = "[" + host + "]"
+	}
+	return strings.ReplaceAll(location, LocationIfAddr, host)
+}
+
+// AnnounceConfig describes a service announced with Announce.
+type AnnounceConfig struct {
+	// The notification type, e.g. "urn:example-com:service:Thing:1"
+	NT string
+	// The unique service name, e.g. "uuid:...::urn:example-com:service:Thing:1"
+	USN string
+	// Where the service can be reached, not needed by Revoke. It may
+	// contain LocationIfAddr to announce the address of each interface
+	// on that interface.
+	Location string
+	// How long the announcement is valid, 30 minutes when zero
+	MaxAge time.Duration
+	// The SERVER header, "OS/version UPnP/1.0 product/version"
+	Server string
+	// The BOOTID.UPNP.ORG and CONFIGID.UPNP.ORG headers sent from UDA 1.1
+	BootID   int
+	ConfigID int
+	// The SEARCHPORT.UPNP.ORG header sent from UDA 1.1 when not zero
+	SearchPort int
+	// The network location signature sent in 01-NLS when not empty
+	NLS string
+}
+
+// Announce sends an ssdp:alive NOTIFY for the service to the multicast group,
+// for services that only want to be discoverable on the LAN. It has to be
+// repeated before MaxAge passes for the service to stay known.
+func (ssdp *SSDP) Announce(ctx context.Context, config AnnounceConfig) error {
+	if config.Location == "" {
+		return fmt.Errorf("announcing %s without a location", config.USN)
+	}
+	return ssdp.sendNotify(ctx, config, NTSAlive)
+}
+
+// Revoke sends an ssdp:byebye NOTIFY for the service, telling control points
+// it is gone.
+func (ssdp *SSDP) Revoke(ctx context.Context, config AnnounceConfig) error {
+	return ssdp.sendNotify(ctx, config, NTSByeBye)
+}
+
+func (ssdp *SSDP) sendNotify(ctx context.Context, config AnnounceConfig, nts string) error {
+	if config.NT == "" || config.USN == "" {
+		return fmt.Errorf("announcements need an NT and a USN")
+	}
+
+	group, err := ssdp.resolveUDPAddr(ssdp.broadcastIp, ssdp.port)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+
+	if nts == NTSAlive && strings.Contains(config.Location, LocationIfAddr) {
+		ifaces, err := ssdp.announceInterfaces()
+		if err != nil {
+			return err
+		}
+		location := config.Location
+		for _, iface := range ifaces {
+			ip, err := InterfaceIP(iface.Name)
+			if err != nil {
+				continue
+			}
+			config.Location = ExpandLocation(location, ip)
+			if err := writeNotify(ctx, conn, &iface, buildNotify(config, nts, group, ssdp.udaVersion), group); err != nil {
+				return err
+			}
+		}
+	} else {
+		var iface *net.Interface
+		if ssdp.iface != "" {
+			if iface, err = net.InterfaceByName(ssdp.iface); err != nil {
+				return err
+			}
+		}
+		if err := writeNotify(ctx, conn, iface, buildNotify(config, nts, group, ssdp.udaVersion), group); err != nil {
+			return err
+		}
+	}
+
+	ssdp.events.Publish(Event{Type: EventAnnouncementSent, Time: ssdp.clock.Now(), ST: config.NT, USN: config.USN})
+	return nil
+}
+
+// writeNotify sends the copies of the message through the interface, or the
+// default one when nil.
+func writeNotify(ctx context.Context, conn *net.UDPConn, iface *net.Interface, message []byte, group *net.UDPAddr) error {
+	if iface != nil {
+		if err := ipv4.NewPacketConn(conn).SetMulticastInterface(iface); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < announceCopies; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := conn.WriteTo(message, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// announceInterfaces returns the interfaces to announce a templated location
+// on: the one of WithInterface, or else every interface that is up and
+// supports multicast.
+func (ssdp *SSDP) announceInterfaces() ([]net.Interface, error) {
+	if ssdp.iface != "" {
+		iface, err := net.InterfaceByName(ssdp.iface)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Interface{*iface}, nil
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	up := ifaces[:0]
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagMulticast != 0 {
+			up = append(up, iface)
+		}
+	}
+	return up, nil
+}
+
+func buildNotify(config AnnounceConfig, nts string, group *net.UDPAddr, version UDAVersion) []byte {
+	var b strings.Builder
+
+	b.WriteString("NOTIFY * HTTP/1.1\r\n")
+	fmt.Fprintf(&b, "HOST: %s\r\n", group)
+	if nts == NTSAlive {
+		maxAge := config.MaxAge
+		if maxAge <= 0 {
+			maxAge = 30 * time.Minute
+		}
+		fmt.Fprintf(&b, "CACHE-CONTROL: max-age=%d\r\n", int(maxAge/time.Second))
+		fmt.Fprintf(&b, "LOCATION: %s\r\n", config.Location)
+		if config.Server != "" {
+			fmt.Fprintf(&b, "SERVER: %s\r\n", config.Server)
+		}
+	}
+	fmt.Fprintf(&b, "NT: %s\r\n", config.NT)
+	fmt.Fprintf(&b, "NTS: %s\r\n", nts)
+	fmt.Fprintf(&b, "USN: %s\r\n", config.USN)
+	writeNLS(&b, config.NLS)
+	if version >= UDA11 {
+		fmt.Fprintf(&b, "BOOTID.UPNP.ORG: %d\r\n", config.BootID)
+		fmt.Fprintf(&b, "CONFIGID.UPNP.ORG: %d\r\n", config.ConfigID)
+		if config.SearchPort != 0 && nts == NTSAlive {
+			fmt.Fprintf(&b, "SEARCHPORT.UPNP.ORG: %d\r\n", config.SearchPort)
+		}
+	}
+	b.WriteString("\r\n")
+
+	return []byte(b.String())
+}
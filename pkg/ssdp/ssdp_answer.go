@@ -0,0 +1,136 @@
+package ssdp
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenGroup joins the multicast group the searches of control points are
+// sent to, on the interfaces announced on, with the socket of
+// WithAdvertiserConn when set.
+func (ssdp *SSDP) listenGroup() (*net.UDPConn, error) {
+	group, err := ssdp.resolveUDPAddr(ssdp.broadcastIp, ssdp.port)
+	if err != nil {
+		return nil, err
+	}
+
+	var iface *net.Interface
+	if ssdp.iface != "" {
+		if iface, err = net.InterfaceByName(ssdp.iface); err != nil {
+			return nil, err
+		}
+	}
+	conn := ssdp.advertiserConn
+	if conn != nil {
+		if err := joinGroup(conn, iface, group); err != nil {
+			return nil, err
+		}
+	} else if conn, err = net.ListenMulticastUDP("udp4", iface, group); err != nil {
+		return nil, err
+	}
+
+	if iface == nil {
+		// Announcements go out on every interface, searches may come in on
+		// any of them. Joining the default one again fails, which is fine.
+		ifaces, _ := ssdp.announceInterfaces()
+		for i := range ifaces {
+			_ = joinGroup(conn, &ifaces[i], group)
+		}
+	}
+	return conn, nil
+}
+
+// serveSearches answers the searches received on the socket until it is
+// closed. Multicast searches are answered after a random delay of up to
+// their MX, unicast ones right away.
+func (a *Advertiser) serveSearches(conn *net.UDPConn, multicast bool) {
+	defer a.searching.Done()
+
+	buf := make([]byte, MaxMessageSize)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buf[:n])))
+		if err != nil || request.Method != "M-SEARCH" || strings.Trim(request.Header.Get("MAN"), `"`) != "ssdp:discover" {
+			continue
+		}
+		st := request.Header.Get("ST")
+		if !multicast {
+			a.answer(conn, addr, st)
+			continue
+		}
+
+		// Multicast searches without an MX are invalid. An MX of 0, sent by
+		// clients searching for less than a second, is answered right away.
+		mx, err := strconv.Atoi(strings.TrimSpace(request.Header.Get("MX")))
+		if err != nil || mx < 0 {
+			continue
+		}
+		if mx > maxMX {
+			mx = maxMX
+		}
+		var delay time.Duration
+		if mx > 0 {
+			delay = time.Duration(rand.Int63n(int64(time.Duration(mx) * time.Second)))
+		}
+		a.answerLater(addr, st, delay)
+	}
+}
+
+// answerLater answers a multicast search after the delay, or when the
+// advertiser shuts down if that comes first.
+func (a *Advertiser) answerLater(addr *net.UDPAddr, st string, delay time.Duration) {
+	a.pending.Add(1)
+	go func() {
+		defer a.pending.Done()
+
+		select {
+		case <-a.ssdp.clock.After(delay):
+		case <-a.done:
+		}
+		a.answer(a.replyConn, addr, st)
+	}()
+}
+
+// answer sends the responses of the devices answering the search target.
+func (a *Advertiser) answer(conn *net.UDPConn, addr *net.UDPAddr, st string) {
+	// Dialing UDP sends nothing, it only picks the local address
+	route, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		return
+	}
+	ip := route.LocalAddr().(*net.UDPAddr).IP
+	route.Close()
+
+	for _, device := range a.Devices() {
+		for _, config := range device.announcements(ExpandLocation(a.Location(device.UDN), ip)) {
+			if !MatchesST(config.NT, st) {
+				continue
+			}
+			responseST := st
+			if st == ALL.String() {
+				responseST = config.NT
+			}
+			conn.WriteToUDP(BuildSearchResponse(SearchResponseConfig{
+				ST:         responseST,
+				USN:        config.USN,
+				Location:   config.Location,
+				MaxAge:     config.MaxAge,
+				Server:     config.Server,
+				Date:       a.ssdp.clock.Now(),
+				SearchPort: a.SearchPort(),
+				NLS:        a.nls,
+				Version:    a.ssdp.udaVersion,
+			}), addr)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package ssdp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseBootID returns the value of a BOOTID.UPNP.ORG or NEXTBOOTID.UPNP.ORG
+// header, or zero when it is missing or not the non-negative 31 bit integer
+// UDA 1.1 requires.
+func parseBootID(value string) int {
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 31)
+	if err != nil {
+		return 0
+	}
+	return int(id)
+}
+
+type registryCacheOption struct {
+	cache *DescriptionCache
+}
+
+func (r registryCacheOption) apply(opts *registryOptions) {
+	opts.cache = r.cache
+}
+
+// WithRegistryCache removes the cached description of a device from the
+// cache when the registry sees it reboot, see EventDeviceRebooted, or
+// announce another CONFIGID.UPNP.ORG.
+func WithRegistryCache(cache *DescriptionCache) OptionRegistry {
+	return registryCacheOption{cache}
+}
+
+type rebootHandlerOption func(udn string)
+
+func (r rebootHandlerOption) apply(opts *registryOptions) {
+	opts.onReboot = r
+}
+
+// WithRebootHandler calls the handler on its own goroutine with the UDN of
+// each device the registry sees reboot, e.g. to subscribe to its events
+// again, see gena.Subscriber.ResubscribeOnReboot.
+func WithRebootHandler(handler func(udn string)) OptionRegistry {
+	return rebootHandlerOption(handler)
+}
+
+// rebooted records the boot ID of the entry and reports whether it increased,
+// meaning the device rebooted and forgot its state. The description of a
+// rebooted device is dropped, along with its cached copy. The caller must
+// hold the lock.
+func (r *Registry) rebooted(entry *RegistryEntry, bootID int) bool {
+	if bootID == 0 {
+		return false
+	}
+	previous := entry.BootID
+	entry.BootID = bootID
+	if previous == 0 || bootID <= previous {
+		return false
+	}
+
+	entry.Device = nil
+	if r.opts.cache != nil && entry.Location != nil {
+		r.opts.cache.Remove(*entry.Location)
+	}
+	return true
+}
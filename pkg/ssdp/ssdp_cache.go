@@ -0,0 +1,243 @@
+package ssdp
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The suffix of the files of a DescriptionCache.
+const cacheSuffix = ".xml"
+
+// A DescriptionCache keeps fetched descriptions on disk, evicting the least
+// recently used once the files exceed the size budget. Only the index is
+// kept in memory, so fleets of thousands of devices can be cached without
+// holding their descriptions. The cache is keyed by location. Searches
+// refetch a description when the device announces another CONFIGID.UPNP.ORG,
+// other descriptions that change behind an unchanged location must be
+// removed with Remove.
+type DescriptionCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+	// The CONFIGID announced for each location
+	configIDs map[string]int
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// NewDescriptionCache returns a cache storing its files in dir, creating it
+// when missing. The files of an earlier cache in dir are reused, the oldest
+// considered the least recently used.
+func NewDescriptionCache(dir string, maxBytes int64) (*DescriptionCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	c := &DescriptionCache{
+		dir:       dir,
+		maxBytes:  maxBytes,
+		entries:   make(map[string]*list.Element),
+		lru:       list.New(),
+		configIDs: make(map[string]int),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []os.FileInfo
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), cacheSuffix) {
+			continue
+		}
+		if info, err := file.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		key := strings.TrimSuffix(info.Name(), cacheSuffix)
+		c.entries[key] = c.lru.PushBack(&cacheEntry{key: key, size: info.Size()})
+		c.size += info.Size()
+	}
+	c.evict()
+
+	return c, nil
+}
+
+type descriptionCacheOption struct {
+	cache *DescriptionCache
+}
+
+func (d descriptionCacheOption) apply(opts *options) {
+	opts.cache = d.cache
+}
+
+// WithDescriptionCache serves descriptions from the cache, fetching and
+// storing those missing or, during searches, those of devices announcing
+// another CONFIGID.UPNP.ORG. The cache can be shared between several SSDP
+// clients.
+func WithDescriptionCache(cache *DescriptionCache) OptionSSDP {
+	return descriptionCacheOption{cache}
+}
+
+// Len returns the number of cached descriptions.
+func (c *DescriptionCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// Size returns the total size of the cached descriptions in bytes.
+func (c *DescriptionCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+// Remove drops the description of the location, e.g. after the device
+// announced a new CONFIGID.
+func (c *DescriptionCache) Remove(location url.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[cacheKey(location)]; ok {
+		c.remove(element)
+	}
+}
+
+func cacheKey(location url.URL) string {
+	sum := sha256.Sum256([]byte(location.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *DescriptionCache) path(key string) string {
+	return filepath.Join(c.dir, key+cacheSuffix)
+}
+
+// open returns the cached description of the location, if any.
+func (c *DescriptionCache) open(location url.URL) (io.ReadCloser, bool) {
+	key := cacheKey(location)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	file, err := os.Open(c.path(key))
+	if err != nil {
+		// Removed behind our back, fetch it again
+		c.remove(element)
+		return nil, false
+	}
+	// Keep the order for a cache reusing the files
+	now := time.Now()
+	os.Chtimes(c.path(key), now, now)
+	return file, true
+}
+
+// fetch returns the cached description of the location, or fetches and
+// stores it with next.
+func (c *DescriptionCache) fetch(ctx context.Context, location url.URL, next Fetcher) (io.ReadCloser, error) {
+	if file, ok := c.open(location); ok {
+		return file, nil
+	}
+
+	body, err := next.Fetch(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, MaxDescriptionSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) <= MaxDescriptionSize {
+		c.store(cacheKey(location), data)
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// store writes the description, evicting others to stay within budget.
+func (c *DescriptionCache) store(key string, data []byte) {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return
+	}
+
+	temp, err := os.CreateTemp(c.dir, "fetch-*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		c.size -= entry.size
+		entry.size = size
+		c.lru.MoveToFront(element)
+	} else {
+		c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: size})
+	}
+	c.size += size
+	c.evict()
+}
+
+// evict removes the least recently used descriptions until the cache is
+// within budget.
+func (c *DescriptionCache) evict() {
+	for c.size > c.maxBytes && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *DescriptionCache) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	c.lru.Remove(element)
+	delete(c.entries, entry.key)
+	delete(c.configIDs, entry.key)
+	c.size -= entry.size
+	os.Remove(c.path(entry.key))
+}
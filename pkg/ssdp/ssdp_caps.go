@@ -0,0 +1,55 @@
+package ssdp
+
+type maxResponsesOption int
+
+func (m maxResponsesOption) apply(opts *options) {
+	opts.maxResponses = int(m)
+}
+
+// WithMaxResponses caps the number of responses retained per search, so a
+// network segment flooding replies cannot exhaust memory. Further responses
+// are dropped and counted in Progress.Overflow. Zero means no cap.
+func WithMaxResponses(max int) OptionSSDP {
+	return maxResponsesOption(max)
+}
+
+type maxTrackedOption int
+
+func (m maxTrackedOption) apply(opts *registryOptions) {
+	opts.maxTracked = int(m)
+}
+
+// WithMaxTracked caps the number of devices in the registry. Announcements
+// and responses of further devices are dropped and counted by Overflow, while
+// known devices keep being updated. Zero means no cap. Monitors take
+// WithMonitorMaxTracked.
+func WithMaxTracked(max int) OptionRegistry {
+	return maxTrackedOption(max)
+}
+
+type monitorMaxTrackedOption int
+
+func (m monitorMaxTrackedOption) apply(opts *monitorOptions) {
+	opts.maxTracked = int(m)
+}
+
+// WithMonitorMaxTracked caps the number of NT and USN pairs the monitor
+// remembers in its coalescing window and diagnostics, so a network segment
+// flooding announcements cannot exhaust memory. The announcements of further
+// pairs are still delivered, without coalescing, and counted in
+// MonitorStats.Overflow. Zero means the default cap of the diagnostics and
+// none on coalescing.
+func WithMonitorMaxTracked(max int) OptionMonitor {
+	return monitorMaxTrackedOption(max)
+}
+
+// room reports whether a set of the size has room for another pair, noting
+// an overflow of the notification being read when it has not. It is only
+// called from the read loop.
+func (m *Monitor) room(size int, max int) bool {
+	if max <= 0 || size < max {
+		return true
+	}
+	m.untracked = true
+	return false
+}
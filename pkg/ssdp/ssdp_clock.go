@@ -0,0 +1,35 @@
+package ssdp
+
+import (
+	"time"
+)
+
+// Clock is the time source used for search windows, deadlines and expiry
+// calculations. It can be replaced with a fake implementation in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type clockOption struct {
+	clock Clock
+}
+
+func (c clockOption) apply(opts *options) {
+	opts.clock = c.clock
+}
+
+// WithClock replaces the real time source.
+func WithClock(clock Clock) OptionSSDP {
+	return clockOption{clock}
+}
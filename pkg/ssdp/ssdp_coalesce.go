@@ -0,0 +1,69 @@
+package ssdp
+
+import (
+	"strconv"
+	"time"
+)
+
+type coalesceOption time.Duration
+
+func (c coalesceOption) apply(opts *monitorOptions) {
+	opts.coalesce = time.Duration(c)
+}
+
+// WithCoalescing delivers only the first of the identical alive
+// announcements received within the window. Devices announce every NT and
+// USN pair, often several times over for lack of delivery guarantees, so
+// most notifications repeat what is already known. Announcements changing
+// the location, boot or config ID of a pair are always delivered, as are
+// byebyes and updates. The suppressed notifications are counted in
+// MonitorStats.Coalesced.
+func WithCoalescing(window time.Duration) OptionMonitor {
+	return coalesceOption(window)
+}
+
+// coalesced is the last delivered alive announcement of an NT and USN pair.
+type coalesced struct {
+	delivered time.Time
+	// what has to change for an announcement to be delivered again
+	state string
+}
+
+// coalesce reports whether the notification repeats an alive announcement
+// delivered within the window. It is only called from the read loop.
+func (m *Monitor) coalesce(notify Notify) bool {
+	if m.opts.coalesce <= 0 {
+		return false
+	}
+
+	now := m.clock.Now()
+	if now.Sub(m.pruned) > m.opts.coalesce {
+		for key, last := range m.recent {
+			if now.Sub(last.delivered) >= m.opts.coalesce {
+				delete(m.recent, key)
+			}
+		}
+		m.pruned = now
+	}
+
+	key := notify.NT + " " + notify.USN
+	if notify.NTS != NTSAlive {
+		delete(m.recent, key)
+		return false
+	}
+
+	location := ""
+	if notify.Location != nil {
+		location = notify.Location.String()
+	}
+	state := location + " " + strconv.Itoa(notify.BootID) + " " + strconv.Itoa(notify.ConfigID)
+
+	last, ok := m.recent[key]
+	if ok && last.state == state && now.Sub(last.delivered) < m.opts.coalesce {
+		return true
+	}
+	if ok || m.room(len(m.recent), m.opts.maxTracked) {
+		m.recent[key] = coalesced{delivered: now, state: state}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package ssdp
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// parseConfigID returns the value of a CONFIGID.UPNP.ORG header, or zero when
+// it is missing or outside the range UDA 1.1 allows (0-16777215).
+func parseConfigID(value string) int {
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 24)
+	if err != nil {
+		return 0
+	}
+	return int(id)
+}
+
+// checkConfigID records the CONFIGID announced for the location, removing
+// the cached description when it differs from the one it was cached under.
+// Descriptions cached without a known CONFIGID are fetched once more.
+func (c *DescriptionCache) checkConfigID(location url.URL, configID int) {
+	if configID == 0 {
+		return
+	}
+	key := cacheKey(location)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok && c.configIDs[key] != configID {
+		c.remove(element)
+	}
+	c.configIDs[key] = configID
+}
+
+// reconfigured records the CONFIGID of the entry and reports whether it
+// changed, meaning the description of the device changed. Its description
+// is dropped, along with its cached copy. The caller must hold the lock.
+func (r *Registry) reconfigured(entry *RegistryEntry, configID int) bool {
+	if configID == 0 {
+		return false
+	}
+	previous := entry.ConfigID
+	entry.ConfigID = configID
+	if previous == 0 || configID == previous {
+		return false
+	}
+
+	entry.Device = nil
+	if r.opts.cache != nil && entry.Location != nil {
+		r.opts.cache.Remove(*entry.Location)
+	}
+	return true
+}
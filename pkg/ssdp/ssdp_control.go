@@ -0,0 +1,51 @@
+package ssdp
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+// controlReader reads packets along with the interface and local address
+// they were received on.
+type controlReader struct {
+	*net.UDPConn
+	packetConn *ipv4.PacketConn
+}
+
+// newControlReader enables the control messages on the socket, falling back
+// to plain reads where the platform does not support them.
+func newControlReader(conn *net.UDPConn) searchReader {
+	packetConn := ipv4.NewPacketConn(conn)
+	if err := packetConn.SetControlMessage(ipv4.FlagDst|ipv4.FlagInterface, true); err != nil {
+		return conn
+	}
+	return controlReader{conn, packetConn}
+}
+
+// readFrom reads a packet from the reader, returning the index of the
+// interface and the local address it was received on when known.
+func readFrom(reader searchReader, b []byte) (int, *net.UDPAddr, int, *net.UDPAddr, error) {
+	control, ok := reader.(controlReader)
+	if !ok {
+		n, addr, err := reader.ReadFromUDP(b)
+		return n, addr, 0, nil, err
+	}
+
+	n, cm, src, err := control.packetConn.ReadFrom(b)
+	if err != nil {
+		return n, nil, 0, nil, err
+	}
+
+	addr, _ := src.(*net.UDPAddr)
+	if cm == nil || cm.Dst == nil {
+		return n, addr, 0, nil, nil
+	}
+
+	port := 0
+	if local, ok := control.LocalAddr().(*net.UDPAddr); ok {
+		port = local.Port
+	}
+
+	return n, addr, cm.IfIndex, &net.UDPAddr{IP: cm.Dst, Port: port}, nil
+}
@@ -0,0 +1,16 @@
+package ssdp
+
+import (
+	"time"
+)
+
+// ClockSkew returns how far the clock of the device is ahead of the local
+// clock, going by the DATE header of the response. It is zero when the
+// response has no date. The DATE header has a resolution of one second and
+// the response spent some time on the network, so small values are noise.
+func (r SearchResponse) ClockSkew() time.Duration {
+	if r.Date.IsZero() || r.Received.IsZero() {
+		return 0
+	}
+	return r.Date.Sub(r.Received.Truncate(time.Second))
+}
@@ -0,0 +1,151 @@
+package ssdp
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// A Decoder turns a search response into a result of its own type, e.g. one
+// holding the vendor headers of a family of devices. It is passed the raw
+// message for the headers a SearchResponse doesn't hold. Responses it
+// returns an error for are left out.
+type Decoder[T any] func(response SearchResponse, raw []byte) (T, error)
+
+// Decoders pick the Decoder of a response by its ST or Server header, so the
+// logic for specific vendors can live outside of this package. The first
+// registered decoder matching a response is used.
+type Decoders[T any] struct {
+	mu       sync.RWMutex
+	decoders []matchedDecoder[T]
+}
+
+type matchedDecoder[T any] struct {
+	st      string
+	server  string
+	decoder Decoder[T]
+}
+
+// NewDecoders returns an empty set of decoders.
+func NewDecoders[T any]() *Decoders[T] {
+	return &Decoders[T]{}
+}
+
+// ForST registers the decoder for responses whose ST answers a search for
+// the target, see MatchesST.
+func (d *Decoders[T]) ForST(st string, decoder Decoder[T]) {
+	d.register(matchedDecoder[T]{st: st, decoder: decoder})
+}
+
+// ForServer registers the decoder for responses whose Server header contains
+// server, like Quirk.Server.
+func (d *Decoders[T]) ForServer(server string, decoder Decoder[T]) {
+	d.register(matchedDecoder[T]{server: server, decoder: decoder})
+}
+
+func (d *Decoders[T]) register(decoder matchedDecoder[T]) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.decoders = append(d.decoders, decoder)
+}
+
+// decoder returns the decoder of the response, nil when none matches.
+func (d *Decoders[T]) decoder(response SearchResponse) Decoder[T] {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, matched := range d.decoders {
+		if matched.st != "" && MatchesST(response.ST, matched.st) {
+			return matched.decoder
+		}
+		if matched.server != "" && strings.Contains(response.Server, matched.server) {
+			return matched.decoder
+		}
+	}
+	return nil
+}
+
+// Search searches like SSDP.SearchContext and returns the responses decoded
+// by the matching decoders. Responses no decoder matches are left out.
+func Search[T any](ctx context.Context, ssdp *SSDP, decoders *Decoders[T], search string) ([]T, error) {
+	ctx, scope := ssdp.withSearchScope(ctx, search)
+
+	readers, sent, release, err := ssdp.sendSearch(ctx, search)
+	if err != nil {
+		ssdp.publishSearch(ctx, EventSearchFinished, 0, err)
+		return nil, scope.wrap(err)
+	}
+	defer release()
+
+	var responses []SearchResponse
+	var raws [][]byte
+	err = ssdp.searchLoop(ctx, search, readers, sent, func(response SearchResponse, raw []byte) {
+		responses = append(responses, response)
+		raws = append(raws, raw)
+	}, nil)
+	if err != nil {
+		return nil, scope.wrap(err)
+	}
+
+	ssdp.resolveMACs(ctx, responses)
+	ssdp.resolveHostnames(ctx, responses)
+
+	results := make([]T, 0, len(responses))
+	for i, response := range responses {
+		decoder := decoders.decoder(response)
+		if decoder == nil {
+			continue
+		}
+		result, err := decoder(response, raws[i])
+		if err != nil {
+			ssdp.log(ctx, "undecodable response", "from", addrString(response.ResponseAddr), "error", err)
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// SearchAs searches like SSDP.SearchContext and returns the responses turned
+// into the types of the application by decode. Responses it returns an error
+// for are left out.
+func SearchAs[T any](ctx context.Context, ssdp *SSDP, search string, decode func(SearchResponse) (T, error)) ([]T, error) {
+	responses, err := ssdp.SearchContext(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]T, 0, len(responses))
+	for _, response := range responses {
+		result, err := decode(response)
+		if err != nil {
+			ssdp.log(ctx, "undecodable response", "from", addrString(response.ResponseAddr), "error", err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SearchDevicesAs searches like SSDP.SearchDevicesContext and returns the
+// devices turned into the types of the application by decode. Devices it
+// returns an error for are left out.
+func SearchDevicesAs[T any](ctx context.Context, ssdp *SSDP, search string, decode func(Device) (T, error)) ([]T, error) {
+	devices, err := ssdp.SearchDevicesContext(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]T, 0, len(devices))
+	for _, device := range devices {
+		result, err := decode(device)
+		if err != nil {
+			ssdp.log(ctx, "undecodable device", "udn", device.UDN, "error", err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,39 @@
+package ssdp
+
+import (
+	"net/url"
+)
+
+// A DeviceKey returns the key a registry tracks a device under, from the USN
+// and location it was seen with. Announcements and responses with the same
+// key update the same entry.
+type DeviceKey func(usn string, location *url.URL) string
+
+// UDNKey tracks devices by the UDN of their USN, the default.
+func UDNKey(usn string, location *url.URL) string {
+	return udnFromUSN(usn)
+}
+
+// HostKey tracks devices by the host of their location, for firmware that
+// makes up a new UUID on every boot. Devices sharing a host, such as a
+// bridge and the devices behind it, are merged into one entry. Devices
+// without a location fall back to their UDN.
+func HostKey(usn string, location *url.URL) string {
+	if location == nil || location.Hostname() == "" {
+		return udnFromUSN(usn)
+	}
+	return location.Hostname()
+}
+
+type deviceKeyOption struct {
+	key DeviceKey
+}
+
+func (d deviceKeyOption) apply(opts *registryOptions) {
+	opts.key = d.key
+}
+
+// WithDeviceKey sets the key devices are tracked under, UDNKey by default.
+func WithDeviceKey(key DeviceKey) OptionRegistry {
+	return deviceKeyOption{key}
+}
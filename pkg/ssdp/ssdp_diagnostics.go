@@ -0,0 +1,104 @@
+package ssdp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The most USNs the monitor remembers as alive for its diagnostics, several
+// per device, unless WithMonitorMaxTracked.
+const maxDiagnosedUSNs = 16384
+
+// DiagnosticKind is the kind of protocol inconsistency in an announcement.
+type DiagnosticKind int
+
+const (
+	// The USN does not contain the NT
+	DiagnosticUSNMismatch DiagnosticKind = iota
+	// A byebye for a USN not announced alive since the monitor started
+	DiagnosticUnknownByeBye
+	// An alive or update without a valid max-age
+	DiagnosticZeroMaxAge
+)
+
+func (k DiagnosticKind) String() string {
+	switch k {
+	case DiagnosticUSNMismatch:
+		return "usn-mismatch"
+	case DiagnosticUnknownByeBye:
+		return "unknown-byebye"
+	case DiagnosticZeroMaxAge:
+		return "zero-max-age"
+	}
+	return fmt.Sprintf("DiagnosticKind(%d)", int(k))
+}
+
+// A Diagnostic describes an announcement that is inconsistent with the
+// protocol. The announcement is delivered regardless.
+type Diagnostic struct {
+	Kind    DiagnosticKind
+	Message string
+	Notify  Notify
+}
+
+type diagnosticsOption func(Diagnostic)
+
+func (d diagnosticsOption) apply(opts *monitorOptions) {
+	opts.diagnostics = d
+}
+
+// WithDiagnostics calls the callback from the read loop for every
+// announcement inconsistent with the protocol, to help pinpoint broken
+// devices.
+func WithDiagnostics(callback func(Diagnostic)) OptionMonitor {
+	return diagnosticsOption(callback)
+}
+
+// diagnose reports the inconsistencies of the announcement. The caller is
+// the read loop, which owns alive.
+func (m *Monitor) diagnose(notify Notify) {
+	report := m.opts.diagnostics
+	if report == nil {
+		return
+	}
+
+	if notify.USN != notify.NT && !strings.HasSuffix(notify.USN, "::"+notify.NT) {
+		report(Diagnostic{
+			Kind:    DiagnosticUSNMismatch,
+			Message: fmt.Sprintf("USN %q does not contain the NT %q", notify.USN, notify.NT),
+			Notify:  notify,
+		})
+	}
+
+	switch notify.NTS {
+	case NTSByeBye:
+		// A device says byebye for each USN it announced, so the USN is
+		// forgotten rather than the device. Once some USNs could not be
+		// remembered, an unknown one may have been announced after all.
+		if !m.alive[notify.USN] && !m.aliveFull {
+			report(Diagnostic{
+				Kind:    DiagnosticUnknownByeBye,
+				Message: fmt.Sprintf("byebye for %s, which was not announced alive", notify.USN),
+				Notify:  notify,
+			})
+		}
+		delete(m.alive, notify.USN)
+	case NTSAlive, NTSUpdate:
+		if notify.MaxAge() == 0 {
+			report(Diagnostic{
+				Kind:    DiagnosticZeroMaxAge,
+				Message: fmt.Sprintf("%s of %s without a valid max-age in %q", notify.NTS, notify.USN, notify.Control),
+				Notify:  notify,
+			})
+		}
+		max := maxDiagnosedUSNs
+		if m.opts.maxTracked > 0 {
+			max = m.opts.maxTracked
+		}
+		if m.alive[notify.USN] || m.room(len(m.alive), max) {
+			m.alive[notify.USN] = true
+		} else {
+			m.aliveFull = true
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ssdp
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// The number of packet errors buffered for a consumer that is not reading
+// them.
+const discoverErrorBuffer = 16
+
+// PacketError is a packet received during a search that could not be parsed.
+// It does not end the search.
+type PacketError struct {
+	Addr *net.UDPAddr
+	Err  error
+}
+
+func (e *PacketError) Error() string {
+	return fmt.Sprintf("malformed response from %s: %v", addrString(e.Addr), e.Err)
+}
+
+func (e *PacketError) Unwrap() error {
+	return e.Err
+}
+
+// Discover searches like SearchContext, but delivers each response on the
+// returned channel as soon as it arrives. Packets that cannot be parsed are
+// delivered as *PacketError on the errors channel without ending the
+// search; they are dropped when the errors are not read. An error ending the
+// search early, like that of the context, is delivered last. Both channels
+// are closed when the search window closes.
+func (ssdp *SSDP) Discover(ctx context.Context, search string) (<-chan SearchResponse, <-chan error, error) {
+	ctx, scope := ssdp.withSearchScope(ctx, search)
+
+	readers, sent, release, err := ssdp.sendSearch(ctx, search)
+	if err != nil {
+		ssdp.publishSearch(ctx, EventSearchFinished, 0, err)
+		return nil, nil, scope.wrap(err)
+	}
+
+	responses := make(chan SearchResponse)
+	errs := make(chan error, discoverErrorBuffer)
+
+	go func() {
+		defer close(errs)
+		defer close(responses)
+		defer release()
+
+		deliver := func(response SearchResponse, _ []byte) {
+			select {
+			case responses <- response:
+			case <-ctx.Done():
+			}
+		}
+
+		packetError := func(err *PacketError) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}
+
+		if err := scope.wrap(ssdp.searchLoop(ctx, search, readers, sent, deliver, packetError)); err != nil {
+			// The final error must not be lost to a full buffer, unless
+			// nobody is reading anymore.
+			select {
+			case errs <- err:
+			default:
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+				}
+			}
+		}
+	}()
+
+	return responses, errs, nil
+}
@@ -0,0 +1,37 @@
+package ssdp
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// The encodings accepted for descriptions.
+const acceptEncoding = "gzip, deflate"
+
+// decodeBody returns a reader of the decompressed response body. The size
+// limits of the caller apply to the decompressed output.
+func decodeBody(response *http.Response) (io.Reader, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(response.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return response.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(response.Body)
+	case "deflate":
+		// Deflate is supposed to be zlib wrapped, but some servers send raw
+		// deflate data.
+		body := bufio.NewReader(response.Body)
+		header, err := body.Peek(2)
+		if err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+			return zlib.NewReader(body)
+		}
+		return flate.NewReader(body), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
@@ -0,0 +1,183 @@
+package ssdp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// The number of events an EventBus buffers when none is given.
+const defaultEventBuffer = 256
+
+type EventType int
+
+const (
+	EventSearchStarted EventType = iota + 1
+	EventSearchFinished
+	EventResponseReceived
+	EventDeviceAdded
+	EventDeviceRemoved
+	EventDeviceExpired
+	EventSubscriptionRenewed
+	EventSubscriptionFailed
+	EventAnnouncementSent
+	EventDeviceRebooted
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSearchStarted:
+		return "search started"
+	case EventSearchFinished:
+		return "search finished"
+	case EventResponseReceived:
+		return "response received"
+	case EventDeviceAdded:
+		return "device added"
+	case EventDeviceRemoved:
+		return "device removed"
+	case EventDeviceExpired:
+		return "device expired"
+	case EventSubscriptionRenewed:
+		return "subscription renewed"
+	case EventSubscriptionFailed:
+		return "subscription failed"
+	case EventAnnouncementSent:
+		return "announcement sent"
+	case EventDeviceRebooted:
+		return "device rebooted"
+	}
+	return "unknown"
+}
+
+// An Event is something that happened in a client, registry or GENA
+// subscriber sharing an EventBus. Only the fields of its type are set.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// The target of search events, the NT of announcements
+	ST string
+	// The number of responses of a finished search
+	Responses int
+	// The response received
+	Response *SearchResponse
+	// The device added, removed, expired or rebooted
+	Device *RegistryEntry
+	// The device, service and subscription of subscription events, and the
+	// USN of announcements
+	UDN       string
+	ServiceID string
+	SID       string
+	USN       string
+	// The error of a failed search or subscription
+	Err error
+}
+
+// An EventBus carries the events of the subsystems it is passed to, see
+// WithEventBus, WithRegistryEvents and gena.WithEventBus, to a single
+// consumer. Events are dropped rather than holding up a subsystem while the
+// buffer is full.
+type EventBus struct {
+	events chan Event
+
+	mu      sync.Mutex
+	dropped uint64
+	closed  bool
+}
+
+// NewEventBus returns a bus buffering up to size events, or 256 when size is
+// not positive.
+func NewEventBus(size int) *EventBus {
+	if size <= 0 {
+		size = defaultEventBuffer
+	}
+	return &EventBus{events: make(chan Event, size)}
+}
+
+// Events returns the events. The channel is closed when the bus is closed.
+func (b *EventBus) Events() <-chan Event {
+	return b.events
+}
+
+// Publish sends the event, setting its time when zero. It is safe to call on
+// a nil bus, which drops the event.
+func (b *EventBus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	select {
+	case b.events <- event:
+	default:
+		b.dropped++
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (b *EventBus) Dropped() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.dropped
+}
+
+// Close closes the events channel. Later events are dropped.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.events)
+	}
+}
+
+type eventBusOption struct {
+	bus *EventBus
+}
+
+func (e eventBusOption) apply(opts *options) {
+	opts.events = e.bus
+}
+
+// WithEventBus publishes the searches, responses and announcements of the
+// client to the bus.
+func WithEventBus(bus *EventBus) OptionSSDP {
+	return eventBusOption{bus}
+}
+
+type registryEventsOption struct {
+	bus *EventBus
+}
+
+func (r registryEventsOption) apply(opts *registryOptions) {
+	opts.events = r.bus
+}
+
+// WithRegistryEvents publishes the devices added to, removed from, expired
+// from and rebooted in the registry to the bus.
+func WithRegistryEvents(bus *EventBus) OptionRegistry {
+	return registryEventsOption{bus}
+}
+
+// publishSearch publishes an event of the search of the context.
+func (opts *options) publishSearch(ctx context.Context, eventType EventType, responses int, err error) {
+	if opts.events == nil {
+		return
+	}
+
+	event := Event{Type: eventType, Time: opts.clock.Now(), Responses: responses, Err: err}
+	if scope, ok := ctx.Value(searchScopeKey{}).(*searchScope); ok {
+		event.ST = scope.st
+	}
+	opts.events.Publish(event)
+}
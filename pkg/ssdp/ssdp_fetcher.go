@@ -0,0 +1,128 @@
+package ssdp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// A Fetcher retrieves the documents of devices, such as their descriptions.
+// Failed fetches are retried according to the policy set with WithFetchRetry,
+// unless the error is marked with Permanent.
+type Fetcher interface {
+	Fetch(ctx context.Context, location url.URL) (io.ReadCloser, error)
+}
+
+// FetcherFunc adapts a function to a Fetcher.
+type FetcherFunc func(ctx context.Context, location url.URL) (io.ReadCloser, error)
+
+func (f FetcherFunc) Fetch(ctx context.Context, location url.URL) (io.ReadCloser, error) {
+	return f(ctx, location)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+// Permanent marks an error of a Fetcher that retrying won't fix.
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+type fetcherOption struct {
+	fetcher Fetcher
+}
+
+func (f fetcherOption) apply(opts *options) {
+	opts.fetcher = f.fetcher
+}
+
+// WithFetcher replaces the HTTP retrieval of descriptions, e.g. to read them
+// from a cache, from test fixtures or over the proprietary transport of a
+// device. The transport, proxy and request decorator options only apply to
+// the default HTTP fetcher.
+func WithFetcher(fetcher Fetcher) OptionSSDP {
+	return fetcherOption{fetcher}
+}
+
+// Fetch retrieves the document at the location with the configured fetcher
+// and cache, without retrying.
+func (opts *options) Fetch(ctx context.Context, location url.URL) (io.ReadCloser, error) {
+	if opts.cache != nil {
+		return opts.cache.fetch(ctx, location, opts.activeFetcher())
+	}
+	return opts.activeFetcher().Fetch(ctx, location)
+}
+
+// activeFetcher returns the configured fetcher, or the HTTP one.
+func (opts *options) activeFetcher() Fetcher {
+	if opts.fetcher != nil {
+		return opts.fetcher
+	}
+	return httpFetcher{opts}
+}
+
+// httpFetcher fetches documents over HTTP, decompressing them.
+type httpFetcher struct {
+	opts *options
+}
+
+func (h httpFetcher) Fetch(ctx context.Context, location url.URL) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location.String(), nil)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	request.Header.Set("Accept-Encoding", acceptEncoding)
+
+	response, err := h.opts.doRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("unexpected status %q fetching %s", response.Status, location.String())
+	}
+
+	// The Content-Type isn't checked, many devices serve their description
+	// as text/html or text/plain.
+
+	body, err := decodeBody(response)
+	if err != nil {
+		response.Body.Close()
+		return nil, Permanent(err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{body, response.Body}, nil
+}
+
+// FSFetcher returns a Fetcher reading documents from the file system by the
+// path of their location, ignoring the host, e.g. "rootDesc.xml" for
+// "http://192.168.1.30:8200/rootDesc.xml". Missing files are permanent
+// errors.
+func FSFetcher(fsys fs.FS) Fetcher {
+	return FetcherFunc(func(ctx context.Context, location url.URL) (io.ReadCloser, error) {
+		name := strings.TrimPrefix(path.Clean("/"+location.Path), "/")
+		file, err := fsys.Open(name)
+		if err != nil {
+			return nil, Permanent(err)
+		}
+		return file, nil
+	})
+}
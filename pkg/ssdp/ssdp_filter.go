@@ -0,0 +1,78 @@
+package ssdp
+
+import (
+	"net"
+	"regexp"
+)
+
+// A DeviceFilter matches search responses by device, address or server. A
+// response matches when any of the criteria matches.
+type DeviceFilter struct {
+	// UDNs like "uuid:2f402f80-da50-11e1-9b23-001788255acc"
+	UDNs []string
+	// Networks the response may come from
+	Networks []*net.IPNet
+	// Patterns of the SERVER header
+	Servers []*regexp.Regexp
+}
+
+// Match reports whether the response matches the filter.
+func (f DeviceFilter) Match(response *SearchResponse) bool {
+	udn := udnFromUSN(response.USN)
+	for _, u := range f.UDNs {
+		if u == udn {
+			return true
+		}
+	}
+
+	if response.ResponseAddr != nil {
+		for _, network := range f.Networks {
+			if network.Contains(response.ResponseAddr.IP) {
+				return true
+			}
+		}
+	}
+
+	for _, server := range f.Servers {
+		if server.MatchString(response.Server) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type excludeOption DeviceFilter
+
+func (e excludeOption) apply(opts *options) {
+	filter := DeviceFilter(e)
+	opts.exclude = &filter
+}
+
+// WithExclude drops the search responses matching the filter, so that known
+// irrelevant devices are skipped along with their descriptions. Dropped
+// responses are counted in Progress.Filtered.
+func WithExclude(filter DeviceFilter) OptionSSDP {
+	return excludeOption(filter)
+}
+
+type includeOnlyOption DeviceFilter
+
+func (i includeOnlyOption) apply(opts *options) {
+	filter := DeviceFilter(i)
+	opts.includeOnly = &filter
+}
+
+// WithIncludeOnly drops the search responses not matching the filter.
+// Exclusions of WithExclude apply on top.
+func WithIncludeOnly(filter DeviceFilter) OptionSSDP {
+	return includeOnlyOption(filter)
+}
+
+// filtered reports whether the response is dropped by the filters.
+func (opts *options) filtered(response *SearchResponse) bool {
+	if opts.includeOnly != nil && !opts.includeOnly.Match(response) {
+		return true
+	}
+	return opts.exclude != nil && opts.exclude.Match(response)
+}
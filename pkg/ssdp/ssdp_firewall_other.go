@@ -0,0 +1,12 @@
+//go:build !windows
+
+package ssdp
+
+import (
+	"context"
+)
+
+// firewallPublic reports false, firewall profiles are Windows only.
+func firewallPublic(ctx context.Context, iface string) bool {
+	return false
+}
@@ -0,0 +1,26 @@
+package ssdp
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// firewallPublic reports whether the network of the interface, or any
+// network when empty, is in the public profile of the Windows firewall.
+func firewallPublic(ctx context.Context, iface string) bool {
+	command := "(Get-NetConnectionProfile).NetworkCategory"
+	if iface != "" {
+		command = "(Get-NetConnectionProfile -InterfaceAlias '" + strings.ReplaceAll(iface, "'", "''") + "').NetworkCategory"
+	}
+	out, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command).Output()
+	if err != nil {
+		return false
+	}
+	for _, category := range strings.Fields(string(out)) {
+		if category == "Public" {
+			return true
+		}
+	}
+	return false
+}
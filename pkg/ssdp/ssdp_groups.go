@@ -0,0 +1,76 @@
+package ssdp
+
+import (
+	"net"
+)
+
+type groupsOption []string
+
+func (g groupsOption) apply(opts *options) {
+	opts.groups = append(opts.groups, g...)
+}
+
+// WithGroups sends each search to the given multicast groups as well as the
+// broadcast IP, for networks that announce some devices on a site-specific
+// group. The Group of each response tells which search it answers.
+func WithGroups(groups ...string) OptionSSDP {
+	return groupsOption(groups)
+}
+
+// searchGroups returns the broadcast IP followed by the further groups,
+// without duplicates.
+func (opts *options) searchGroups() []string {
+	groups := []string{opts.broadcastIp}
+	seen := map[string]bool{opts.broadcastIp: true}
+
+	for _, group := range opts.groups {
+		if !seen[group] {
+			seen[group] = true
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+// A searchReader receiving the responses to the search sent to a group.
+type groupReader struct {
+	searchReader
+	group string
+}
+
+type groupConn struct {
+	*net.UDPConn
+	groupReader
+}
+
+// listenForGroups returns a socket for each group to search. A unicast
+// response does not say which search it answers, so the further groups are
+// searched from sockets of their own on ephemeral ports.
+func (ssdp *SSDP) listenForGroups() ([]groupConn, func(), error) {
+	conn, release, err := ssdp.listenForSearchResponses()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups := ssdp.searchGroups()
+	conns := []groupConn{{conn, groupReader{newControlReader(conn), groups[0]}}}
+	releaseAll := func() {
+		release()
+		for _, conn := range conns[1:] {
+			conn.Close()
+		}
+	}
+
+	for _, group := range groups[1:] {
+		addr := &net.UDPAddr{IP: conn.LocalAddr().(*net.UDPAddr).IP}
+		extra, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			releaseAll()
+			return nil, nil, err
+		}
+		conns = append(conns, groupConn{extra, groupReader{newControlReader(extra), group}})
+	}
+
+	return conns, releaseAll, nil
+}
@@ -0,0 +1,189 @@
+package ssdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// A Hint is the machine-readable cause of a failure to see SSDP traffic, for
+// applications to show the user how to fix it.
+type Hint string
+
+const (
+	// The socket is bound but not a single packet was seen on it
+	HintNoTraffic Hint = "no-traffic"
+	// The network is in the public profile of the Windows firewall, which
+	// blocks inbound SSDP
+	HintFirewallPublic Hint = "windows-firewall-public"
+	// The process runs on a Docker bridge network, which does not pass
+	// multicast to and from the LAN, rather than on the network of the host
+	HintDockerBridge Hint = "docker-bridge"
+)
+
+// Guidance returns what the user can do about the cause.
+func (h Hint) Guidance() string {
+	switch h {
+	case HintNoTraffic:
+		return "No packets arrive. Check that a firewall allows inbound UDP on the SSDP port and that the network passes multicast."
+	case HintFirewallPublic:
+		return "The network is public to Windows Firewall. Mark it private or allow the application through the firewall."
+	case HintDockerBridge:
+		return "Docker bridge networks do not pass multicast. Run the container with --network host or on a macvlan network."
+	}
+	return string(h)
+}
+
+// HintError is an error with the hints on its likely cause.
+type HintError struct {
+	Hints []Hint
+	Err   error
+}
+
+func (e *HintError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HintError) Unwrap() error {
+	return e.Err
+}
+
+// HintsOf returns the hints attached to the error, nil when it has none.
+func HintsOf(err error) []Hint {
+	var hintErr *HintError
+	if errors.As(err, &hintErr) {
+		return hintErr.Hints
+	}
+	return nil
+}
+
+// withHints attaches the hints to the error, which is returned as is when
+// there are none.
+func withHints(err error, hints []Hint) error {
+	if len(hints) == 0 {
+		return err
+	}
+	return &HintError{Hints: hints, Err: err}
+}
+
+// environmentHints returns the hints on the host and network of the
+// interface, any interface when empty.
+func environmentHints(ctx context.Context, iface string) []Hint {
+	var hints []Hint
+	if firewallPublic(ctx, iface) {
+		hints = append(hints, HintFirewallPublic)
+	}
+	if dockerBridge(iface) {
+		hints = append(hints, HintDockerBridge)
+	}
+	return hints
+}
+
+// dockerBridge reports whether the interface is a Docker bridge, or the
+// process runs in a Docker container attached to one.
+func dockerBridge(iface string) bool {
+	return behindBridge(os.DirFS("/"), iface)
+}
+
+// behindBridge reports whether the interface is a bridge, or the container
+// the root belongs to reaches its default gateway through a veth interface,
+// the end of a bridge. Containers sharing the network of the host with
+// --network host see its bridges and are not behind one.
+func behindBridge(root fs.FS, iface string) bool {
+	if isBridgeName(iface) {
+		return true
+	}
+	if !inContainer(root) {
+		return false
+	}
+
+	links, _ := fs.ReadDir(root, "sys/class/net")
+	for _, link := range links {
+		if isBridgeName(link.Name()) {
+			return false
+		}
+	}
+
+	for _, name := range defaultRouteInterfaces(root) {
+		if (iface == "" || name == iface) && isVeth(root, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBridgeName(iface string) bool {
+	return iface == "docker0" || strings.HasPrefix(iface, "br-")
+}
+
+// inContainer reports whether the root is that of a Docker container.
+func inContainer(root fs.FS) bool {
+	if _, err := fs.Stat(root, ".dockerenv"); err == nil {
+		return true
+	}
+	cgroup, err := fs.ReadFile(root, "proc/1/cgroup")
+	return err == nil && bytes.Contains(cgroup, []byte("docker"))
+}
+
+// defaultRouteInterfaces returns the interfaces with a default route through
+// a gateway, from the IPv4 routing table.
+func defaultRouteInterfaces(root fs.FS) []string {
+	table, err := fs.ReadFile(root, "proc/net/route")
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	// The first line names the columns: Iface Destination Gateway ...
+	for _, line := range strings.Split(string(table), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[1] == "00000000" && fields[2] != "00000000" {
+			names = append(names, fields[0])
+		}
+	}
+	return names
+}
+
+// isVeth reports whether the interface is one end of a veth pair, whose
+// peer lives in another namespace, as told by its link differing from its
+// own index.
+func isVeth(root fs.FS, iface string) bool {
+	index, err := fs.ReadFile(root, "sys/class/net/"+iface+"/ifindex")
+	if err != nil {
+		return false
+	}
+	link, err := fs.ReadFile(root, "sys/class/net/"+iface+"/iflink")
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(index)) != strings.TrimSpace(string(link))
+}
+
+// CheckTraffic returns a *HintError with HintNoTraffic and the hints on the
+// environment when the monitor has not received a single packet, which on a
+// network with UPnP devices means it is blocked. Call it once devices had
+// time to announce, e.g. after a few minutes.
+func (m *Monitor) CheckTraffic(ctx context.Context) error {
+	if atomic.LoadUint64(&m.received) > 0 {
+		return nil
+	}
+	err := fmt.Errorf("ssdp: no packets received on %s in %v", m.conn.LocalAddr(), m.clock.Now().Sub(m.started).Round(time.Second))
+	return withHints(err, append([]Hint{HintNoTraffic}, environmentHints(ctx, m.iface)...))
+}
+
+// interfaceHints returns the hints on an interface the self-test failed on,
+// where seen tells whether any packet arrived.
+func interfaceHints(ctx context.Context, iface net.Interface, seen bool) []Hint {
+	hints := environmentHints(ctx, iface.Name)
+	if !seen {
+		hints = append([]Hint{HintNoTraffic}, hints...)
+	}
+	return hints
+}
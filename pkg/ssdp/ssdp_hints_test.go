@@ -0,0 +1,56 @@
+package ssdp
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func Test_BehindBridge(t *testing.T) {
+	routes := "Iface\tDestination\tGateway\tFlags\n" +
+		"eth0\t00000000\t010011AC\t0003\n" +
+		"eth0\t000011AC\t00000000\t0001\n"
+
+	bridged := fstest.MapFS{
+		".dockerenv":                 {},
+		"proc/net/route":             {Data: []byte(routes)},
+		"sys/class/net/eth0/ifindex": {Data: []byte("12\n")},
+		"sys/class/net/eth0/iflink":  {Data: []byte("13\n")},
+		"sys/class/net/lo/ifindex":   {Data: []byte("1\n")},
+		"sys/class/net/lo/iflink":    {Data: []byte("1\n")},
+	}
+	if !behindBridge(bridged, "eth0") || !behindBridge(bridged, "") {
+		t.Error("expected a container reaching its gateway through a veth to be behind a bridge")
+	}
+	if behindBridge(bridged, "lo") {
+		t.Error("expected an interface without the default route not to be behind a bridge")
+	}
+
+	// With --network host the container sees the interfaces of the host
+	hostNetwork := fstest.MapFS{
+		".dockerenv":                    {},
+		"proc/net/route":                {Data: []byte(routes)},
+		"sys/class/net/eth0/ifindex":    {Data: []byte("2\n")},
+		"sys/class/net/eth0/iflink":     {Data: []byte("2\n")},
+		"sys/class/net/docker0/ifindex": {Data: []byte("3\n")},
+		"sys/class/net/docker0/iflink":  {Data: []byte("3\n")},
+	}
+	if behindBridge(hostNetwork, "eth0") {
+		t.Error("expected a container on the host network not to be behind a bridge")
+	}
+	hostNetwork["sys/class/net/eth0/iflink"] = &fstest.MapFile{Data: []byte("7\n")}
+	if behindBridge(hostNetwork, "eth0") {
+		t.Error("expected the bridges of the host to rule out a bridge network")
+	}
+
+	host := fstest.MapFS{
+		"proc/net/route":             {Data: []byte(routes)},
+		"sys/class/net/eth0/ifindex": {Data: []byte("12\n")},
+		"sys/class/net/eth0/iflink":  {Data: []byte("13\n")},
+	}
+	if behindBridge(host, "eth0") {
+		t.Error("expected a process outside a container not to be behind a bridge")
+	}
+	if !behindBridge(host, "docker0") || !behindBridge(host, "br-1a2b3c") {
+		t.Error("expected the bridges themselves to be reported")
+	}
+}
@@ -0,0 +1,30 @@
+package ssdp
+
+// A DescriptionHook is called with each fetched description before it is
+// returned, e.g. to look the device up in a vendor database, tag it or
+// normalize its fields. The response is the one announcing the location,
+// nil when the description was fetched without one. Returning an error
+// fails the fetch with it.
+type DescriptionHook func(device *Device, response *SearchResponse) error
+
+type descriptionHookOption []DescriptionHook
+
+func (d descriptionHookOption) apply(opts *options) {
+	opts.descriptionHooks = append(opts.descriptionHooks, d...)
+}
+
+// WithDescriptionHooks registers hooks called in order after each
+// description is parsed, by SearchDevices and FetchDescription alike.
+func WithDescriptionHooks(hooks ...DescriptionHook) OptionSSDP {
+	return descriptionHookOption(hooks)
+}
+
+// postProcess runs the description hooks, stopping at the first error.
+func (opts *options) postProcess(device *Device, response *SearchResponse) error {
+	for _, hook := range opts.descriptionHooks {
+		if err := hook(device, response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package ssdp
+
+import (
+	"context"
+	"sync"
+)
+
+// The number of concurrent description requests to a host when not set with
+// WithMaxFetchesPerHost.
+const defaultFetchesPerHost = 2
+
+// hostLimiter bounds the concurrent requests to each host.
+type hostLimiter struct {
+	limit int
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{limit: limit, slots: make(map[string]chan struct{})}
+}
+
+// acquire waits for a free slot of the host and returns the function
+// releasing it.
+func (h *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	if h.limit <= 0 {
+		return func() {}, nil
+	}
+
+	h.mu.Lock()
+	slots, ok := h.slots[host]
+	if !ok {
+		slots = make(chan struct{}, h.limit)
+		h.slots[host] = slots
+	}
+	h.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type maxFetchesPerHostOption int
+
+func (m maxFetchesPerHostOption) apply(opts *options) {
+	opts.fetchesPerHost = int(m)
+}
+
+// WithMaxFetchesPerHost limits the concurrent description requests to each
+// host, 2 by default. The web servers of embedded devices commonly drop
+// parallel connections, and a root device with embedded devices or several
+// devices behind one bridge share a host. Zero or less lifts the limit.
+func WithMaxFetchesPerHost(max int) OptionSSDP {
+	return maxFetchesPerHostOption(max)
+}
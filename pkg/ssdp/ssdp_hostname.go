@@ -0,0 +1,116 @@
+package ssdp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long resolved hostnames, and failures to resolve one, are cached.
+const hostnameTTL = 10 * time.Minute
+
+// reverseDNS resolves and caches the hostnames of responders.
+type reverseDNS struct {
+	resolver *net.Resolver
+	timeout  time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedHostname
+}
+
+type cachedHostname struct {
+	name    string
+	expires time.Time
+}
+
+type reverseDNSOption struct {
+	resolver *net.Resolver
+	timeout  time.Duration
+}
+
+func (r reverseDNSOption) apply(opts *options) {
+	resolver := r.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	opts.reverseDNS = &reverseDNS{resolver: resolver, timeout: r.timeout, cache: make(map[string]cachedHostname)}
+}
+
+// WithReverseDNS looks up the hostname of each responder with a PTR query
+// once the search window closes, giving up on those not answered within the
+// timeout. Hostnames, and failures to find one, are cached for ten minutes.
+// A nil resolver uses net.DefaultResolver.
+func WithReverseDNS(resolver *net.Resolver, timeout time.Duration) OptionSSDP {
+	return reverseDNSOption{resolver, timeout}
+}
+
+// resolveHostnames sets the hostname of the responses.
+func (ssdp *SSDP) resolveHostnames(ctx context.Context, responses []SearchResponse) {
+	if ssdp.reverseDNS == nil || len(responses) == 0 {
+		return
+	}
+
+	if ssdp.reverseDNS.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ssdp.reverseDNS.timeout)
+		defer cancel()
+	}
+
+	names := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, response := range responses {
+		if response.ResponseAddr == nil {
+			continue
+		}
+		ip := response.ResponseAddr.IP.String()
+		if _, ok := names[ip]; ok {
+			continue
+		}
+		names[ip] = ""
+
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			name := ssdp.reverseDNS.lookup(ctx, ip, ssdp.clock.Now())
+			mu.Lock()
+			names[ip] = name
+			mu.Unlock()
+		}(ip)
+	}
+	wg.Wait()
+
+	for i := range responses {
+		if responses[i].ResponseAddr != nil {
+			responses[i].Hostname = names[responses[i].ResponseAddr.IP.String()]
+		}
+	}
+}
+
+// lookup returns the cached hostname of the IP, or resolves it.
+func (r *reverseDNS) lookup(ctx context.Context, ip string, now time.Time) string {
+	r.mu.Lock()
+	cached, ok := r.cache[ip]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.name
+	}
+
+	names, err := r.resolver.LookupAddr(ctx, ip)
+	if err != nil && ctx.Err() != nil {
+		// Timed out, try again next search
+		return ""
+	}
+	name := ""
+	if len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	r.mu.Lock()
+	r.cache[ip] = cachedHostname{name: name, expires: now.Add(hostnameTTL)}
+	r.mu.Unlock()
+
+	return name
+}
@@ -0,0 +1,195 @@
+package ssdp
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type transportOption struct {
+	transport http.RoundTripper
+}
+
+func (t transportOption) apply(opts *options) {
+	opts.transport = t.transport
+}
+
+// WithTransport sets the transport used for HTTP requests to devices, such as
+// description fetches. It defaults to http.DefaultTransport.
+func WithTransport(transport http.RoundTripper) OptionSSDP {
+	return transportOption{transport}
+}
+
+type requestDecoratorOption func(*http.Request) error
+
+func (r requestDecoratorOption) apply(opts *options) {
+	opts.decorate = r
+}
+
+// WithRequestDecorator registers a function that can modify every HTTP
+// request to a device before it is sent, for example to add credentials for
+// a specific host. Returning an error aborts the request.
+func WithRequestDecorator(decorate func(*http.Request) error) OptionSSDP {
+	return requestDecoratorOption(decorate)
+}
+
+type proxyOption func(*http.Request) (*url.URL, error)
+
+func (p proxyOption) apply(opts *options) {
+	opts.proxy = p
+}
+
+// WithProxy sets the proxy for HTTP requests to devices, such as
+// http.ProxyURL of an inspection proxy. Without it the proxy comes from the
+// HTTP_PROXY and NO_PROXY environment, as with http.ProxyFromEnvironment. It
+// applies to the default transport and to custom ones of type *http.Transport.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) OptionSSDP {
+	return proxyOption(proxy)
+}
+
+// HTTPTuning adjusts the connection reuse of HTTP requests to devices. Zero
+// values keep the setting of the transport.
+type HTTPTuning struct {
+	// Idle connections kept per device, embedded servers often handle only
+	// a few connections at once
+	MaxIdleConnsPerHost int
+	// How long idle connections are kept
+	IdleConnTimeout time.Duration
+	// Close every connection after its request, see also Quirk.NoKeepAlive
+	DisableKeepAlives bool
+}
+
+type httpTuningOption HTTPTuning
+
+func (h httpTuningOption) apply(opts *options) {
+	tuning := HTTPTuning(h)
+	opts.tuning = &tuning
+}
+
+// WithHTTPTuning tunes the connection reuse of the default transport, or of
+// a custom one of type *http.Transport.
+func WithHTTPTuning(tuning HTTPTuning) OptionSSDP {
+	return httpTuningOption(tuning)
+}
+
+// tuneTransport returns the transport with the proxy and tuning applied,
+// cloned once so connections are reused across requests.
+func (opts *options) tuneTransport() http.RoundTripper {
+	transport := opts.transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	base, ok := transport.(*http.Transport)
+	if !ok || (opts.proxy == nil && opts.tuning == nil) {
+		return opts.transport
+	}
+
+	tuned := base.Clone()
+	if opts.proxy != nil {
+		tuned.Proxy = opts.proxy
+	}
+	if tuning := opts.tuning; tuning != nil {
+		if tuning.MaxIdleConnsPerHost > 0 {
+			tuned.MaxIdleConnsPerHost = tuning.MaxIdleConnsPerHost
+		}
+		if tuning.IdleConnTimeout > 0 {
+			tuned.IdleConnTimeout = tuning.IdleConnTimeout
+		}
+		tuned.DisableKeepAlives = tuned.DisableKeepAlives || tuning.DisableKeepAlives
+	}
+	return tuned
+}
+
+// hostSet is a set of hostnames shared by the copies of the options.
+type hostSet struct {
+	mu    sync.RWMutex
+	hosts map[string]bool
+}
+
+func (h *hostSet) add(host string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.hosts == nil {
+		h.hosts = make(map[string]bool)
+	}
+	h.hosts[host] = true
+}
+
+func (h *hostSet) contains(host string) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.hosts[host]
+}
+
+// closingTransport closes the connections to the devices with the
+// NoKeepAlive quirk, learning them from the Server header of their replies.
+type closingTransport struct {
+	opts *options
+	base http.RoundTripper
+}
+
+func (c *closingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	host := request.URL.Hostname()
+	if c.opts.noKeepAlive.contains(host) && !request.Close {
+		// A RoundTripper must not modify the request it was given
+		request = request.Clone(request.Context())
+		request.Close = true
+	}
+
+	response, err := c.base.RoundTrip(request)
+	if err == nil && !request.Close {
+		if server := response.Header.Get("Server"); server != "" && c.opts.quirksOf(server, "").NoKeepAlive {
+			c.opts.noKeepAlive.add(host)
+		}
+	}
+	return response, err
+}
+
+// HTTPClient returns an HTTP client for device traffic using the configured
+// transport, proxy and request decorator. It can be passed to the SOAP and
+// GENA clients so their traffic takes the same route.
+func (opts *options) HTTPClient() *http.Client {
+	transport := opts.transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	transport = &closingTransport{opts: opts, base: transport}
+
+	if opts.decorate != nil {
+		transport = &decoratingTransport{decorate: opts.decorate, base: transport}
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// doRequest decorates and sends an HTTP request to a device.
+func (opts *options) doRequest(request *http.Request) (*http.Response, error) {
+	return opts.HTTPClient().Do(request)
+}
+
+type decoratingTransport struct {
+	decorate func(*http.Request) error
+	base     http.RoundTripper
+}
+
+func (d *decoratingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it was given
+	request = request.Clone(request.Context())
+	if err := d.decorate(request); err != nil {
+		if request.Body != nil {
+			request.Body.Close()
+		}
+		return nil, err
+	}
+	return d.base.RoundTrip(request)
+}
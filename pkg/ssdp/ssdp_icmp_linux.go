@@ -0,0 +1,18 @@
+package ssdp
+
+import (
+	"net"
+	"syscall"
+)
+
+// reportICMPErrors has the reads of the unconnected socket fail with the ICMP
+// errors its packets cause, which Linux only does with IP_RECVERR.
+func reportICMPErrors(conn *net.UDPConn) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return
+	}
+	_ = raw.Control(func(fd uintptr) {
+		_ = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_RECVERR, 1)
+	})
+}
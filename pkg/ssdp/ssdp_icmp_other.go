@@ -0,0 +1,11 @@
+//go:build !linux
+
+package ssdp
+
+import (
+	"net"
+)
+
+// reportICMPErrors does nothing, ICMP errors are not reported on unconnected
+// sockets.
+func reportICMPErrors(conn *net.UDPConn) {}
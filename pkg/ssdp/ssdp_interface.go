@@ -0,0 +1,39 @@
+package ssdp
+
+import (
+	"fmt"
+	"net"
+)
+
+type interfaceOption string
+
+func (i interfaceOption) apply(opts *options) {
+	opts.iface = string(i)
+}
+
+// WithInterface sends searches from the network interface with the given
+// name instead of the default route.
+func WithInterface(name string) OptionSSDP {
+	return interfaceOption(name)
+}
+
+// InterfaceIP returns the first IPv4 address of the named interface.
+func InterfaceIP(name string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+	}
+
+	return nil, fmt.Errorf("interface %s has no IPv4 address", name)
+}
@@ -0,0 +1,59 @@
+package ssdp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	// MaxMessageSize is the largest SSDP message accepted from the network.
+	MaxMessageSize = 8192
+	// MaxHeaderCount is the largest number of header lines in a message.
+	MaxHeaderCount = 64
+	// MaxHeaderLength is the longest header line accepted in a message.
+	MaxHeaderLength = 1024
+	// MaxDescriptionSize is the largest device description that is decoded.
+	MaxDescriptionSize = 1 << 20
+)
+
+var (
+	ErrMessageTooLarge     = errors.New("ssdp: message too large")
+	ErrTooManyHeaders      = errors.New("ssdp: too many headers")
+	ErrHeaderTooLong       = errors.New("ssdp: header line too long")
+	ErrDescriptionTooLarge = errors.New("ssdp: description too large")
+)
+
+// limitMessage reads a complete SSDP message and checks it against the size
+// limits before handing it to the HTTP parser.
+func limitMessage(message io.Reader) (*bufio.Reader, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(message, MaxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > MaxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	// Only the start line and headers are checked, the body is ignored.
+	header := bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	if end := bytes.Index(header, []byte("\n\n")); end >= 0 {
+		header = header[:end]
+	}
+
+	lines := bytes.Split(header, []byte("\n"))
+	if len(lines) > MaxHeaderCount+1 {
+		return nil, ErrTooManyHeaders
+	}
+
+	for _, line := range lines {
+		if len(line) > MaxHeaderLength {
+			return nil, ErrHeaderTooLong
+		}
+	}
+
+	return bufio.NewReader(bytes.NewReader(repairHeaders(data))), nil
+}
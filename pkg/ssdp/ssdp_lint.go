@@ -0,0 +1,203 @@
+package ssdp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The longest max-age that is still considered sane.
+const maxSaneMaxAge = 24 * time.Hour
+
+// Devices announce each of their notification types in a burst, bursts
+// further apart than this are separate announcement rounds.
+const announcementBurst = 2 * time.Second
+
+// LintConfig selects the device to lint and the checks to run.
+type LintConfig struct {
+	// The UDN of the device, e.g. "uuid:4d696e69-444c-164e-9d41-b827eb54e939"
+	UDN string
+	// How long to listen for announcements. The announcement checks are
+	// skipped when zero, to be meaningful it should exceed half the max-age.
+	Listen time.Duration
+}
+
+// A LintReport lists the conformance problems found for a device.
+type LintReport struct {
+	UDN      string
+	Findings []Finding
+}
+
+func (r *LintReport) add(severity Severity, subject string, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, Finding{Severity: severity, Header: subject, Problem: fmt.Sprintf(format, args...)})
+}
+
+// Errors returns the number of findings of error severity.
+func (r *LintReport) Errors() int {
+	errors := 0
+	for _, finding := range r.Findings {
+		if finding.Severity == SeverityError {
+			errors++
+		}
+	}
+	return errors
+}
+
+// Lint runs UDA conformance checks against a device: it validates its search
+// responses strictly, checks the sanity of the max-age, the description and
+// the reachability of the URLs in it, and optionally the intervals of its
+// announcements. The error is only set when linting could not run at all.
+func (ssdp *SSDP) Lint(ctx context.Context, config LintConfig) (*LintReport, error) {
+	if !strings.HasPrefix(config.UDN, "uuid:") {
+		return nil, fmt.Errorf("invalid UDN %q", config.UDN)
+	}
+
+	options := *ssdp.options
+	options.strict = true
+	strict := &SSDP{&options}
+
+	report := &LintReport{UDN: config.UDN}
+
+	responses, err := strict.SearchContext(ctx, config.UDN)
+	if err != nil {
+		return nil, err
+	}
+
+	var maxAge time.Duration
+	var response *SearchResponse
+	for i := range responses {
+		if udnFromUSN(responses[i].USN) != config.UDN {
+			continue
+		}
+		response = &responses[i]
+		maxAge = response.MaxAge()
+		report.Findings = append(report.Findings, response.Findings...)
+	}
+
+	if response == nil {
+		report.add(SeverityError, "search", "no response to a search for %s", config.UDN)
+	} else {
+		if maxAge > maxSaneMaxAge {
+			report.add(SeverityWarning, "CACHE-CONTROL", "max-age of %v exceeds a day, the device would linger long after disappearing", maxAge)
+		}
+		if response.Location != nil {
+			strict.lintDescription(ctx, report, response)
+		}
+	}
+
+	if config.Listen > 0 {
+		if err := strict.lintAnnouncements(ctx, report, config, maxAge); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+func (ssdp *SSDP) lintDescription(ctx context.Context, report *LintReport, response *SearchResponse) {
+	device, err := ssdp.FetchDescriptionContext(ctx, response.Location)
+	if err != nil {
+		report.add(SeverityError, "description", "fetching %s: %v", response.Location, err)
+		return
+	}
+
+	report.Findings = append(report.Findings, device.Findings...)
+	if udn := udnFromUSN(response.USN); device.UDN != udn {
+		report.add(SeverityError, "UDN", "%q does not match the USN %q", device.UDN, udn)
+	}
+
+	for _, service := range device.AllServices() {
+		ssdp.lintURL(ctx, report, device, "service "+service.ServiceID+" SCPDURL", service.SCPDURL)
+	}
+
+	for _, icon := range device.Icons {
+		ssdp.lintURL(ctx, report, device, "icon", icon.URL)
+	}
+	if device.PresentationURL != "" {
+		ssdp.lintURL(ctx, report, device, "presentationURL", device.PresentationURL)
+	}
+}
+
+// lintURL checks that the URL, relative to the description, can be fetched.
+func (ssdp *SSDP) lintURL(ctx context.Context, report *LintReport, device *Device, subject string, ref string) {
+	if ref == "" {
+		report.add(SeverityError, subject, "missing")
+		return
+	}
+
+	u, err := device.ResolveURL(ref)
+	if err != nil {
+		report.add(SeverityError, subject, "invalid URL %q: %v", ref, err)
+		return
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		report.add(SeverityError, subject, "invalid URL %q: %v", ref, err)
+		return
+	}
+
+	response, err := ssdp.doRequest(request)
+	if err != nil {
+		report.add(SeverityError, subject, "unreachable: %v", err)
+		return
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		report.add(SeverityError, subject, "%s answered %q", u, response.Status)
+	}
+}
+
+// lintAnnouncements listens for the announcements of the device and checks
+// that they are repeated within half the max-age, as the UDA requires.
+func (ssdp *SSDP) lintAnnouncements(ctx context.Context, report *LintReport, config LintConfig, maxAge time.Duration) error {
+	monitor, err := ssdp.Monitor(WithBufferedDelivery(256))
+	if err != nil {
+		return err
+	}
+	defer monitor.Close()
+
+	var last time.Time
+	var longest time.Duration
+	rounds := 0
+
+	window := ssdp.clock.After(config.Listen)
+listen:
+	for {
+		select {
+		case <-window:
+			break listen
+		case <-ctx.Done():
+			return ctx.Err()
+		case notify := <-monitor.Notifications():
+			if udnFromUSN(notify.USN) != config.UDN || notify.NTS != NTSAlive {
+				continue
+			}
+			now := ssdp.clock.Now()
+			if rounds == 0 || now.Sub(last) > announcementBurst {
+				if rounds > 0 && now.Sub(last) > longest {
+					longest = now.Sub(last)
+				}
+				rounds++
+			}
+			last = now
+			if notify.MaxAge() == 0 {
+				report.add(SeverityError, "NOTIFY", "announcement of %s without a valid max-age", notify.NT)
+			}
+		}
+	}
+
+	switch {
+	case rounds == 0:
+		report.add(SeverityError, "NOTIFY", "no announcement within %v", config.Listen)
+	case maxAge > 0 && longest > maxAge/2:
+		report.add(SeverityWarning, "NOTIFY", "announcements %v apart, more than half the max-age of %v", longest, maxAge)
+	case maxAge > 0 && rounds == 1 && config.Listen > maxAge/2:
+		report.add(SeverityWarning, "NOTIFY", "a single announcement within %v, more than half the max-age of %v", config.Listen, maxAge)
+	}
+
+	return nil
+}
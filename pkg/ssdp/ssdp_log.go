@@ -0,0 +1,91 @@
+package ssdp
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// A Logger receives log lines with their fields as alternating keys and
+// values, in the manner of log/slog. Lines logged during a search carry the
+// "search" ID, "st" and "interface" fields of the search first.
+type Logger interface {
+	Log(msg string, keysAndValues ...interface{})
+}
+
+// LoggerFunc adapts a function to a Logger.
+type LoggerFunc func(msg string, keysAndValues ...interface{})
+
+func (l LoggerFunc) Log(msg string, keysAndValues ...interface{}) {
+	l(msg, keysAndValues...)
+}
+
+type loggerOption struct {
+	logger Logger
+}
+
+func (l loggerOption) apply(opts *options) {
+	opts.logger = l.logger
+}
+
+// WithLogger logs the progress of searches to the logger.
+func WithLogger(logger Logger) OptionSSDP {
+	return loggerOption{logger}
+}
+
+// SearchError is the error of a failed search when a logger is configured,
+// identifying the search like its log lines do.
+type SearchError struct {
+	ID        uint64
+	ST        string
+	Interface string
+	Err       error
+}
+
+func (e *SearchError) Error() string {
+	return fmt.Sprintf("search %d for %s: %v", e.ID, e.ST, e.Err)
+}
+
+func (e *SearchError) Unwrap() error {
+	return e.Err
+}
+
+var lastSearchID uint64
+
+type searchScopeKey struct{}
+
+// searchScope identifies a search in its log lines and errors.
+type searchScope struct {
+	id    uint64
+	st    string
+	iface string
+	// errors are only wrapped when logging, so they can be matched to the
+	// log lines
+	logging bool
+}
+
+// withSearchScope returns a context identifying a new search for the target.
+func (opts *options) withSearchScope(ctx context.Context, search string) (context.Context, *searchScope) {
+	scope := &searchScope{id: atomic.AddUint64(&lastSearchID, 1), st: search, iface: opts.iface, logging: opts.logger != nil}
+	return context.WithValue(ctx, searchScopeKey{}, scope), scope
+}
+
+// wrap returns the error identifying the search when logging.
+func (s *searchScope) wrap(err error) error {
+	if err == nil || !s.logging {
+		return err
+	}
+	return &SearchError{ID: s.id, ST: s.st, Interface: s.iface, Err: err}
+}
+
+// log logs the line with the fields of the search of the context, if any.
+func (opts *options) log(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	if opts.logger == nil {
+		return
+	}
+
+	if scope, ok := ctx.Value(searchScopeKey{}).(*searchScope); ok {
+		keysAndValues = append([]interface{}{"search", scope.id, "st", scope.st, "interface", scope.iface}, keysAndValues...)
+	}
+	opts.logger.Log(msg, keysAndValues...)
+}
@@ -0,0 +1,117 @@
+package ssdp
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// The neighbor table of the kernel, only available on Linux.
+const arpTable = "/proc/net/arp"
+
+// How long to wait for the kernel to resolve the neighbors probed.
+const neighborProbeWait = 200 * time.Millisecond
+
+type resolveMACOption bool
+
+func (r resolveMACOption) apply(opts *options) {
+	opts.resolveMAC = bool(r)
+}
+
+// WithResolveMAC looks up the MAC address of each responder in the neighbor
+// table of the system once the search window closes. Responders missing from
+// the table are sent an empty datagram to make the kernel resolve them, and
+// the table is read once more. Only IPv4 neighbors on Linux are resolved;
+// elsewhere, and for responders behind a router, SearchResponse.MAC is nil.
+func WithResolveMAC(resolve bool) OptionSSDP {
+	return resolveMACOption(resolve)
+}
+
+// resolveMACs sets the MAC of the responses found in the neighbor table.
+func (ssdp *SSDP) resolveMACs(ctx context.Context, responses []SearchResponse) {
+	if !ssdp.resolveMAC || len(responses) == 0 {
+		return
+	}
+
+	neighbors := readNeighbors()
+	var missing []*net.UDPAddr
+	for i := range responses {
+		if !setMAC(&responses[i], neighbors) && responses[i].ResponseAddr != nil {
+			missing = append(missing, responses[i].ResponseAddr)
+		}
+	}
+	if len(missing) == 0 || neighbors == nil {
+		return
+	}
+
+	probeNeighbors(missing)
+	select {
+	case <-ssdp.clock.After(neighborProbeWait):
+	case <-ctx.Done():
+		return
+	}
+
+	neighbors = readNeighbors()
+	for i := range responses {
+		if responses[i].MAC == nil {
+			setMAC(&responses[i], neighbors)
+		}
+	}
+}
+
+func setMAC(response *SearchResponse, neighbors map[string]net.HardwareAddr) bool {
+	if response.ResponseAddr == nil {
+		return false
+	}
+	mac, ok := neighbors[response.ResponseAddr.IP.String()]
+	if ok {
+		response.MAC = mac
+	}
+	return ok
+}
+
+// probeNeighbors sends an empty datagram to the discard port of each
+// address, which has the kernel resolve the ones on link.
+func probeNeighbors(addrs []*net.UDPAddr) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for _, addr := range addrs {
+		conn.WriteTo(nil, &net.UDPAddr{IP: addr.IP, Port: 9})
+	}
+}
+
+// readNeighbors returns the complete entries of the neighbor table by IP,
+// or nil when it cannot be read.
+func readNeighbors() map[string]net.HardwareAddr {
+	file, err := os.Open(arpTable)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	neighbors := make(map[string]net.HardwareAddr)
+	scanner := bufio.NewScanner(file)
+	// Skip the header:
+	// IP address       HW type     Flags       HW address            Mask     Device
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Flags 0x0 is an incomplete entry
+		if len(fields) < 4 || fields[2] == "0x0" {
+			continue
+		}
+		mac, err := net.ParseMAC(fields[3])
+		if err != nil || mac.String() == "00:00:00:00:00:00" {
+			continue
+		}
+		neighbors[fields[0]] = mac
+	}
+	return neighbors
+}
@@ -0,0 +1,56 @@
+package ssdp
+
+import (
+	"net/http"
+)
+
+// SearchSender sends an M-SEARCH request.
+type SearchSender func(request *http.Request) error
+
+// SearchReceiver receives a parsed search response.
+type SearchReceiver func(response *SearchResponse)
+
+// SearchMiddleware wraps the sending of M-SEARCH requests and the receiving of
+// their responses, like an http.RoundTripper wrapping another. Either field
+// may be nil.
+type SearchMiddleware struct {
+	// Send wraps next, it may modify the request before passing it on or
+	// abort the search by returning an error without calling next.
+	Send func(next SearchSender) SearchSender
+	// Receive wraps next, it may modify the response before passing it on
+	// or drop it by not calling next.
+	Receive func(next SearchReceiver) SearchReceiver
+}
+
+type middlewareOption []SearchMiddleware
+
+func (m middlewareOption) apply(opts *options) {
+	opts.middleware = append(opts.middleware, m...)
+}
+
+// WithSearchMiddleware registers middleware for searches, for example to add
+// headers for testing, record tracing spans or rewrite the Location host of
+// devices behind NAT. The first registered middleware is the outermost.
+func WithSearchMiddleware(middleware ...SearchMiddleware) OptionSSDP {
+	return middlewareOption(middleware)
+}
+
+// searchSender wraps send in the Send middleware.
+func (opts *options) searchSender(send SearchSender) SearchSender {
+	for i := len(opts.middleware) - 1; i >= 0; i-- {
+		if opts.middleware[i].Send != nil {
+			send = opts.middleware[i].Send(send)
+		}
+	}
+	return send
+}
+
+// searchReceiver wraps receive in the Receive middleware.
+func (opts *options) searchReceiver(receive SearchReceiver) SearchReceiver {
+	for i := len(opts.middleware) - 1; i >= 0; i-- {
+		if opts.middleware[i].Receive != nil {
+			receive = opts.middleware[i].Receive(receive)
+		}
+	}
+	return receive
+}
@@ -0,0 +1,302 @@
+package ssdp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type deliveryMode int
+
+const (
+	deliverBlocking deliveryMode = iota
+	deliverDropOldest
+	deliverCallback
+)
+
+type monitorOptions struct {
+	mode     deliveryMode
+	buffer   int
+	workers  int
+	callback func(Notify)
+	// called for inconsistent announcements, see WithDiagnostics
+	diagnostics func(Diagnostic)
+	// a socket bound elsewhere, see WithMonitorConn
+	conn *net.UDPConn
+	// the window identical announcements are coalesced in, see
+	// WithCoalescing
+	coalesce time.Duration
+	// the most pairs remembered, see WithMonitorMaxTracked
+	maxTracked int
+}
+
+type OptionMonitor interface {
+	apply(*monitorOptions)
+}
+
+type blockingDeliveryOption struct{}
+
+func (blockingDeliveryOption) apply(opts *monitorOptions) {
+	opts.mode = deliverBlocking
+	opts.buffer = 0
+}
+
+type bufferedDeliveryOption int
+
+func (b bufferedDeliveryOption) apply(opts *monitorOptions) {
+	opts.mode = deliverDropOldest
+	opts.buffer = int(b)
+	if opts.buffer < 1 {
+		opts.buffer = 1
+	}
+}
+
+type callbackDeliveryOption struct {
+	callback func(Notify)
+	workers  int
+	queue    int
+}
+
+func (c callbackDeliveryOption) apply(opts *monitorOptions) {
+	opts.mode = deliverCallback
+	opts.callback = c.callback
+	opts.workers = c.workers
+	opts.buffer = c.queue
+}
+
+// WithBlockingDelivery delivers notifications on an unbuffered channel. A
+// slow consumer stalls the read loop. This is the default.
+func WithBlockingDelivery() OptionMonitor {
+	return blockingDeliveryOption{}
+}
+
+// WithBufferedDelivery delivers notifications on a channel buffering up to
+// size notifications. When the buffer is full the oldest notification is
+// dropped.
+func WithBufferedDelivery(size int) OptionMonitor {
+	return bufferedDeliveryOption(size)
+}
+
+// WithCallbackDelivery calls the callback from a pool of workers. Up to queue
+// notifications wait for a free worker, further notifications are dropped.
+func WithCallbackDelivery(callback func(Notify), workers int, queue int) OptionMonitor {
+	return callbackDeliveryOption{callback: callback, workers: workers, queue: queue}
+}
+
+// A Monitor listens for NOTIFY announcements on the SSDP multicast group.
+type Monitor struct {
+	opts        *monitorOptions
+	conn        *net.UDPConn
+	includeSelf bool
+	local       map[string]bool
+	// the USNs announced alive, for diagnostics, and whether some were
+	// not remembered for lack of room
+	alive     map[string]bool
+	aliveFull bool
+	// whether the notification being read could not be tracked, see
+	// WithMonitorMaxTracked
+	untracked bool
+	clock     Clock
+	// the alive announcements delivered recently, see WithCoalescing
+	recent map[string]coalesced
+	pruned time.Time
+	// when the monitor started and on which interface, see CheckTraffic
+	started time.Time
+	iface   string
+
+	notifications chan Notify
+	workers       sync.WaitGroup
+	closing       chan struct{}
+	done          chan struct{}
+	closeOnce     sync.Once
+	closeErr      error
+
+	received    uint64
+	dropped     uint64
+	parseErrors uint64
+	coalesced   uint64
+	overflow    uint64
+}
+
+// MonitorStats are the counters of a Monitor.
+type MonitorStats struct {
+	// Number of UDP packets received
+	Received uint64
+	// Number of notifications dropped because the consumer was too slow
+	Dropped uint64
+	// Number of packets that could not be parsed as a NOTIFY
+	ParseErrors uint64
+	// Number of announcements not delivered as they repeated an earlier
+	// one, see WithCoalescing
+	Coalesced uint64
+	// Number of announcements whose pair could not be tracked, see
+	// WithMonitorMaxTracked
+	Overflow uint64
+}
+
+// Monitor starts listening for announcements on the multicast group and port
+// of the SSDP client.
+func (ssdp *SSDP) Monitor(opts ...OptionMonitor) (*Monitor, error) {
+	options := &monitorOptions{}
+
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	var iface *net.Interface
+	if ssdp.iface != "" {
+		var err error
+		iface, err = net.InterfaceByName(ssdp.iface)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	group := &net.UDPAddr{IP: net.ParseIP(ssdp.broadcastIp), Port: ssdp.port}
+	conn := options.conn
+	if conn != nil {
+		if err := joinGroup(conn, iface, group); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		conn, err = net.ListenMulticastUDP("udp", iface, group)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	monitor := &Monitor{
+		opts:        options,
+		conn:        conn,
+		includeSelf: ssdp.includeSelf,
+		local:       localAddrs(),
+		alive:       make(map[string]bool),
+		clock:       ssdp.clock,
+		recent:      make(map[string]coalesced),
+		started:     ssdp.clock.Now(),
+		iface:       ssdp.iface,
+		closing:     make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	if options.mode == deliverCallback {
+		queue := make(chan Notify, options.buffer)
+		monitor.notifications = queue
+		for i := 0; i < options.workers || i == 0; i++ {
+			monitor.workers.Add(1)
+			go func() {
+				defer monitor.workers.Done()
+				for notify := range queue {
+					options.callback(notify)
+				}
+			}()
+		}
+	} else {
+		monitor.notifications = make(chan Notify, options.buffer)
+	}
+
+	go monitor.readLoop()
+
+	return monitor, nil
+}
+
+// Notifications returns the channel notifications are delivered on. It is
+// closed when the monitor is closed. With callback delivery nothing is sent
+// on it.
+func (m *Monitor) Notifications() <-chan Notify {
+	if m.opts.mode == deliverCallback {
+		return nil
+	}
+	return m.notifications
+}
+
+// Stats returns the current counters of the monitor.
+func (m *Monitor) Stats() MonitorStats {
+	return MonitorStats{
+		Received:    atomic.LoadUint64(&m.received),
+		Dropped:     atomic.LoadUint64(&m.dropped),
+		ParseErrors: atomic.LoadUint64(&m.parseErrors),
+		Coalesced:   atomic.LoadUint64(&m.coalesced),
+		Overflow:    atomic.LoadUint64(&m.overflow),
+	}
+}
+
+// Close stops listening and waits for pending callbacks to finish. Closing
+// again waits the same way and returns the same error.
+func (m *Monitor) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.closing)
+		m.closeErr = m.conn.Close()
+	})
+	<-m.done
+	m.workers.Wait()
+	return m.closeErr
+}
+
+func (m *Monitor) readLoop() {
+	defer close(m.done)
+	defer close(m.notifications)
+
+	buf := make([]byte, MaxMessageSize)
+	for {
+		rlen, addr, err := m.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		atomic.AddUint64(&m.received, 1)
+		if !m.includeSelf && isSelf(m.local, addr) {
+			continue
+		}
+
+		notify, err := ScanNotify(buf[:rlen], addr)
+		if err != nil {
+			atomic.AddUint64(&m.parseErrors, 1)
+			continue
+		}
+
+		m.untracked = false
+		m.diagnose(*notify)
+		coalesced := m.coalesce(*notify)
+		if m.untracked {
+			atomic.AddUint64(&m.overflow, 1)
+		}
+		if coalesced {
+			atomic.AddUint64(&m.coalesced, 1)
+			continue
+		}
+		m.deliver(*notify)
+	}
+}
+
+func (m *Monitor) deliver(notify Notify) {
+	switch m.opts.mode {
+	case deliverBlocking:
+		select {
+		case m.notifications <- notify:
+		case <-m.closing:
+		}
+	case deliverDropOldest:
+		for {
+			select {
+			case m.notifications <- notify:
+				return
+			default:
+			}
+			// Make room by dropping the oldest notification
+			select {
+			case <-m.notifications:
+				atomic.AddUint64(&m.dropped, 1)
+			default:
+			}
+		}
+	case deliverCallback:
+		select {
+		case m.notifications <- notify:
+		default:
+			atomic.AddUint64(&m.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package ssdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The number of unmatched responses buffered for a consumer that is not
+// reading them.
+const unmatchedBuffer = 64
+
+// ErrMuxClosed is returned by searches of a closed SearchMux.
+var ErrMuxClosed = errors.New("ssdp: search mux closed")
+
+// A SearchMux runs concurrent searches over a single socket. Responses don't
+// say which search they answer, so each is handed to the searches whose
+// target matches its ST and whose window it arrived in. Concurrent searches
+// for overlapping targets, such as ssdp:all and anything else, each receive
+// the responses of both.
+type SearchMux struct {
+	ssdp    *SSDP
+	conn    *net.UDPConn
+	group   string
+	release func()
+	stop    func()
+
+	mu       sync.Mutex
+	searches map[*muxSearch]bool
+	err      error
+	closed   bool
+
+	unmatched chan SearchResponse
+	failed    chan struct{}
+	done      chan struct{}
+	finished  chan struct{}
+}
+
+type muxSearch struct {
+	st        string
+	sent      time.Time
+	responses []SearchResponse
+}
+
+// matches reports whether the response answers the search.
+func (s *muxSearch) matches(response *SearchResponse) bool {
+	return MatchesST(response.ST, s.st)
+}
+
+// NewSearchMux binds the search socket and keeps it until the mux is closed.
+// Only the first group is searched, see WithGroups.
+func (ssdp *SSDP) NewSearchMux() (*SearchMux, error) {
+	conn, release, err := ssdp.listenForSearchResponses()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &SearchMux{
+		ssdp:      ssdp,
+		conn:      conn,
+		group:     ssdp.searchGroups()[0],
+		release:   release,
+		searches:  make(map[*muxSearch]bool),
+		unmatched: make(chan SearchResponse, unmatchedBuffer),
+		failed:    make(chan struct{}),
+		done:      make(chan struct{}),
+		finished:  make(chan struct{}),
+	}
+
+	packets := make(chan packet)
+	m.stop = readPackets(groupReader{newControlReader(conn), m.group}, packets)
+
+	var local map[string]bool
+	if !ssdp.includeSelf {
+		local = localAddrs()
+	}
+
+	go m.dispatch(packets, local)
+
+	return m, nil
+}
+
+// dispatch hands the received responses to the matching searches.
+func (m *SearchMux) dispatch(packets <-chan packet, local map[string]bool) {
+	defer close(m.finished)
+	defer close(m.unmatched)
+
+	for {
+		var p packet
+		select {
+		case p = <-packets:
+		case <-m.done:
+			return
+		}
+
+		if p.transient {
+			continue
+		}
+		if p.err != nil {
+			m.mu.Lock()
+			m.err = p.err
+			m.mu.Unlock()
+			close(m.failed)
+			return
+		}
+
+		if !m.ssdp.includeSelf && isSelf(local, p.addr) {
+			continue
+		}
+		response, err := ParseSearchResponse(bytes.NewReader(p.data), p.addr)
+		if err != nil || m.ssdp.filtered(response) {
+			continue
+		}
+		response.Findings = m.ssdp.validate(p.data)
+		response.Group = p.group
+		response.InterfaceIndex = p.ifIndex
+		response.LocalAddr = p.local
+		response.Received = m.ssdp.clock.Now()
+
+		matched := false
+		m.mu.Lock()
+		for search := range m.searches {
+			if !search.matches(response) {
+				continue
+			}
+			matched = true
+			if m.ssdp.maxResponses > 0 && len(search.responses) >= m.ssdp.maxResponses {
+				continue
+			}
+			answer := *response
+			answer.RTT = answer.Received.Sub(search.sent)
+			answer.SearchST = search.st
+			search.responses = append(search.responses, answer)
+		}
+		m.mu.Unlock()
+
+		if !matched {
+			select {
+			case m.unmatched <- *response:
+			default:
+			}
+		}
+	}
+}
+
+// Search searches like SearchContext, sharing the socket with the other
+// searches of the mux.
+func (m *SearchMux) Search(ctx context.Context, search string) ([]SearchResponse, error) {
+	request, broadcastAddr, err := m.ssdp.buildSearchRequest(search, m.group)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.ssdp.limiter.wait(ctx, m.ssdp.clock); err != nil {
+		return nil, err
+	}
+
+	s := &muxSearch{st: search, sent: m.ssdp.clock.Now()}
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrMuxClosed
+	}
+	m.searches[s] = true
+	m.mu.Unlock()
+
+	finish := func() []SearchResponse {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.searches, s)
+		return s.responses
+	}
+
+	send := m.ssdp.searchSender(func(request *http.Request) error {
+		searchBytes, err := encodeSearchRequest(request)
+		if err != nil {
+			return err
+		}
+		_, err = m.conn.WriteTo(searchBytes, broadcastAddr)
+		return err
+	})
+	if err := send(request); err != nil {
+		finish()
+		return nil, err
+	}
+
+	select {
+	case <-m.ssdp.clock.After(m.ssdp.searchWindow()):
+		return finish(), nil
+	case <-ctx.Done():
+		finish()
+		return nil, ctx.Err()
+	case <-m.failed:
+		finish()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return nil, m.err
+	case <-m.done:
+		finish()
+		return nil, ErrMuxClosed
+	}
+}
+
+// Unmatched returns the responses that matched none of the running searches,
+// such as late answers to searches that already ended. They are dropped when
+// not read. The channel is closed when the mux is closed.
+func (m *SearchMux) Unmatched() <-chan SearchResponse {
+	return m.unmatched
+}
+
+// Close ends the running searches and releases the socket.
+func (m *SearchMux) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.mu.Unlock()
+
+	close(m.done)
+	m.stop()
+	<-m.finished
+	m.release()
+	return nil
+}
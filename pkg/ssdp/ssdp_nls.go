@@ -0,0 +1,55 @@
+package ssdp
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The OPT header declaring the 01 namespace of the NLS header.
+const nlsOpt = `"http://schemas.upnp.org/upnp/1/0/"; ns=01`
+
+// parseNLS returns the network location signature of a message. It is sent in
+// an extension header whose namespace prefix is declared by the OPT header,
+// "01-NLS" in practice, with some stacks sending a bare NLS header instead.
+func parseNLS(headers http.Header) string {
+	if nls := headers.Get(nlsPrefix(headers.Get("opt")) + "-nls"); nls != "" {
+		return nls
+	}
+	return headers.Get("nls")
+}
+
+// nlsPrefix returns the namespace prefix declared by the OPT header.
+func nlsPrefix(opt string) string {
+	prefix := "01"
+	for opt != "" {
+		var field string
+		field, opt, _ = strings.Cut(opt, ";")
+		field = strings.TrimSpace(field)
+		if strings.HasPrefix(field, "ns=") {
+			prefix = strings.TrimSpace(strings.TrimPrefix(field, "ns="))
+		}
+	}
+	return prefix
+}
+
+// writeNLS writes the NLS header with the OPT header declaring it, nothing
+// when the signature is empty.
+func writeNLS(b *strings.Builder, nls string) {
+	if nls == "" {
+		return
+	}
+	fmt.Fprintf(b, "OPT: %s\r\n", nlsOpt)
+	fmt.Fprintf(b, "01-NLS: %s\r\n", nls)
+}
+
+// newNLS returns a random network location signature, which changes every
+// time a host starts announcing.
+func newNLS() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,92 @@
+package ssdp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Notification sub types carried in the NTS header.
+const (
+	NTSAlive  = "ssdp:alive"
+	NTSByeBye = "ssdp:byebye"
+	NTSUpdate = "ssdp:update"
+)
+
+// A NOTIFY announcement sent by a device implementing SSDP.
+type Notify struct {
+	Host     string
+	Control  string
+	Server   string
+	NT       string
+	NTS      string
+	USN      string
+	Location *url.URL
+	// The network location signature, see SearchResponse.NLS
+	NLS string
+	// The address the announcement was sent from
+	Addr *net.UDPAddr
+	// The port the device answers unicast searches on, see
+	// SearchResponse.SearchPort
+	SearchPort int
+	// The BOOTID.UPNP.ORG, see SearchResponse.BootID, and of an update the
+	// NEXTBOOTID.UPNP.ORG the device is about to use
+	BootID     int
+	NextBootID int
+	// The CONFIGID.UPNP.ORG, see SearchResponse.ConfigID
+	ConfigID int
+}
+
+// MaxAge returns the max-age directive of the Cache-Control header, or zero
+// when it is missing or malformed.
+func (n Notify) MaxAge() time.Duration {
+	return parseMaxAge(n.Control)
+}
+
+// ParseNotify parses a raw NOTIFY request received from the given address.
+// Messages exceeding the size limits are rejected.
+func ParseNotify(httpRequest io.Reader, addr *net.UDPAddr) (*Notify, error) {
+	reader, err := limitMessage(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.ReadRequest(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Method != "NOTIFY" {
+		return nil, fmt.Errorf("unexpected method %q", request.Method)
+	}
+
+	headers := request.Header
+
+	notify := &Notify{}
+
+	notify.Host = request.Host
+	notify.Control = headers.Get("cache-control")
+	notify.Server = headers.Get("server")
+	notify.NT = headers.Get("nt")
+	notify.NTS = headers.Get("nts")
+	notify.USN = headers.Get("usn")
+	notify.NLS = parseNLS(headers)
+	notify.SearchPort = parseSearchPort(headers.Get("searchport.upnp.org"))
+	notify.BootID = parseBootID(headers.Get("bootid.upnp.org"))
+	notify.NextBootID = parseBootID(headers.Get("nextbootid.upnp.org"))
+	notify.ConfigID = parseConfigID(headers.Get("configid.upnp.org"))
+	notify.Addr = addr
+
+	if location := headers.Get("location"); location != "" {
+		notify.Location, err = url.Parse(location)
+		if err != nil {
+			return nil, err
+		}
+		notify.Location = addZone(notify.Location, addr)
+	}
+
+	return notify, nil
+}
@@ -0,0 +1,46 @@
+package ssdp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+var ErrHostnameLookup = errors.New("ssdp: hostname lookups are disabled")
+
+type numericOption bool
+
+func (n numericOption) apply(opts *options) {
+	opts.numeric = bool(n)
+}
+
+// WithNumericAddresses disables hostname lookups. The multicast address and
+// the hosts of device locations must then be IP addresses, anything else
+// fails with ErrHostnameLookup instead of waiting for DNS.
+func WithNumericAddresses(numeric bool) OptionSSDP {
+	return numericOption(numeric)
+}
+
+// resolveUDPAddr resolves the host and port, refusing hostnames in numeric
+// mode.
+func (opts *options) resolveUDPAddr(host string, port int) (*net.UDPAddr, error) {
+	if opts.numeric {
+		ip, zone := splitZone(host)
+		if ip == nil {
+			return nil, fmt.Errorf("%w: %s", ErrHostnameLookup, host)
+		}
+		return &net.UDPAddr{IP: ip, Port: port, Zone: zone}, nil
+	}
+
+	return net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// checkLocation refuses locations with a hostname in numeric mode.
+func (opts *options) checkLocation(location url.URL) error {
+	if ip, _ := splitZone(location.Hostname()); opts.numeric && ip == nil {
+		return fmt.Errorf("%w: %s", ErrHostnameLookup, location.Hostname())
+	}
+	return nil
+}
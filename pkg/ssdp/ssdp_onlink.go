@@ -0,0 +1,53 @@
+package ssdp
+
+import (
+	"net"
+)
+
+type onLinkOption bool
+
+func (o onLinkOption) apply(opts *options) {
+	opts.onLinkOnly = bool(o)
+}
+
+// WithOnLinkOnly drops search responses whose source address is not within
+// the prefixes of the local interfaces. Devices answer from the local
+// network, so anything else is likely a spoofed response from the WAN
+// reaching a socket bound to all addresses. Dropped packets are counted in
+// Progress.OffLink.
+func WithOnLinkOnly(onLinkOnly bool) OptionSSDP {
+	return onLinkOption(onLinkOnly)
+}
+
+// localPrefixes returns the networks of the local interfaces.
+func localPrefixes() []*net.IPNet {
+	var prefixes []*net.IPNet
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return prefixes
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			prefixes = append(prefixes, ipNet)
+		}
+	}
+
+	return prefixes
+}
+
+// isOnLink reports whether the address is within one of the prefixes.
+func isOnLink(prefixes []*net.IPNet, addr *net.UDPAddr) bool {
+	if addr == nil {
+		return false
+	}
+
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
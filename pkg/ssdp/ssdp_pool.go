@@ -0,0 +1,132 @@
+package ssdp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrPoolClosed = errors.New("ssdp: connection pool closed")
+
+// ConnPool keeps bound sockets open between searches, one per local address.
+// Searches on the same address take turns using its socket, which avoids
+// rebinding it for every search and the EADDRINUSE errors of concurrent
+// searches. Responses arriving after a search ended are discarded before the
+// socket is reused.
+type ConnPool struct {
+	mu     sync.Mutex
+	slots  map[string]chan *net.UDPConn
+	conns  []*net.UDPConn
+	closed bool
+}
+
+func NewConnPool() *ConnPool {
+	return &ConnPool{
+		slots: make(map[string]chan *net.UDPConn),
+	}
+}
+
+type poolOption struct {
+	pool *ConnPool
+}
+
+func (p poolOption) apply(opts *options) {
+	opts.pool = p.pool
+}
+
+// WithConnPool makes searches borrow their sockets from the pool. The pool
+// can be shared between several SSDP clients.
+func WithConnPool(pool *ConnPool) OptionSSDP {
+	return poolOption{pool}
+}
+
+// get borrows the socket bound to addr, binding it on first use and waiting
+// while another search is using it.
+func (p *ConnPool) get(addr *net.UDPAddr) (*net.UDPConn, error) {
+	key := addr.String()
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+
+	slot, ok := p.slots[key]
+	if !ok {
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			p.mu.Unlock()
+			return nil, err
+		}
+		p.slots[key] = make(chan *net.UDPConn, 1)
+		p.conns = append(p.conns, conn)
+		p.mu.Unlock()
+		return conn, nil
+	}
+	p.mu.Unlock()
+
+	conn, ok := <-slot
+	if !ok {
+		return nil, ErrPoolClosed
+	}
+	if err := drain(conn); err != nil {
+		p.put(addr, conn)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// drain discards the packets queued on a socket since its last search, late
+// responses that would otherwise be taken for answers to the next one.
+func drain(conn *net.UDPConn) error {
+	buf := make([]byte, MaxMessageSize)
+	// Queued packets are read at once, the deadline only ends the last read
+	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return err
+	}
+	for {
+		if _, _, err := conn.ReadFromUDP(buf); err != nil {
+			break
+		}
+	}
+	return conn.SetReadDeadline(time.Time{})
+}
+
+// put returns a borrowed socket to the pool.
+func (p *ConnPool) put(addr *net.UDPAddr, conn *net.UDPConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	p.slots[addr.String()] <- conn
+}
+
+// Close closes all sockets of the pool. Searches waiting for a socket fail
+// with ErrPoolClosed.
+func (p *ConnPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	var firstErr error
+	for _, conn := range p.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, slot := range p.slots {
+		close(slot)
+	}
+
+	return firstErr
+}
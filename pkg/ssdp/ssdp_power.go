@@ -0,0 +1,104 @@
+package ssdp
+
+import (
+	"net"
+	"time"
+)
+
+// The first port UDA 1.1 allows for unicast searches, and how many ports
+// from it are tried for a PowerProfile without a SearchPort.
+const (
+	firstSearchPort       = 49152
+	maxSearchPortAttempts = 64
+)
+
+// A PowerProfile tunes how an Advertiser announces its devices, see
+// WithPowerProfile.
+type PowerProfile struct {
+	// The max-age of the devices that have none, 30 minutes when zero
+	MaxAge time.Duration
+	// Announcements are sent at multiples of the interval, e.g. the wake
+	// windows of a device sleeping in between, still renewing them before
+	// they expire. When no window falls within half the max-age they are
+	// sent whenever due. Zero sends them whenever due.
+	WakeInterval time.Duration
+	// Whether to answer unicast searches, right away as they need no
+	// random delay. Multicast searches are left to the announcements.
+	UnicastSearches bool
+	// The port unicast searches are answered on, announced in
+	// SEARCHPORT.UPNP.ORG from UDA 1.1. The first free port from 49152
+	// when zero.
+	SearchPort int
+}
+
+// LowPower returns the profile of a battery powered device waking every
+// interval: announcements valid for 2 hours, sent in the wake windows, and
+// answers to unicast searches only.
+func LowPower(wakeInterval time.Duration) PowerProfile {
+	return PowerProfile{
+		MaxAge:          2 * time.Hour,
+		WakeInterval:    wakeInterval,
+		UnicastSearches: true,
+	}
+}
+
+type powerProfileOption PowerProfile
+
+func (p powerProfileOption) apply(opts *options) {
+	opts.power = PowerProfile(p)
+}
+
+// WithPowerProfile sets the profile the advertisers of the client announce
+// with, e.g. LowPower.
+func WithPowerProfile(profile PowerProfile) OptionSSDP {
+	return powerProfileOption(profile)
+}
+
+func (p PowerProfile) maxAge() time.Duration {
+	if p.MaxAge <= 0 {
+		return 30 * time.Minute
+	}
+	return p.MaxAge
+}
+
+// align returns the last wake window before the time, or else the first one
+// after now that comes before the announcements expire. The time itself is
+// returned when no window falls in between, as with a WakeInterval longer
+// than half the max-age.
+func (p PowerProfile) align(at time.Time, now time.Time, expires time.Time) time.Time {
+	if p.WakeInterval <= 0 {
+		return at
+	}
+	if aligned := at.Truncate(p.WakeInterval); aligned.After(now) {
+		return aligned
+	}
+	if next := now.Truncate(p.WakeInterval).Add(p.WakeInterval); next.Before(expires) {
+		return next
+	}
+	return at
+}
+
+// listenSearchPort binds the port for unicast searches.
+func (p PowerProfile) listenSearchPort() (*net.UDPConn, error) {
+	if p.SearchPort != 0 {
+		return net.ListenUDP("udp4", &net.UDPAddr{Port: p.SearchPort})
+	}
+
+	var err error
+	for port := firstSearchPort; port < firstSearchPort+maxSearchPortAttempts; port++ {
+		var conn *net.UDPConn
+		if conn, err = net.ListenUDP("udp4", &net.UDPAddr{Port: port}); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// SearchPort returns the port unicast searches are answered on, zero when
+// they are not, see PowerProfile.
+func (a *Advertiser) SearchPort() int {
+	if a.searchConn == nil {
+		return 0
+	}
+	return a.searchConn.LocalAddr().(*net.UDPAddr).Port
+}
@@ -0,0 +1,112 @@
+package ssdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrNoResponse is returned by Probe when the device did not answer in time.
+var ErrNoResponse = errors.New("ssdp: no response")
+
+// Probe sends a unicast search to the device at addr and returns its first
+// response. The address of a known device is its SearchAddr, which honors
+// the SEARCHPORT.UPNP.ORG it announced. Unicast searches carry no MX and
+// UDA 1.1 devices answer them immediately, which makes Probe a fast check
+// whether a known device is still alive. It gives up with ErrNoResponse
+// after the search timeout, or with the error of the context when it is
+// done first.
+func (ssdp *SSDP) Probe(ctx context.Context, addr *net.UDPAddr, search string) (*SearchResponse, error) {
+	local := &net.UDPAddr{}
+	if ssdp.iface != "" {
+		ip, err := InterfaceIP(ssdp.iface)
+		if err != nil {
+			return nil, err
+		}
+		local.IP = ip
+	}
+
+	conn, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	reportICMPErrors(conn)
+
+	// Unblock the read when the search times out or the context is done
+	var timeout <-chan time.Time
+	if ssdp.timeout > 0 {
+		timeout = ssdp.clock.After(ssdp.timeout)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-timeout:
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+		conn.SetReadDeadline(time.Now())
+	}()
+
+	if err := ssdp.limiter.wait(ctx, ssdp.clock); err != nil {
+		return nil, err
+	}
+	sent := ssdp.clock.Now()
+	send := ssdp.searchSender(func(request *http.Request) error {
+		searchBytes, err := encodeSearchRequest(request)
+		if err != nil {
+			return err
+		}
+		_, err = conn.WriteTo(searchBytes, addr)
+		return err
+	})
+	if err := send(ssdp.newSearchRequest(search, addr)); err != nil {
+		return nil, err
+	}
+
+	var found *SearchResponse
+	receive := ssdp.searchReceiver(func(response *SearchResponse) {
+		found = response
+	})
+
+	buf := make([]byte, MaxMessageSize)
+	for found == nil {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, ErrNoResponse
+			}
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				ssdp.unreachable.add(addr.String(), ssdp.clock.Now())
+				return nil, ErrPortUnreachable
+			}
+			return nil, err
+		}
+		if !from.IP.Equal(addr.IP) {
+			continue
+		}
+
+		response, err := ParseSearchResponse(bytes.NewReader(buf[:n]), from)
+		if err != nil {
+			continue
+		}
+		response.Findings = ssdp.validate(buf[:n])
+		response.LocalAddr = conn.LocalAddr().(*net.UDPAddr)
+		response.Received = ssdp.clock.Now()
+		response.RTT = response.Received.Sub(sent)
+		response.SearchST = search
+		receive(response)
+	}
+
+	return found, nil
+}
@@ -0,0 +1,93 @@
+package ssdp
+
+import (
+	"time"
+)
+
+// How often progress is reported while no packets are arriving.
+const progressInterval = 250 * time.Millisecond
+
+// Progress describes the state of a running search.
+type Progress struct {
+	// Time since the search request was sent
+	Elapsed time.Duration
+	// Number of UDP packets received so far
+	Packets int
+	// Number of packets that could not be parsed as a search response
+	ParseErrors int
+	// Number of unique devices (by location) that responded so far
+	Devices int
+	// Number of packets dropped for coming from outside the local network,
+	// see WithOnLinkOnly
+	OffLink int
+	// Number of responses dropped for exceeding WithMaxResponses
+	Overflow int
+	// Number of responses dropped by WithExclude or WithIncludeOnly
+	Filtered int
+}
+
+type progressOption func(Progress)
+
+func (p progressOption) apply(opts *options) {
+	opts.progress = p
+}
+
+// WithProgress registers a callback that is invoked while a search is running.
+// It is called for every received packet and at least every 250 milliseconds
+// otherwise, with a final call when the search window closes.
+func WithProgress(progress func(Progress)) OptionSSDP {
+	return progressOption(progress)
+}
+
+type searchProgress struct {
+	callback    func(Progress)
+	clock       Clock
+	start       time.Time
+	packets     int
+	parseErrors int
+	offLink     int
+	overflow    int
+	filtered    int
+	locations   map[string]bool
+}
+
+func newSearchProgress(callback func(Progress), clock Clock) *searchProgress {
+	return &searchProgress{
+		callback:  callback,
+		clock:     clock,
+		start:     clock.Now(),
+		locations: make(map[string]bool),
+	}
+}
+
+// tick returns a channel that fires when the next periodic report is due, or
+// nil when no callback is registered.
+func (p *searchProgress) tick() <-chan time.Time {
+	if p.callback == nil {
+		return nil
+	}
+
+	return p.clock.After(progressInterval)
+}
+
+func (p *searchProgress) seen(response *SearchResponse) {
+	if response.Location != nil {
+		p.locations[response.Location.String()] = true
+	}
+}
+
+func (p *searchProgress) report() {
+	if p.callback == nil {
+		return
+	}
+
+	p.callback(Progress{
+		Elapsed:     p.clock.Now().Sub(p.start),
+		Packets:     p.packets,
+		ParseErrors: p.parseErrors,
+		Devices:     len(p.locations),
+		OffLink:     p.offLink,
+		Overflow:    p.overflow,
+		Filtered:    p.filtered,
+	})
+}
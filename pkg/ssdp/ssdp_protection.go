@@ -0,0 +1,37 @@
+package ssdp
+
+import (
+	"strings"
+)
+
+// The service type prefix of the UPnP Device Protection service.
+const DeviceProtectionServiceType = "urn:schemas-upnp-org:service:DeviceProtection:"
+
+// DeviceProtection returns the Device Protection service of the device, if
+// it implements one. Devices with this service only accept control and
+// eventing from authenticated control points with the required roles, over
+// TLS.
+func (d Device) DeviceProtection() (Service, bool) {
+	for _, service := range d.Services {
+		if strings.HasPrefix(service.ServiceType, DeviceProtectionServiceType) {
+			return service, true
+		}
+	}
+	return Service{}, false
+}
+
+// RequiresTLS reports whether the device's control and eventing traffic
+// should be routed over TLS, because it implements Device Protection or its
+// description is served over HTTPS.
+func (d Device) RequiresTLS() bool {
+	if _, ok := d.DeviceProtection(); ok {
+		return true
+	}
+	return d.Location != nil && d.Location.Scheme == "https"
+}
+
+// Secure reports whether the responding device advertised a secure HTTPS
+// description location.
+func (r SearchResponse) Secure() bool {
+	return r.SecureLocation != nil
+}
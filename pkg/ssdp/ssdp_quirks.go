@@ -0,0 +1,206 @@
+package ssdp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// A Quirk describes known deviations from the UPnP specification of a family
+// of devices. It applies to devices whose Server header or model name
+// contains the given values. The model name is only known once a description
+// was fetched, so quirks matched on it apply to the later requests to the
+// host. Deviations common to all devices, such as a missing EXT header,
+// header names in any case or a description served with the wrong
+// Content-Type, are tolerated without a quirk.
+type Quirk struct {
+	Name string
+	// Substring of the Server header of matching devices
+	Server string
+	// Substring of the model name of matching devices
+	ModelName string
+	// Ports to try when the description can't be fetched from the advertised
+	// location, for devices that move their HTTP server between ports
+	DescriptionPorts []int
+	// Close HTTP connections after each request, for embedded servers that
+	// break when a connection is reused
+	NoKeepAlive bool
+}
+
+// DefaultQuirks are the quirks of commonly found consumer devices.
+var DefaultQuirks = []Quirk{
+	{
+		// Belkin WeMo devices move their setup.xml between ports 49152-49155
+		// after a reboot while still answering with a stale location.
+		Name:             "wemo",
+		Server:           "Unspecified, UPnP/1.0, Unspecified",
+		DescriptionPorts: []int{49152, 49153, 49154, 49155},
+	},
+}
+
+type quirksOption []Quirk
+
+func (q quirksOption) apply(opts *options) {
+	opts.quirks = q
+}
+
+// WithQuirks replaces the DefaultQuirks used to tolerate known device
+// deviations.
+func WithQuirks(quirks ...Quirk) OptionSSDP {
+	return quirksOption(quirks)
+}
+
+func (q Quirk) matches(server string, modelName string) bool {
+	if q.Server != "" && strings.Contains(server, q.Server) {
+		return true
+	}
+	return q.ModelName != "" && strings.Contains(modelName, q.ModelName)
+}
+
+// Quirks returns the combination of all quirks matching the given Server
+// header and model name. Either may be empty when it isn't known yet.
+func (ssdp *SSDP) Quirks(server string, modelName string) Quirk {
+	return ssdp.quirksOf(server, modelName)
+}
+
+func (opts *options) quirksOf(server string, modelName string) Quirk {
+	combined := Quirk{}
+
+	for _, quirk := range opts.quirks {
+		if quirk.matches(server, modelName) {
+			combined = combined.combine(quirk)
+		}
+	}
+
+	return combined
+}
+
+// combine returns the quirk with the name and deviations of the other added.
+func (q Quirk) combine(other Quirk) Quirk {
+	switch {
+	case other.Name == "":
+	case q.Name == "":
+		q.Name = other.Name
+	case !strings.Contains(q.Name, other.Name):
+		q.Name += "," + other.Name
+	}
+	q.DescriptionPorts = append(q.DescriptionPorts[:len(q.DescriptionPorts):len(q.DescriptionPorts)], other.DescriptionPorts...)
+	q.NoKeepAlive = q.NoKeepAlive || other.NoKeepAlive
+	return q
+}
+
+// learnQuirks remembers the quirks matching the model name of the device
+// fetched from the host, for the later requests to it.
+func (opts *options) learnQuirks(host string, device *Device) {
+	quirk := opts.quirksOf("", device.ModelName)
+	if quirk.Name == "" {
+		return
+	}
+	opts.hostQuirks.learn(host, quirk)
+	if quirk.NoKeepAlive {
+		opts.noKeepAlive.add(host)
+	}
+}
+
+// hostQuirks are the quirks learned per hostname, shared by the copies of the
+// options.
+type hostQuirks struct {
+	mu     sync.RWMutex
+	quirks map[string]Quirk
+}
+
+func (h *hostQuirks) learn(host string, quirk Quirk) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.quirks == nil {
+		h.quirks = make(map[string]Quirk)
+	}
+	h.quirks[host] = quirk
+}
+
+func (h *hostQuirks) of(host string) Quirk {
+	if h == nil {
+		return Quirk{}
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.quirks[host]
+}
+
+// descriptionLocations returns the location followed by the alternative
+// locations to try according to the quirk.
+func (q Quirk) descriptionLocations(location url.URL) []url.URL {
+	locations := []url.URL{location}
+
+	for _, port := range q.DescriptionPorts {
+		if strconv.Itoa(port) == location.Port() {
+			continue
+		}
+		alternative := location
+		alternative.Host = net.JoinHostPort(location.Hostname(), strconv.Itoa(port))
+		locations = append(locations, alternative)
+	}
+
+	return locations
+}
+
+// repairHeaders removes whitespace between header names and the colon, which
+// some devices send but the HTTP parser rejects.
+func repairHeaders(data []byte) []byte {
+	end := bytes.Index(data, []byte("\n\r\n"))
+	if end < 0 {
+		end = bytes.Index(data, []byte("\n\n"))
+	}
+	if end < 0 {
+		end = len(data)
+	}
+
+	lines := bytes.SplitAfter(data[:end], []byte("\n"))
+	// The start line is never changed
+	for i := 1; i < len(lines); i++ {
+		colon := bytes.IndexByte(lines[i], ':')
+		if colon <= 0 {
+			continue
+		}
+		name := bytes.TrimRight(lines[i][:colon], " \t")
+		if len(name) != colon {
+			lines[i] = append(append([]byte{}, name...), lines[i][colon:]...)
+		}
+	}
+
+	repaired := bytes.Join(lines, nil)
+	return append(repaired, data[end:]...)
+}
+
+// charsetReader decodes the single byte encodings that some devices declare
+// in their descriptions.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "windows-1252", "cp1252":
+		return charmap.Windows1252.NewDecoder().Reader(input), nil
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1", "us-ascii", "ascii":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		decoded := make([]byte, 0, len(data))
+		for _, c := range data {
+			decoded = utf8.AppendRune(decoded, rune(c))
+		}
+		return bytes.NewReader(decoded), nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
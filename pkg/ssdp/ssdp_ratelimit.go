@@ -0,0 +1,71 @@
+package ssdp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// searchLimiter is a token bucket for outgoing searches.
+type searchLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+type searchRateLimitOption struct {
+	perSecond float64
+	burst     int
+}
+
+func (s searchRateLimitOption) apply(opts *options) {
+	burst := float64(s.burst)
+	if burst < 1 {
+		burst = 1
+	}
+	opts.limiter = &searchLimiter{rate: s.perSecond, burst: burst, tokens: burst}
+}
+
+// WithSearchRateLimit limits the M-SEARCH packets sent by the SSDP client to
+// perSecond on average, with bursts of up to burst packets. The limit is
+// shared by all searches of the client, which wait for their turn, so a busy
+// retry loop cannot flood the network.
+func WithSearchRateLimit(perSecond float64, burst int) OptionSSDP {
+	return searchRateLimitOption{perSecond: perSecond, burst: burst}
+}
+
+// wait blocks until a packet may be sent or the context is done.
+func (l *searchLimiter) wait(ctx context.Context, clock Clock) error {
+	if l == nil || l.rate <= 0 {
+		return nil
+	}
+
+	for {
+		l.mu.Lock()
+		now := clock.Now()
+		if !l.last.IsZero() {
+			l.tokens += now.Sub(l.last).Seconds() * l.rate
+			if l.tokens > l.burst {
+				l.tokens = l.burst
+			}
+		}
+		l.last = now
+
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		select {
+		case <-clock.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package ssdp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrUnreachable is returned by Reachable when the host of a location does
+// not accept connections.
+var ErrUnreachable = errors.New("ssdp: location unreachable")
+
+type reachabilityOption time.Duration
+
+func (r reachabilityOption) apply(opts *options) {
+	opts.reachTimeout = time.Duration(r)
+}
+
+// WithReachabilityCheck dials the host of every location found by
+// SearchDevices before fetching descriptions, dropping those that don't
+// accept a connection within the timeout. Responses from devices that just
+// went to sleep are common after sleep and wake cycles, and fetching their
+// descriptions would fail the whole search after retrying.
+func WithReachabilityCheck(timeout time.Duration) OptionSSDP {
+	return reachabilityOption(timeout)
+}
+
+// Reachable dials the host of the location, after rewriting it, and returns
+// an error wrapping ErrUnreachable when it does not accept a connection
+// within the timeout of WithReachabilityCheck or the context. Locations
+// with a hostname under WithNumericAddresses fail without being dialed, and those fetched
+// through a proxy or a Fetcher for another scheme are not checked.
+func (ssdp *SSDP) Reachable(ctx context.Context, location url.URL) error {
+	location = ssdp.rewriteLocation(location)
+	if err := ssdp.checkLocation(location); err != nil {
+		return err
+	}
+
+	port := location.Port()
+	switch {
+	case ssdp.proxied(location):
+		return nil
+	case port != "":
+	case location.Scheme == "http":
+		port = "80"
+	case location.Scheme == "https":
+		port = "443"
+	default:
+		return nil
+	}
+
+	if ssdp.reachTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ssdp.reachTimeout)
+		defer cancel()
+	}
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(location.Hostname(), port))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrUnreachable, location.Host, err)
+	}
+	conn.Close()
+	return nil
+}
+
+// proxied reports whether requests to the location go through a proxy: the
+// one of WithProxy, or else the one of the transport, or else the one of the
+// environment as for transports that don't tell.
+func (opts *options) proxied(location url.URL) bool {
+	proxy := opts.proxy
+	if proxy == nil {
+		transport := opts.transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		if base, ok := transport.(*http.Transport); ok {
+			proxy = base.Proxy
+		} else {
+			proxy = http.ProxyFromEnvironment
+		}
+	}
+	if proxy == nil {
+		return false
+	}
+	proxyURL, err := proxy(&http.Request{Method: http.MethodGet, URL: &location, Host: location.Host})
+	return err == nil && proxyURL != nil
+}
+
+// reachableLocations returns the locations that are reachable, checking them
+// concurrently.
+func (ssdp *SSDP) reachableLocations(ctx context.Context, locations []url.URL) []url.URL {
+	reachable := make([]bool, len(locations))
+	var wg sync.WaitGroup
+	for i, location := range locations {
+		wg.Add(1)
+		go func(i int, location url.URL) {
+			defer wg.Done()
+			reachable[i] = ssdp.Reachable(ctx, location) == nil
+		}(i, location)
+	}
+	wg.Wait()
+
+	kept := locations[:0]
+	for i, location := range locations {
+		if reachable[i] {
+			kept = append(kept, location)
+		}
+	}
+	return kept
+}
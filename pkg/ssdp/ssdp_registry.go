@@ -0,0 +1,625 @@
+package ssdp
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type registryOptions struct {
+	clock        Clock
+	gracePeriod  time.Duration
+	missedRounds int
+	maxTracked   int
+	key          DeviceKey
+	events       *EventBus
+	cache        *DescriptionCache
+	onReboot     func(udn string)
+}
+
+type OptionRegistry interface {
+	apply(*registryOptions)
+}
+
+type registryClockOption struct {
+	clock Clock
+}
+
+func (r registryClockOption) apply(opts *registryOptions) {
+	opts.clock = r.clock
+}
+
+// WithRegistryClock sets the time source for last seen times and expiry.
+func WithRegistryClock(clock Clock) OptionRegistry {
+	return registryClockOption{clock}
+}
+
+type gracePeriodOption time.Duration
+
+func (g gracePeriodOption) apply(opts *registryOptions) {
+	opts.gracePeriod = time.Duration(g)
+}
+
+// WithGracePeriod keeps devices for the period after their max-age has
+// passed, so a late announcement does not make them flap.
+func WithGracePeriod(period time.Duration) OptionRegistry {
+	return gracePeriodOption(period)
+}
+
+type missedRoundsOption int
+
+func (m missedRoundsOption) apply(opts *registryOptions) {
+	opts.missedRounds = int(m)
+}
+
+// WithMissedRounds keeps expired devices until they have also missed the
+// given number of consecutive rediscovery rounds, see AddRound.
+func WithMissedRounds(rounds int) OptionRegistry {
+	return missedRoundsOption(rounds)
+}
+
+// A RegistryEntry is what is known about a device, keyed by its UDN.
+type RegistryEntry struct {
+	// The key the device is tracked under, its UDN unless WithDeviceKey
+	Key      string
+	UDN      string
+	Location *url.URL
+	Server   string
+	Addr     *net.UDPAddr
+	// The MAC address last resolved, see WithResolveMAC
+	MAC net.HardwareAddr
+	// The port last announced for unicast searches, see SearchAddr
+	SearchPort int
+	// The hostname last resolved, see WithReverseDNS
+	Hostname string
+	// The device and service types the device answered searches for or
+	// announced, and those of its description once set
+	DeviceTypes  []string
+	ServiceTypes []string
+	// The description, nil until set with SetDescription
+	Device *Device
+	// The network location signature last announced
+	NLS string
+	// The BOOTID.UPNP.ORG last seen, zero when the device never sent one
+	BootID int
+	// The CONFIGID.UPNP.ORG last seen, zero when the device never sent one
+	ConfigID int
+	// The name of the remote agent that last reported the device, empty
+	// when it was last seen locally, see AddResponseFrom
+	Agent    string
+	LastSeen time.Time
+	Expires  time.Time
+	// The number of consecutive rediscovery rounds the device did not answer
+	Missed int
+}
+
+// copy returns a copy that does not share slices with the entry.
+func (e *RegistryEntry) copy() RegistryEntry {
+	entry := *e
+	entry.DeviceTypes = append([]string(nil), e.DeviceTypes...)
+	entry.ServiceTypes = append([]string(nil), e.ServiceTypes...)
+	return entry
+}
+
+// index maps a key to the keys of the entries with that key.
+type index map[string]map[string]bool
+
+func (i index) add(key string, entryKey string) {
+	if key == "" {
+		return
+	}
+	if i[key] == nil {
+		i[key] = make(map[string]bool)
+	}
+	i[key][entryKey] = true
+}
+
+func (i index) remove(key string, entryKey string) {
+	delete(i[key], entryKey)
+	if len(i[key]) == 0 {
+		delete(i, key)
+	}
+}
+
+// A Registry tracks the devices seen in search responses and announcements.
+// It is safe for concurrent use.
+type Registry struct {
+	opts *registryOptions
+
+	mu             sync.RWMutex
+	entries        map[string]*RegistryEntry
+	byDeviceType   index
+	byServiceType  index
+	byManufacturer index
+	byAddress      index
+	overflow       uint64
+}
+
+func NewRegistry(opts ...OptionRegistry) *Registry {
+	options := &registryOptions{
+		clock: realClock{},
+		key:   UDNKey,
+	}
+
+	for _, o := range opts {
+		o.apply(options)
+	}
+
+	return &Registry{
+		opts:           options,
+		entries:        make(map[string]*RegistryEntry),
+		byDeviceType:   make(index),
+		byServiceType:  make(index),
+		byManufacturer: make(index),
+		byAddress:      make(index),
+	}
+}
+
+// udnFromUSN returns the UDN part of a USN like
+// "uuid:device-UUID::urn:schemas-upnp-org:service:serviceType:v".
+func udnFromUSN(usn string) string {
+	if i := strings.Index(usn, "::"); i >= 0 {
+		return usn[:i]
+	}
+	return usn
+}
+
+// AddResponse records a search response.
+func (r *Registry) AddResponse(response SearchResponse) {
+	r.AddResponseFrom("", response)
+}
+
+// AddResponseFrom records a search response forwarded by the named agent
+// from a remote network.
+func (r *Registry) AddResponseFrom(agent string, response SearchResponse) {
+	seen := response.Received
+	if seen.IsZero() {
+		seen = r.opts.clock.Now()
+	}
+
+	key := r.opts.key(response.USN, response.Location)
+	r.update(key, udnFromUSN(response.USN), response.ST, response.Location, response.Server, response.NLS, response.ResponseAddr, response.SearchPort, response.BootID, response.ConfigID, agent, seen, seen.Add(response.MaxAge()))
+
+	if response.MAC == nil && response.Hostname == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.entries[key]; ok {
+		if response.MAC != nil {
+			entry.MAC = response.MAC
+		}
+		if response.Hostname != "" {
+			entry.Hostname = response.Hostname
+		}
+	}
+}
+
+// AddNotify records an announcement. A byebye removes the device, an alive
+// with a higher BOOTID.UPNP.ORG than before publishes EventDeviceRebooted.
+func (r *Registry) AddNotify(notify Notify) {
+	r.AddNotifyFrom("", notify)
+}
+
+// AddNotifyFrom records an announcement forwarded by the named agent from a
+// remote network.
+func (r *Registry) AddNotifyFrom(agent string, notify Notify) {
+	key := r.opts.key(notify.USN, notify.Location)
+
+	if notify.NTS == NTSByeBye {
+		r.removeByeBye(key, udnFromUSN(notify.USN))
+		return
+	}
+
+	// An update announces the boot ID the device is about to use, it is
+	// not a reboot
+	bootID := notify.BootID
+	if notify.NTS == NTSUpdate {
+		bootID = 0
+	}
+
+	now := r.opts.clock.Now()
+	r.update(key, udnFromUSN(notify.USN), notify.NT, notify.Location, notify.Server, notify.NLS, notify.Addr, notify.SearchPort, bootID, notify.ConfigID, agent, now, now.Add(notify.MaxAge()))
+
+	if notify.NTS == NTSUpdate && notify.NextBootID != 0 {
+		r.mu.Lock()
+		if entry, ok := r.entries[key]; ok {
+			entry.BootID = notify.NextBootID
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *Registry) update(key string, udn string, target string, location *url.URL, server string, nls string, addr *net.UDPAddr, searchPort int, bootID int, configID int, agent string, seen time.Time, expires time.Time) {
+	if key == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries[key]
+	if !ok {
+		if r.full() {
+			return
+		}
+		entry = &RegistryEntry{Key: key}
+		r.entries[key] = entry
+		defer r.publish(EventDeviceAdded, entry)
+	}
+
+	r.unindex(entry)
+
+	if udn != "" {
+		entry.UDN = udn
+	}
+
+	if location != nil {
+		entry.Location = location
+	}
+	if server != "" {
+		entry.Server = server
+	}
+	if addr != nil {
+		entry.Addr = addr
+		// The port goes with the address it was announced from
+		entry.SearchPort = searchPort
+	}
+	if nls != "" {
+		// A new signature means the device changed networks, so its
+		// description, cached or not, may be stale.
+		if entry.NLS != "" && entry.NLS != nls {
+			entry.Device = nil
+			if r.opts.cache != nil && entry.Location != nil {
+				r.opts.cache.Remove(*entry.Location)
+			}
+		}
+		entry.NLS = nls
+	}
+	if r.rebooted(entry, bootID) {
+		defer r.publish(EventDeviceRebooted, entry)
+		if r.opts.onReboot != nil {
+			go r.opts.onReboot(entry.UDN)
+		}
+	}
+	// A new configuration means a new description
+	r.reconfigured(entry, configID)
+	switch {
+	case strings.Contains(target, ":device:"):
+		entry.DeviceTypes = appendUnique(entry.DeviceTypes, target)
+	case strings.Contains(target, ":service:"):
+		entry.ServiceTypes = appendUnique(entry.ServiceTypes, target)
+	}
+	entry.Agent = agent
+	entry.LastSeen = seen
+	entry.Missed = 0
+	if expires.After(entry.Expires) {
+		entry.Expires = expires
+	}
+
+	r.reindex(entry)
+}
+
+// SetDescription records the description of a device, adding its device and
+// service types and those of its embedded devices to the indexes.
+func (r *Registry) SetDescription(device Device) {
+	if device.UDN == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := r.opts.key(device.UDN, device.Location)
+	entry, ok := r.entries[key]
+	if !ok {
+		if r.full() {
+			return
+		}
+		entry = &RegistryEntry{Key: key, LastSeen: r.opts.clock.Now()}
+		r.entries[key] = entry
+		defer r.publish(EventDeviceAdded, entry)
+	}
+
+	r.unindex(entry)
+
+	entry.UDN = device.UDN
+
+	entry.Device = &device
+	if entry.Location == nil {
+		entry.Location = device.Location
+	}
+	entry.DeviceTypes = appendUnique(entry.DeviceTypes, device.DeviceType)
+	entry.DeviceTypes = appendEmbeddedTypes(entry.DeviceTypes, device.Devices)
+	for _, service := range device.AllServices() {
+		entry.ServiceTypes = appendUnique(entry.ServiceTypes, service.ServiceType)
+	}
+
+	r.reindex(entry)
+}
+
+func appendEmbeddedTypes(types []string, devices []EmbeddedDevice) []string {
+	for _, device := range devices {
+		types = appendUnique(types, device.DeviceType)
+		types = appendEmbeddedTypes(types, device.Devices)
+	}
+	return types
+}
+
+func appendUnique(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// full reports whether there is no room for another device, counting the
+// overflow if so. The caller must hold the lock.
+func (r *Registry) full() bool {
+	if r.opts.maxTracked > 0 && len(r.entries) >= r.opts.maxTracked {
+		r.overflow++
+		return true
+	}
+	return false
+}
+
+// Overflow returns the number of updates dropped because the registry was
+// full, see WithMaxTracked.
+func (r *Registry) Overflow() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.overflow
+}
+
+// Remove removes the device with the key, its UDN unless WithDeviceKey.
+func (r *Registry) Remove(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry := r.remove(key); entry != nil {
+		r.publish(EventDeviceRemoved, entry)
+	}
+}
+
+// removeByeBye removes the device saying byebye. A byebye has no location,
+// so under a key derived from it, such as HostKey, the device is looked up
+// by its UDN instead.
+func (r *Registry) removeByeBye(key string, udn string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.entries[key]; !ok {
+		for entryKey, entry := range r.entries {
+			if entry.UDN == udn {
+				key = entryKey
+				break
+			}
+		}
+	}
+	if entry := r.remove(key); entry != nil {
+		r.publish(EventDeviceRemoved, entry)
+	}
+}
+
+func (r *Registry) remove(key string) *RegistryEntry {
+	entry, ok := r.entries[key]
+	if ok {
+		r.unindex(entry)
+		delete(r.entries, key)
+	}
+	return entry
+}
+
+// publish publishes an event of the entry. The caller must hold the lock.
+func (r *Registry) publish(eventType EventType, entry *RegistryEntry) {
+	if r.opts.events == nil {
+		return
+	}
+	device := entry.copy()
+	r.opts.events.Publish(Event{Type: eventType, Time: r.opts.clock.Now(), Device: &device})
+}
+
+// AddRound records the responses of a rediscovery search and counts a missed
+// round for every device that did not answer. It returns the keys of the
+// devices removed by Expire afterwards.
+func (r *Registry) AddRound(responses []SearchResponse) []string {
+	answered := make(map[string]bool)
+	for _, response := range responses {
+		r.AddResponse(response)
+		answered[r.opts.key(response.USN, response.Location)] = true
+	}
+
+	r.mu.Lock()
+	for key, entry := range r.entries {
+		if !answered[key] {
+			entry.Missed++
+		}
+	}
+	r.mu.Unlock()
+
+	return r.Expire()
+}
+
+// Expire removes the devices whose max-age and grace period have passed and
+// that missed enough rediscovery rounds, and returns their keys.
+func (r *Registry) Expire() []string {
+	now := r.opts.clock.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired []string
+	for key, entry := range r.entries {
+		if entry.Expires.IsZero() || now.Before(entry.Expires.Add(r.opts.gracePeriod)) {
+			continue
+		}
+		if entry.Missed < r.opts.missedRounds {
+			continue
+		}
+		expired = append(expired, key)
+	}
+
+	sort.Strings(expired)
+	for _, key := range expired {
+		r.publish(EventDeviceExpired, r.remove(key))
+	}
+
+	return expired
+}
+
+// reindex adds the entry to the indexes. The caller must hold the lock.
+func (r *Registry) reindex(entry *RegistryEntry) {
+	for _, deviceType := range entry.DeviceTypes {
+		r.byDeviceType.add(deviceType, entry.Key)
+	}
+	for _, serviceType := range entry.ServiceTypes {
+		r.byServiceType.add(serviceType, entry.Key)
+	}
+	if entry.Device != nil {
+		r.byManufacturer.add(strings.ToLower(entry.Device.Manufacturer), entry.Key)
+	}
+	if entry.Addr != nil {
+		r.byAddress.add(entry.Addr.IP.String(), entry.Key)
+	}
+}
+
+// unindex removes the entry from the indexes. The caller must hold the lock.
+func (r *Registry) unindex(entry *RegistryEntry) {
+	for _, deviceType := range entry.DeviceTypes {
+		r.byDeviceType.remove(deviceType, entry.Key)
+	}
+	for _, serviceType := range entry.ServiceTypes {
+		r.byServiceType.remove(serviceType, entry.Key)
+	}
+	if entry.Device != nil {
+		r.byManufacturer.remove(strings.ToLower(entry.Device.Manufacturer), entry.Key)
+	}
+	if entry.Addr != nil {
+		r.byAddress.remove(entry.Addr.IP.String(), entry.Key)
+	}
+}
+
+// ByUDN returns the device with the UDN.
+func (r *Registry) ByUDN(udn string) (RegistryEntry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if entry, ok := r.entries[udn]; ok && entry.UDN == udn {
+		return entry.copy(), true
+	}
+	// Tracked under another key, see WithDeviceKey
+	for _, entry := range r.entries {
+		if entry.UDN == udn {
+			return entry.copy(), true
+		}
+	}
+	return RegistryEntry{}, false
+}
+
+// ByDeviceType returns the devices of the exact device type, such as
+// "urn:schemas-upnp-org:device:MediaRenderer:1".
+func (r *Registry) ByDeviceType(deviceType string) []RegistryEntry {
+	return r.lookup(r.byDeviceType, deviceType)
+}
+
+// ByServiceType returns the devices offering the exact service type.
+func (r *Registry) ByServiceType(serviceType string) []RegistryEntry {
+	return r.lookup(r.byServiceType, serviceType)
+}
+
+// ByTarget returns the devices that answer a search for the target, see
+// MatchesST, such as the devices of a type in that or a higher version.
+func (r *Registry) ByTarget(searchST string) []RegistryEntry {
+	var entries []RegistryEntry
+	for _, entry := range r.Snapshot() {
+		if entry.answers(searchST) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (e RegistryEntry) answers(searchST string) bool {
+	if MatchesST(e.UDN, searchST) {
+		return true
+	}
+	for _, types := range [][]string{e.DeviceTypes, e.ServiceTypes} {
+		for _, target := range types {
+			if MatchesST(target, searchST) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ByManufacturer returns the described devices of the manufacturer, ignoring
+// case.
+func (r *Registry) ByManufacturer(manufacturer string) []RegistryEntry {
+	return r.lookup(r.byManufacturer, strings.ToLower(manufacturer))
+}
+
+// ByAddress returns the devices that last responded from the IP.
+func (r *Registry) ByAddress(ip net.IP) []RegistryEntry {
+	return r.lookup(r.byAddress, ip.String())
+}
+
+func (r *Registry) lookup(index index, key string) []RegistryEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]RegistryEntry, 0, len(index[key]))
+	for entryKey := range index[key] {
+		entries = append(entries, r.entries[entryKey].copy())
+	}
+
+	sortEntries(entries)
+	return entries
+}
+
+// Snapshot returns all devices, sorted by UDN.
+func (r *Registry) Snapshot() []RegistryEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]RegistryEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entries = append(entries, entry.copy())
+	}
+
+	sortEntries(entries)
+	return entries
+}
+
+// Range calls f for a snapshot of the devices, sorted by UDN, until it
+// returns false.
+func (r *Registry) Range(f func(RegistryEntry) bool) {
+	for _, entry := range r.Snapshot() {
+		if !f(entry) {
+			return
+		}
+	}
+}
+
+// Len returns the number of devices.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.entries)
+}
+
+func sortEntries(entries []RegistryEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].UDN < entries[j].UDN
+	})
+}
@@ -0,0 +1,69 @@
+package ssdp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SearchResponseConfig describes a search response built with
+// BuildSearchResponse.
+type SearchResponseConfig struct {
+	// The search target answered, e.g. "upnp:rootdevice"
+	ST string
+	// The unique service name, e.g. "uuid:...::upnp:rootdevice"
+	USN string
+	// The URL of the description, see ExpandLocation for hosts on several
+	// networks
+	Location string
+	// How long the response is valid, 30 minutes when zero
+	MaxAge time.Duration
+	// The SERVER header, "OS/version UPnP/1.0 product/version"
+	Server string
+	// The DATE header, left out when zero
+	Date time.Time
+	// The BOOTID.UPNP.ORG and CONFIGID.UPNP.ORG headers sent from UDA 1.1
+	BootID   int
+	ConfigID int
+	// The SEARCHPORT.UPNP.ORG header sent from UDA 1.1 when not zero
+	SearchPort int
+	// The network location signature sent in 01-NLS when not empty
+	NLS string
+	// The UDA version to follow, UDA 1.0 when zero
+	Version UDAVersion
+}
+
+// BuildSearchResponse returns the search response for the config as sent on
+// the wire, so the formatting of responders can be checked against
+// ParseSearchResponse and ValidateSearchResponse.
+func BuildSearchResponse(config SearchResponseConfig) []byte {
+	var b strings.Builder
+
+	maxAge := config.MaxAge
+	if maxAge <= 0 {
+		maxAge = 30 * time.Minute
+	}
+
+	b.WriteString("HTTP/1.1 200 OK\r\n")
+	fmt.Fprintf(&b, "CACHE-CONTROL: max-age=%d\r\n", int(maxAge/time.Second))
+	if !config.Date.IsZero() {
+		fmt.Fprintf(&b, "DATE: %s\r\n", config.Date.UTC().Format(http.TimeFormat))
+	}
+	b.WriteString("EXT:\r\n")
+	fmt.Fprintf(&b, "LOCATION: %s\r\n", config.Location)
+	fmt.Fprintf(&b, "SERVER: %s\r\n", config.Server)
+	fmt.Fprintf(&b, "ST: %s\r\n", config.ST)
+	fmt.Fprintf(&b, "USN: %s\r\n", config.USN)
+	writeNLS(&b, config.NLS)
+	if config.Version >= UDA11 {
+		fmt.Fprintf(&b, "BOOTID.UPNP.ORG: %d\r\n", config.BootID)
+		fmt.Fprintf(&b, "CONFIGID.UPNP.ORG: %d\r\n", config.ConfigID)
+		if config.SearchPort != 0 {
+			fmt.Fprintf(&b, "SEARCHPORT.UPNP.ORG: %d\r\n", config.SearchPort)
+		}
+	}
+	b.WriteString("\r\n")
+
+	return []byte(b.String())
+}
@@ -0,0 +1,87 @@
+package ssdp
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how often and how fast a failed operation is retried.
+type RetryPolicy struct {
+	// Total number of attempts, values below 2 disable retrying
+	Attempts int
+	// Delay before the first retry, doubled for every following retry
+	Backoff time.Duration
+	// Upper bound of the delay, zero means unbounded
+	MaxBackoff time.Duration
+	// Fraction of the delay to randomly add or subtract, between 0 and 1
+	Jitter float64
+}
+
+// delay returns the time to wait after the given failed attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+type fetchRetryOption RetryPolicy
+
+func (f fetchRetryOption) apply(opts *options) {
+	opts.retry = RetryPolicy(f)
+}
+
+// WithFetchRetry retries description fetches that fail to connect or return
+// a non 200 status. Descriptions that fail to decode aren't retried.
+func WithFetchRetry(policy RetryPolicy) OptionSSDP {
+	return fetchRetryOption(policy)
+}
+
+// FetchError is returned when a description could not be fetched.
+type FetchError struct {
+	URL string
+	// Number of attempts made
+	Attempts int
+	// The error of the last attempt
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("fetching %s failed after %d attempt(s): %v", e.URL, e.Attempts, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// SearchWithRetry searches until at least one response arrives, repeating
+// empty searches according to the policy. It returns the responses of the
+// first search that found any, or none once the policy is exhausted.
+func (ssdp *SSDP) SearchWithRetry(ctx context.Context, search string, policy RetryPolicy) ([]SearchResponse, error) {
+	for attempt := 1; ; attempt++ {
+		responses, err := ssdp.SearchContext(ctx, search)
+		if err != nil || len(responses) > 0 || attempt >= policy.Attempts {
+			return responses, err
+		}
+
+		select {
+		case <-ssdp.clock.After(policy.delay(attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package ssdp
+
+import (
+	"net"
+	"net/url"
+)
+
+// A LocationRewriter maps a location reported by a device to one the control
+// point can reach. It returns the location unchanged when no mapping applies.
+type LocationRewriter func(location url.URL) url.URL
+
+type locationRewriterOption LocationRewriter
+
+func (l locationRewriterOption) apply(opts *options) {
+	opts.rewrite = LocationRewriter(l)
+}
+
+// WithLocationRewriter rewrites locations before their descriptions are
+// fetched, e.g. when the control point runs in a container and the internal
+// addresses devices report must be mapped to published ones. The location of
+// the fetched Device is the rewritten one, so the URLs resolved against it
+// are reachable as well.
+func WithLocationRewriter(rewrite LocationRewriter) OptionSSDP {
+	return locationRewriterOption(rewrite)
+}
+
+// RewriteHost returns a LocationRewriter that replaces the host of locations
+// found in hosts, keeping the port unless the replacement has one, e.g.
+// RewriteHost(map[string]string{"172.17.0.2": "192.168.1.10"}).
+func RewriteHost(hosts map[string]string) LocationRewriter {
+	return func(location url.URL) url.URL {
+		host, ok := hosts[location.Hostname()]
+		if !ok {
+			return location
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil && location.Port() != "" {
+			host = net.JoinHostPort(host, location.Port())
+		}
+		location.Host = host
+		return location
+	}
+}
+
+func (opts *options) rewriteLocation(location url.URL) url.URL {
+	if opts.rewrite == nil {
+		return location
+	}
+	return opts.rewrite(location)
+}
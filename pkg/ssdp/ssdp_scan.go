@@ -0,0 +1,158 @@
+package ssdp
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// The most extension headers ending in -NLS remembered while scanning.
+const maxScannedNLS = 4
+
+// notifyHeaders are the headers of a NOTIFY request a Notify holds.
+type notifyHeaders struct {
+	host, control, server, nt, nts, usn, location, opt, nls, searchPort string
+	bootID, nextBootID, configID                                        string
+
+	// Extension headers ending in -NLS, whose prefix is only known once
+	// the OPT header was read
+	prefixed [maxScannedNLS]struct{ name, value string }
+}
+
+// ScanNotify parses a raw NOTIFY request like ParseNotify, reading only the
+// headers a Notify holds instead of building the header map of an HTTP
+// request. It allocates a fraction of what ParseNotify does, which adds up
+// on monitors receiving thousands of announcements per minute.
+func ScanNotify(message []byte, addr *net.UDPAddr) (*Notify, error) {
+	if len(message) > MaxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	// The fields of the Notify are substrings of a single copy
+	data := string(message)
+	if end := strings.Index(data, "\n\r\n"); end >= 0 {
+		data = data[:end+1]
+	} else if end := strings.Index(data, "\n\n"); end >= 0 {
+		data = data[:end+1]
+	}
+
+	line, data := nextLine(data)
+	if len(line) > MaxHeaderLength {
+		return nil, ErrHeaderTooLong
+	}
+	method, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return nil, fmt.Errorf("malformed request line %q", line)
+	}
+	if _, proto, ok := strings.Cut(rest, " "); !ok || !strings.HasPrefix(proto, "HTTP/") {
+		return nil, fmt.Errorf("malformed request line %q", line)
+	}
+	if method != "NOTIFY" {
+		return nil, fmt.Errorf("unexpected method %q", method)
+	}
+
+	var headers notifyHeaders
+	nls := 0
+	for count := 0; data != ""; count++ {
+		if count == MaxHeaderCount {
+			return nil, ErrTooManyHeaders
+		}
+
+		line, data = nextLine(data)
+		if len(line) > MaxHeaderLength {
+			return nil, ErrHeaderTooLong
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 {
+			return nil, fmt.Errorf("malformed header line %q", line)
+		}
+		// Some devices send whitespace before the colon, see repairHeaders
+		name := strings.TrimRight(line[:colon], " \t")
+		value := strings.Trim(line[colon+1:], " \t")
+
+		var field *string
+		switch {
+		case strings.EqualFold(name, "host"):
+			field = &headers.host
+		case strings.EqualFold(name, "cache-control"):
+			field = &headers.control
+		case strings.EqualFold(name, "server"):
+			field = &headers.server
+		case strings.EqualFold(name, "nt"):
+			field = &headers.nt
+		case strings.EqualFold(name, "nts"):
+			field = &headers.nts
+		case strings.EqualFold(name, "usn"):
+			field = &headers.usn
+		case strings.EqualFold(name, "location"):
+			field = &headers.location
+		case strings.EqualFold(name, "opt"):
+			field = &headers.opt
+		case strings.EqualFold(name, "nls"):
+			field = &headers.nls
+		case strings.EqualFold(name, "searchport.upnp.org"):
+			field = &headers.searchPort
+		case strings.EqualFold(name, "bootid.upnp.org"):
+			field = &headers.bootID
+		case strings.EqualFold(name, "nextbootid.upnp.org"):
+			field = &headers.nextBootID
+		case strings.EqualFold(name, "configid.upnp.org"):
+			field = &headers.configID
+		case len(name) > 4 && strings.EqualFold(name[len(name)-4:], "-nls") && nls < maxScannedNLS:
+			headers.prefixed[nls].name = name
+			headers.prefixed[nls].value = value
+			nls++
+			continue
+		default:
+			continue
+		}
+		// The first of repeated headers counts, like with http.Header.Get
+		if *field == "" {
+			*field = value
+		}
+	}
+
+	notify := &Notify{
+		Host:       headers.host,
+		Control:    headers.control,
+		Server:     headers.server,
+		NT:         headers.nt,
+		NTS:        headers.nts,
+		USN:        headers.usn,
+		NLS:        headers.scannedNLS(),
+		Addr:       addr,
+		SearchPort: parseSearchPort(headers.searchPort),
+		BootID:     parseBootID(headers.bootID),
+		NextBootID: parseBootID(headers.nextBootID),
+		ConfigID:   parseConfigID(headers.configID),
+	}
+
+	if headers.location != "" {
+		location, err := url.Parse(headers.location)
+		if err != nil {
+			return nil, err
+		}
+		notify.Location = addZone(location, addr)
+	}
+
+	return notify, nil
+}
+
+// nextLine splits off the first line of data, without its line ending.
+func nextLine(data string) (string, string) {
+	line, rest, _ := strings.Cut(data, "\n")
+	return strings.TrimSuffix(line, "\r"), rest
+}
+
+// scannedNLS returns the network location signature like parseNLS.
+func (h *notifyHeaders) scannedNLS() string {
+	prefix := nlsPrefix(h.opt)
+	for _, header := range h.prefixed {
+		name := header.name
+		if len(name) == len(prefix)+4 && strings.EqualFold(name[:len(prefix)], prefix) && header.value != "" {
+			return header.value
+		}
+	}
+	return h.nls
+}
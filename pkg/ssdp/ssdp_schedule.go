@@ -0,0 +1,47 @@
+package ssdp
+
+import (
+	"sort"
+	"time"
+)
+
+// A ScheduledAnnouncement is an announcement an Advertiser repeats.
+type ScheduledAnnouncement struct {
+	NT  string
+	USN string
+	// When it was last sent, zero until the first time
+	Last time.Time
+	// When it is sent next, unless the addresses of the interfaces change
+	// first
+	Next time.Time
+	// How long each announcement is valid
+	MaxAge time.Duration
+}
+
+// Schedule returns the planned announcements of the devices, ordered by the
+// time they are sent next, e.g. for debugging or to keep a battery powered
+// device awake when they are due.
+func (a *Advertiser) Schedule() []ScheduledAnnouncement {
+	devices := a.Devices()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var schedule []ScheduledAnnouncement
+	for _, device := range devices {
+		for _, config := range device.announcements("") {
+			schedule = append(schedule, ScheduledAnnouncement{
+				NT:     config.NT,
+				USN:    config.USN,
+				Last:   a.sent[config.USN],
+				Next:   a.renewAt,
+				MaxAge: device.MaxAge,
+			})
+		}
+	}
+
+	sort.SliceStable(schedule, func(i, j int) bool {
+		return schedule[i].Next.Before(schedule[j].Next)
+	})
+	return schedule
+}
@@ -0,0 +1,129 @@
+package ssdp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Length limits of the description elements, as recommended by the UDA.
+const (
+	maxFriendlyName = 64
+	maxManufacturer = 64
+	maxModelName    = 32
+)
+
+// ValidateDescription checks a description against the rules of the UPnP
+// device schema: the required elements of the device, its embedded devices,
+// services and icons, and the formats of the UDN, types and IDs.
+func ValidateDescription(device *Device) []Finding {
+	var findings []Finding
+	add := func(severity Severity, element string, format string, args ...interface{}) {
+		findings = append(findings, Finding{Severity: severity, Header: element, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	if device.SpecVersion.Major != 1 && device.SpecVersion.Major != 2 {
+		add(SeverityError, "specVersion", "unknown major version %d", device.SpecVersion.Major)
+	}
+	if device.UPC != "" && !isDigits(device.UPC, 12) {
+		add(SeverityWarning, "UPC", "not a 12 digit code: %q", device.UPC)
+	}
+
+	validateDevice(add, "", EmbeddedDevice{
+		DeviceType:   device.DeviceType,
+		FriendlyName: device.FriendlyName,
+		Manufacturer: device.Manufacturer,
+		ModelName:    device.ModelName,
+		UDN:          device.UDN,
+		Services:     device.Services,
+		Devices:      device.Devices,
+	})
+
+	for i, icon := range device.Icons {
+		element := fmt.Sprintf("icon %d", i+1)
+		if icon.MIMEType == "" {
+			add(SeverityError, element, "missing mimetype")
+		}
+		if icon.Width <= 0 || icon.Height <= 0 {
+			add(SeverityError, element, "missing or invalid size %dx%d", icon.Width, icon.Height)
+		}
+		if icon.Depth <= 0 {
+			add(SeverityError, element, "missing or invalid depth %d", icon.Depth)
+		}
+		if icon.URL == "" {
+			add(SeverityError, element, "missing url")
+		}
+	}
+
+	return findings
+}
+
+// validateDevice checks the device, naming its elements after the prefix,
+// which is empty for the root device.
+func validateDevice(add func(Severity, string, string, ...interface{}), prefix string, device EmbeddedDevice) {
+	element := func(name string) string {
+		return strings.TrimSpace(prefix + " " + name)
+	}
+
+	if !isTypeURN(device.DeviceType, "device") {
+		add(SeverityError, element("deviceType"), "not a device type URN: %q", device.DeviceType)
+	}
+	if device.FriendlyName == "" {
+		add(SeverityError, element("friendlyName"), "missing")
+	} else if len(device.FriendlyName) > maxFriendlyName {
+		add(SeverityWarning, element("friendlyName"), "longer than %d characters", maxFriendlyName)
+	}
+	if device.Manufacturer == "" {
+		add(SeverityError, element("manufacturer"), "missing")
+	} else if len(device.Manufacturer) > maxManufacturer {
+		add(SeverityWarning, element("manufacturer"), "longer than %d characters", maxManufacturer)
+	}
+	if device.ModelName == "" {
+		add(SeverityError, element("modelName"), "missing")
+	} else if len(device.ModelName) > maxModelName {
+		add(SeverityWarning, element("modelName"), "longer than %d characters", maxModelName)
+	}
+	if !strings.HasPrefix(device.UDN, "uuid:") || len(device.UDN) == len("uuid:") {
+		add(SeverityError, element("UDN"), "not a uuid: UDN: %q", device.UDN)
+	}
+
+	for _, service := range device.Services {
+		subject := element("service " + service.ServiceID)
+		if !isTypeURN(service.ServiceType, "service") {
+			add(SeverityError, subject, "not a service type URN: %q", service.ServiceType)
+		}
+		if service.ServiceID == "" {
+			add(SeverityError, subject, "missing serviceId")
+		} else if !strings.HasPrefix(service.ServiceID, "urn:") || !strings.Contains(service.ServiceID, ":serviceId:") {
+			add(SeverityWarning, subject, "not a serviceId URN")
+		}
+		if service.SCPDURL == "" {
+			add(SeverityError, subject, "missing SCPDURL")
+		}
+		if service.ControlURL == "" {
+			add(SeverityError, subject, "missing controlURL")
+		}
+	}
+
+	for i, embedded := range device.Devices {
+		validateDevice(add, element(fmt.Sprintf("device %d", i+1)), embedded)
+	}
+}
+
+// isTypeURN reports whether the type is a versioned URN of the kind, such
+// as "urn:schemas-upnp-org:device:MediaServer:1".
+func isTypeURN(urn string, kind string) bool {
+	_, _, ok := splitTypeVersion(urn)
+	return ok && strings.Split(urn, ":")[2] == kind
+}
+
+func isDigits(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package ssdp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// How long an empty result, or one without any max-age, is served from a
+// SearchCache.
+const searchCacheEmptyTTL = 10 * time.Second
+
+// A SearchCache answers searches with the result of an earlier search for
+// the same target while it is fresh. A result lives as long as the shortest
+// max-age of its responses; once half of it has passed the cached result is
+// still returned, but refreshed in the background. Concurrent searches for a
+// target share a single search.
+type SearchCache struct {
+	ssdp *SSDP
+
+	mu      sync.Mutex
+	results map[string]*cachedSearch
+}
+
+type cachedSearch struct {
+	responses []SearchResponse
+	err       error
+	searched  time.Time
+	expires   time.Time
+	// closed once the running search is done, nil when none is running
+	pending chan struct{}
+}
+
+// NewSearchCache returns an empty cache for searches of the client.
+func (ssdp *SSDP) NewSearchCache() *SearchCache {
+	return &SearchCache{
+		ssdp:    ssdp,
+		results: make(map[string]*cachedSearch),
+	}
+}
+
+// Search returns the cached responses for the target, searching when there
+// are none or they expired. Responses past their own max-age are left out.
+func (c *SearchCache) Search(ctx context.Context, search string) ([]SearchResponse, error) {
+	c.mu.Lock()
+	result, ok := c.results[search]
+	if !ok {
+		result = &cachedSearch{}
+		c.results[search] = result
+	}
+
+	now := c.ssdp.clock.Now()
+	if result.err == nil && now.Before(result.expires) {
+		if result.pending == nil && now.Sub(result.searched) > result.expires.Sub(result.searched)/2 {
+			c.start(search, result)
+		}
+		responses := fresh(result.responses, now)
+		c.mu.Unlock()
+		return responses, nil
+	}
+
+	if result.pending == nil {
+		c.start(search, result)
+	}
+	pending := result.pending
+	c.mu.Unlock()
+
+	select {
+	case <-pending:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if result.err != nil {
+		return nil, result.err
+	}
+	return fresh(result.responses, c.ssdp.clock.Now()), nil
+}
+
+// Invalidate drops the cached result of the target, e.g. after a device
+// announced its departure.
+func (c *SearchCache) Invalidate(search string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if result, ok := c.results[search]; ok {
+		result.expires = time.Time{}
+	}
+}
+
+// start searches for the target in the background. It must be called with
+// the lock held.
+func (c *SearchCache) start(search string, result *cachedSearch) {
+	pending := make(chan struct{})
+	result.pending = pending
+
+	go func() {
+		defer close(pending)
+
+		responses, err := c.ssdp.SearchContext(context.Background(), search)
+		searched := c.ssdp.clock.Now()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		result.pending = nil
+		if err != nil && result.responses != nil && searched.Before(result.expires) {
+			// Keep serving the previous result until it expires
+			return
+		}
+		result.responses = responses
+		result.err = err
+		result.searched = searched
+		result.expires = searched.Add(searchTTL(responses))
+	}()
+}
+
+// searchTTL returns the shortest max-age of the responses.
+func searchTTL(responses []SearchResponse) time.Duration {
+	var ttl time.Duration
+	for _, response := range responses {
+		if maxAge := response.MaxAge(); maxAge > 0 && (ttl == 0 || maxAge < ttl) {
+			ttl = maxAge
+		}
+	}
+	if ttl == 0 {
+		return searchCacheEmptyTTL
+	}
+	return ttl
+}
+
+// fresh returns the responses that have not expired at the given time.
+func fresh(responses []SearchResponse, now time.Time) []SearchResponse {
+	kept := make([]SearchResponse, 0, len(responses))
+	for _, response := range responses {
+		if response.MaxAge() == 0 || !response.Expired(now) {
+			kept = append(kept, response)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,44 @@
+package ssdp
+
+import (
+	"net"
+	"strconv"
+)
+
+// DefaultSearchPort is the port devices answer unicast searches on unless
+// they announce another with SEARCHPORT.UPNP.ORG.
+const DefaultSearchPort = 1900
+
+// parseSearchPort returns the value of the SEARCHPORT.UPNP.ORG header, or zero
+// when it is missing or not a port UDA 1.1 allows (49152-65535).
+func parseSearchPort(value string) int {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 49152 || port > 65535 {
+		return 0
+	}
+	return port
+}
+
+// searchAddr returns the address to send unicast searches to a device at
+// addr announcing the port.
+func searchAddr(addr *net.UDPAddr, port int) *net.UDPAddr {
+	if addr == nil {
+		return nil
+	}
+	if port == 0 {
+		port = DefaultSearchPort
+	}
+	return &net.UDPAddr{IP: addr.IP, Port: port, Zone: addr.Zone}
+}
+
+// SearchAddr returns the address to send unicast searches and probes to the
+// responder, see Probe. It is nil when the response address is unknown.
+func (r SearchResponse) SearchAddr() *net.UDPAddr {
+	return searchAddr(r.ResponseAddr, r.SearchPort)
+}
+
+// SearchAddr returns the address to send unicast searches and probes to the
+// device, see Probe. It is nil when the address is unknown.
+func (e RegistryEntry) SearchAddr() *net.UDPAddr {
+	return searchAddr(e.Addr, e.SearchPort)
+}
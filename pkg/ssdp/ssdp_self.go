@@ -0,0 +1,44 @@
+package ssdp
+
+import (
+	"net"
+)
+
+type includeSelfOption bool
+
+func (i includeSelfOption) apply(opts *options) {
+	opts.includeSelf = bool(i)
+}
+
+// WithIncludeSelf controls whether responses sent from the addresses of the
+// local machine are returned. They are filtered out by default, so services
+// advertised by this host don't show up in its own searches.
+func WithIncludeSelf(include bool) OptionSSDP {
+	return includeSelfOption(include)
+}
+
+// localAddrs returns the set of IP addresses assigned to the local machine.
+func localAddrs() map[string]bool {
+	local := make(map[string]bool)
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return local
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			local[ipNet.IP.String()] = true
+		}
+	}
+
+	return local
+}
+
+// isSelf reports whether the address belongs to the local machine.
+func isSelf(local map[string]bool, addr *net.UDPAddr) bool {
+	if addr == nil {
+		return false
+	}
+	return addr.IP.IsLoopback() || local[addr.IP.String()]
+}
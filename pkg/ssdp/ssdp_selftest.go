@@ -0,0 +1,159 @@
+package ssdp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// The errors of an interface failing the self-test of Diagnose.
+var (
+	ErrMulticastJoin = errors.New("cannot join the multicast group")
+	ErrMulticastTX   = errors.New("multicast TX failed")
+	ErrMulticastRX   = errors.New("multicast RX blocked")
+)
+
+// The search target of the probes, which no device matches.
+const diagnoseTarget = "urn:gossdp:diagnose:"
+
+// InterfaceHealth is the outcome of the self-test of an interface.
+type InterfaceHealth struct {
+	Interface string
+	// The IPv4 address the probe was sent from
+	IP net.IP
+	// Whether the group could be joined, the probe sent and received back
+	Joined   bool
+	Sent     bool
+	Received bool
+	// Why the interface cannot take part in SSDP, e.g.
+	// "iface wlan0: multicast RX blocked", which wraps one of ErrMulticastJoin,
+	// ErrMulticastTX or ErrMulticastRX
+	Err error
+}
+
+// Healthy reports whether multicast works both ways on the interface.
+func (h InterfaceHealth) Healthy() bool {
+	return h.Err == nil
+}
+
+// Diagnose checks that multicast works on the interfaces the client uses:
+// the one of WithInterface, or else every interface that is up and supports
+// multicast. On each it joins the group and sends a probe to it, which the
+// host loops back to its own members. An interface the probe does not come
+// back on within the search timeout, or a second without one, has its error
+// set, telling why searches and monitors find nothing there, with the hints
+// on the cause, see HintsOf. The error is only set when no interface could
+// be tested.
+func (ssdp *SSDP) Diagnose(ctx context.Context) ([]InterfaceHealth, error) {
+	ifaces, err := ssdp.announceInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("ssdp: no interface is up with multicast")
+	}
+	group, err := ssdp.resolveUDPAddr(ssdp.broadcastIp, ssdp.port)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := ssdp.timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	results := make([]InterfaceHealth, len(ifaces))
+	seen := make([]bool, len(ifaces))
+	var wg sync.WaitGroup
+	for i := range ifaces {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], seen[i] = diagnoseInterface(probeCtx, ifaces[i], group)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range results {
+		switch {
+		case results[i].Err == nil:
+		case errors.Is(results[i].Err, ErrMulticastRX):
+			results[i].Err = withHints(results[i].Err, interfaceHints(ctx, ifaces[i], seen[i]))
+		default:
+			results[i].Err = withHints(results[i].Err, environmentHints(ctx, ifaces[i].Name))
+		}
+	}
+	return results, nil
+}
+
+// diagnoseInterface loops a probe through the group on the interface, also
+// telling whether any packet arrived.
+func diagnoseInterface(ctx context.Context, iface net.Interface, group *net.UDPAddr) (health InterfaceHealth, seen bool) {
+	health.Interface = iface.Name
+
+	listener, err := net.ListenMulticastUDP("udp4", &iface, group)
+	if err != nil {
+		health.Err = fmt.Errorf("iface %s: %w %s: %v", iface.Name, ErrMulticastJoin, group.IP, err)
+		return
+	}
+	defer listener.Close()
+	health.Joined = true
+
+	ip, err := InterfaceIP(iface.Name)
+	if err != nil {
+		health.Err = fmt.Errorf("iface %s: %w: %v", iface.Name, ErrMulticastTX, err)
+		return
+	}
+	health.IP = ip
+
+	sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: ip})
+	if err != nil {
+		health.Err = fmt.Errorf("iface %s: %w: %v", iface.Name, ErrMulticastTX, err)
+		return
+	}
+	defer sender.Close()
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		health.Err = err
+		return
+	}
+	target := []byte(diagnoseTarget + hex.EncodeToString(nonce))
+	probe := fmt.Sprintf("M-SEARCH * HTTP/1.1\r\nHOST: %s\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: %s\r\n\r\n", group, target)
+	if err := writeNotify(ctx, sender, &iface, []byte(probe), group); err != nil {
+		health.Err = fmt.Errorf("iface %s: %w: %v", iface.Name, ErrMulticastTX, err)
+		return
+	}
+	health.Sent = true
+
+	deadline, _ := ctx.Deadline()
+	listener.SetReadDeadline(deadline)
+	// The group is joined on every interface at once, and Linux delivers the
+	// packets of all of them to each listener, so only those received on
+	// this interface count, where the platform tells
+	reader := newControlReader(listener)
+	buf := make([]byte, MaxMessageSize)
+	for {
+		n, _, ifIndex, _, err := readFrom(reader, buf)
+		if err != nil {
+			health.Err = fmt.Errorf("iface %s: %w", iface.Name, ErrMulticastRX)
+			return
+		}
+		if ifIndex != 0 && ifIndex != iface.Index {
+			continue
+		}
+		seen = true
+		if bytes.Contains(buf[:n], target) {
+			health.Received = true
+			return
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package ssdp
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AllServices returns the services of the device and of all its embedded
+// devices.
+func (d Device) AllServices() []Service {
+	services := append([]Service{}, d.Services...)
+	for _, embedded := range d.Devices {
+		services = append(services, embedded.allServices()...)
+	}
+	return services
+}
+
+func (d EmbeddedDevice) allServices() []Service {
+	services := append([]Service{}, d.Services...)
+	for _, embedded := range d.Devices {
+		services = append(services, embedded.allServices()...)
+	}
+	return services
+}
+
+// ServiceByType returns the first service of the device or its embedded
+// devices whose type starts with the given prefix, e.g.
+// "urn:schemas-upnp-org:service:AVTransport:".
+func (d Device) ServiceByType(prefix string) (Service, bool) {
+	for _, service := range d.AllServices() {
+		if strings.HasPrefix(service.ServiceType, prefix) {
+			return service, true
+		}
+	}
+	return Service{}, false
+}
+
+// ResolveURL resolves a URL from the description, such as a control URL,
+// against the URLBase of the device or else the location it was fetched
+// from.
+func (d Device) ResolveURL(ref string) (*url.URL, error) {
+	base := d.Location
+	if d.URLBase != "" {
+		var err error
+		base, err = url.Parse(d.URLBase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if base == nil {
+		return nil, fmt.Errorf("no base url to resolve %q", ref)
+	}
+
+	return base.Parse(ref)
+}
+
+// A ServiceEndpoint is a service with its URLs resolved against the device.
+// URLs missing from the description are nil.
+type ServiceEndpoint struct {
+	Service     Service
+	Version     int
+	SCPDURL     *url.URL
+	ControlURL  *url.URL
+	EventSubURL *url.URL
+}
+
+// FindService returns the service of the device or its embedded devices with
+// the domain and type, e.g. "schemas-upnp-org" and "ContentDirectory", in
+// minVersion or higher. Higher versions of a service are backwards
+// compatible, so the highest version found is returned.
+func (d Device) FindService(domain string, serviceType string, minVersion int) (*ServiceEndpoint, error) {
+	wanted := fmt.Sprintf("urn:%s:service:%s", domain, serviceType)
+
+	var endpoint *ServiceEndpoint
+	for _, service := range d.AllServices() {
+		prefix, version, ok := splitTypeVersion(service.ServiceType)
+		if ok && prefix == wanted && version >= minVersion && (endpoint == nil || version > endpoint.Version) {
+			endpoint = &ServiceEndpoint{Service: service, Version: version}
+		}
+	}
+
+	if endpoint == nil {
+		return nil, fmt.Errorf("device %s has no %s:%d service", d.UDN, wanted, minVersion)
+	}
+
+	var err error
+	if endpoint.SCPDURL, err = d.resolveOptionalURL(endpoint.Service.SCPDURL); err != nil {
+		return nil, err
+	}
+	if endpoint.ControlURL, err = d.resolveOptionalURL(endpoint.Service.ControlURL); err != nil {
+		return nil, err
+	}
+	if endpoint.EventSubURL, err = d.resolveOptionalURL(endpoint.Service.EventSubURL); err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+func (d Device) resolveOptionalURL(ref string) (*url.URL, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	return d.ResolveURL(ref)
+}
@@ -0,0 +1,79 @@
+package ssdp
+
+import (
+	"bytes"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SortResponsesByRTT sorts the responses from the fastest to the slowest.
+func SortResponsesByRTT(responses []SearchResponse) {
+	sort.SliceStable(responses, func(i, j int) bool {
+		return responses[i].RTT < responses[j].RTT
+	})
+}
+
+// SortResponsesByAddress sorts the responses by the address they were
+// received from.
+func SortResponsesByAddress(responses []SearchResponse) {
+	sort.SliceStable(responses, func(i, j int) bool {
+		return compareAddr(responses[i].ResponseAddr, responses[j].ResponseAddr) < 0
+	})
+}
+
+// SortDevicesByAddress sorts the devices by the address of their location,
+// and then by the location itself.
+func SortDevicesByAddress(devices []Device) {
+	sort.SliceStable(devices, func(i, j int) bool {
+		if c := compareAddr(locationAddr(devices[i].Location), locationAddr(devices[j].Location)); c != 0 {
+			return c < 0
+		}
+		return urlString(devices[i].Location) < urlString(devices[j].Location)
+	})
+}
+
+// SortDevicesByFriendlyName sorts the devices by friendly name, ignoring case.
+func SortDevicesByFriendlyName(devices []Device) {
+	sort.SliceStable(devices, func(i, j int) bool {
+		return strings.ToLower(devices[i].FriendlyName) < strings.ToLower(devices[j].FriendlyName)
+	})
+}
+
+// SortDevicesByDeviceType sorts the devices by device type.
+func SortDevicesByDeviceType(devices []Device) {
+	sort.SliceStable(devices, func(i, j int) bool {
+		return devices[i].DeviceType < devices[j].DeviceType
+	})
+}
+
+func locationAddr(location *url.URL) *net.UDPAddr {
+	if location == nil {
+		return nil
+	}
+
+	port, _ := strconv.Atoi(location.Port())
+	ip, zone := splitZone(location.Hostname())
+	return &net.UDPAddr{IP: ip, Port: port, Zone: zone}
+}
+
+// compareAddr orders addresses numerically by IP and then by port, with
+// missing addresses last.
+func compareAddr(a *net.UDPAddr, b *net.UDPAddr) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	if c := bytes.Compare(a.IP.To16(), b.IP.To16()); c != 0 {
+		return c
+	}
+
+	return a.Port - b.Port
+}
@@ -0,0 +1,161 @@
+package ssdp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity tells how badly a finding violates the UDA.
+type Severity int
+
+const (
+	// The message violates a MUST of the UDA
+	SeverityError Severity = iota
+	// The message violates a SHOULD of the UDA
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// A Finding is a problem found by validating a message against the UDA.
+type Finding struct {
+	Severity Severity
+	Header   string
+	Problem  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Header, f.Problem)
+}
+
+type strictOption bool
+
+func (s strictOption) apply(opts *options) {
+	opts.strict = bool(s)
+}
+
+// WithStrict validates every search response against the UDA version of
+// WithUDAVersion, UDA 1.0 if unset, and records the findings in
+// SearchResponse.Findings. Fetched descriptions are checked with
+// ValidateDescription into Device.Findings. Both are returned regardless.
+func WithStrict(strict bool) OptionSSDP {
+	return strictOption(strict)
+}
+
+// The SERVER and USER-AGENT form "OS/version UPnP/version product/version".
+var productTokens = regexp.MustCompile(`^\S+/\S+ UPnP/\d\.\d \S+/\S+`)
+
+// ValidateSearchResponse checks that a raw search response has the headers
+// the UDA version requires, in their required formats. The error is only set
+// when the message cannot be parsed at all.
+func ValidateSearchResponse(httpResponse io.Reader, version UDAVersion) ([]Finding, error) {
+	reader, err := limitMessage(httpResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := http.ReadResponse(reader, &http.Request{})
+	if err != nil {
+		return nil, err
+	}
+	headers := response.Header
+
+	if version == 0 {
+		version = UDA10
+	}
+
+	var findings []Finding
+	add := func(severity Severity, header string, format string, args ...interface{}) {
+		findings = append(findings, Finding{Severity: severity, Header: header, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	if response.StatusCode != http.StatusOK {
+		add(SeverityError, "status", "expected 200 OK, got %q", response.Status)
+	} else if response.Status != "200 OK" {
+		add(SeverityWarning, "status", "nonstandard reason phrase in %q", response.Status)
+	}
+	if response.Proto != "HTTP/1.1" {
+		add(SeverityWarning, "status", "expected HTTP/1.1, got %s", response.Proto)
+	}
+
+	control := headers.Get("cache-control")
+	switch maxAge := parseMaxAge(control); {
+	case control == "":
+		add(SeverityError, "CACHE-CONTROL", "missing")
+	case maxAge == 0:
+		add(SeverityError, "CACHE-CONTROL", "no valid max-age in %q", control)
+	case maxAge < 1800*time.Second:
+		add(SeverityWarning, "CACHE-CONTROL", "max-age of %v is below 1800 seconds", maxAge)
+	}
+
+	if _, ok := headers["Ext"]; !ok {
+		add(SeverityError, "EXT", "missing")
+	}
+
+	if location := headers.Get("location"); location == "" {
+		add(SeverityError, "LOCATION", "missing")
+	} else if u, err := url.Parse(location); err != nil || !u.IsAbs() || u.Host == "" {
+		add(SeverityError, "LOCATION", "not an absolute URL: %q", location)
+	}
+
+	if server := headers.Get("server"); server == "" {
+		add(SeverityError, "SERVER", "missing")
+	} else if !productTokens.MatchString(server) {
+		add(SeverityWarning, "SERVER", "not of the form \"OS/version UPnP/version product/version\": %q", server)
+	}
+
+	st := headers.Get("st")
+	if st == "" {
+		add(SeverityError, "ST", "missing")
+	}
+
+	usn := headers.Get("usn")
+	switch {
+	case usn == "":
+		add(SeverityError, "USN", "missing")
+	case !strings.HasPrefix(usn, "uuid:"):
+		add(SeverityError, "USN", "does not start with uuid: %q", usn)
+	case st != "" && !strings.HasPrefix(st, "uuid:") && !strings.HasSuffix(usn, "::"+st):
+		add(SeverityWarning, "USN", "does not end with the ST: %q", usn)
+	}
+
+	if date := headers.Get("date"); date != "" {
+		if _, err := time.Parse(http.TimeFormat, date); err != nil {
+			add(SeverityWarning, "DATE", "not in RFC 1123 format: %q", date)
+		}
+	}
+
+	if version >= UDA11 {
+		for _, header := range []string{"BOOTID.UPNP.ORG", "CONFIGID.UPNP.ORG"} {
+			value := headers.Get(header)
+			if value == "" {
+				add(SeverityError, header, "missing")
+			} else if n, err := strconv.ParseUint(value, 10, 31); err != nil || (header == "CONFIGID.UPNP.ORG" && n > 16777215) {
+				add(SeverityError, header, "not a valid number: %q", value)
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// validate returns the findings for the raw response in strict mode.
+func (opts *options) validate(data []byte) []Finding {
+	if !opts.strict {
+		return nil
+	}
+	findings, _ := ValidateSearchResponse(bytes.NewReader(data), opts.udaVersion)
+	return findings
+}
@@ -0,0 +1,120 @@
+package ssdp
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// String returns a compact one-line summary of the search response.
+func (r SearchResponse) String() string {
+	return fmt.Sprintf("%s (%s) from %s at %s", r.USN, r.ST, addrString(r.ResponseAddr), urlString(r.Location))
+}
+
+// Describe returns a verbose multi-line description of the search response,
+// intended for CLI and debug output.
+func (r SearchResponse) Describe() string {
+	var b strings.Builder
+
+	writeField(&b, "USN", r.USN)
+	writeField(&b, "ST", r.ST)
+	if r.SearchST != r.ST {
+		writeField(&b, "Searched for", r.SearchST)
+	}
+	if r.Location != nil {
+		writeField(&b, "Location", r.Location.String())
+	}
+	if r.SecureLocation != nil {
+		writeField(&b, "Secure location", r.SecureLocation.String())
+	}
+	if r.StatusLine != "" && r.StatusLine != "HTTP/1.1 200 OK" {
+		writeField(&b, "Status", r.StatusLine)
+	}
+	writeField(&b, "Server", r.Server)
+	writeField(&b, "Cache-Control", r.Control)
+	writeField(&b, "Ext", r.Ext)
+	writeField(&b, "NLS", r.NLS)
+	if !r.Date.IsZero() {
+		writeField(&b, "Date", r.Date.String())
+	}
+	if r.ResponseAddr != nil {
+		writeField(&b, "Address", r.ResponseAddr.String())
+	}
+	if r.MAC != nil {
+		writeField(&b, "MAC", r.MAC.String())
+	}
+	writeField(&b, "Hostname", r.Hostname)
+	if r.SearchPort != 0 {
+		writeField(&b, "Search port", strconv.Itoa(r.SearchPort))
+	}
+	writeField(&b, "Group", r.Group)
+	if r.LocalAddr != nil {
+		writeField(&b, "Received on", r.LocalAddr.String())
+	}
+	for _, finding := range r.Findings {
+		writeField(&b, "Finding", finding.String())
+	}
+	if r.Duplicate > 0 {
+		writeField(&b, "Duplicate", strconv.Itoa(r.Duplicate))
+	}
+
+	return b.String()
+}
+
+// String returns a compact one-line summary of the device.
+func (d Device) String() string {
+	return fmt.Sprintf("%s (%s %s) %s at %s", d.FriendlyName, d.Manufacturer, d.ModelName, d.UDN, urlString(d.Location))
+}
+
+// Describe returns a verbose multi-line description of the device, intended
+// for CLI and debug output.
+func (d Device) Describe() string {
+	var b strings.Builder
+
+	writeField(&b, "Friendly name", d.FriendlyName)
+	writeField(&b, "Device type", d.DeviceType)
+	writeField(&b, "UDN", d.UDN)
+	writeField(&b, "Manufacturer", d.Manufacturer)
+	writeField(&b, "Model", strings.TrimSpace(d.ModelName+" "+d.ModelNumber))
+	writeField(&b, "Description", d.ModelDescription)
+	writeField(&b, "Serial number", d.SerialNumber)
+	writeField(&b, "Spec version", fmt.Sprintf("%d.%d", d.SpecVersion.Major, d.SpecVersion.Minor))
+	if d.Location != nil {
+		writeField(&b, "Location", d.Location.String())
+	}
+	writeField(&b, "Presentation URL", d.PresentationURL)
+	for _, icon := range d.Icons {
+		writeField(&b, "Icon", fmt.Sprintf("%s %dx%dx%d %s", icon.MIMEType, icon.Width, icon.Height, icon.Depth, icon.URL))
+	}
+	for _, service := range d.Services {
+		writeField(&b, "Service", fmt.Sprintf("%s %s", service.ServiceType, service.ControlURL))
+	}
+	if _, ok := d.DeviceProtection(); ok {
+		writeField(&b, "Protection", "Device Protection")
+	}
+
+	return b.String()
+}
+
+func writeField(b *strings.Builder, name string, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(b, "%-17s %s\n", name+":", value)
+}
+
+func addrString(addr *net.UDPAddr) string {
+	if addr == nil {
+		return "-"
+	}
+	return addr.String()
+}
+
+func urlString(u *url.URL) string {
+	if u == nil {
+		return "-"
+	}
+	return u.String()
+}
@@ -0,0 +1,45 @@
+package ssdp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RootDevice is the search target answered once by every root device.
+const RootDevice = "upnp:rootdevice"
+
+// MatchesST reports whether a device or service of the type responseST
+// answers a search for searchST. Every type answers ssdp:all, and a device or
+// service type URN answers searches for its own and all lower versions of the
+// type, as the UDA requires of backwards compatible versions: MediaServer:2
+// answers a search for MediaServer:1, but not the other way around. Other
+// targets, like UDNs and upnp:rootdevice, must match exactly.
+func MatchesST(responseST string, searchST string) bool {
+	if searchST == ALL.String() || responseST == searchST {
+		return true
+	}
+
+	responseType, responseVersion, ok := splitTypeVersion(responseST)
+	if !ok {
+		return false
+	}
+	searchType, searchVersion, ok := splitTypeVersion(searchST)
+	return ok && responseType == searchType && responseVersion >= searchVersion
+}
+
+// splitTypeVersion splits a device or service type URN, such as
+// "urn:schemas-upnp-org:device:MediaServer:2", into the type without the
+// version and the version.
+func splitTypeVersion(urn string) (string, int, bool) {
+	parts := strings.Split(urn, ":")
+	if len(parts) != 5 || parts[0] != "urn" || (parts[2] != "device" && parts[2] != "service") {
+		return "", 0, false
+	}
+
+	version, err := strconv.Atoi(parts[4])
+	if err != nil || version < 1 {
+		return "", 0, false
+	}
+
+	return strings.Join(parts[:4], ":"), version, true
+}
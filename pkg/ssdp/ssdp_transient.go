@@ -0,0 +1,32 @@
+package ssdp
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// The number of consecutive transient read errors after which a search
+// gives up.
+const maxTransientErrors = 16
+
+// How long to wait before reading again after a transient error, so a
+// socket out of buffers is not spun on.
+const transientErrorDelay = 10 * time.Millisecond
+
+// isTransient reports whether a read error is likely to go away, such as an
+// interrupted system call or an ICMP error reported on the socket, rather
+// than ending the search.
+func isTransient(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return false
+	}
+	for _, errno := range []syscall.Errno{syscall.EINTR, syscall.EAGAIN, syscall.ENOBUFS, syscall.ENOMEM, syscall.ECONNREFUSED, syscall.EHOSTUNREACH, syscall.ENETUNREACH} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
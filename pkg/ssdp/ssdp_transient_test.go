@@ -0,0 +1,102 @@
+package ssdp
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// A read scripted for a fakeReader.
+type fakeRead struct {
+	data string
+	err  error
+}
+
+// fakeReader returns the scripted reads in turn, then blocks until a read
+// deadline is set.
+type fakeReader struct {
+	mu      sync.Mutex
+	reads   []fakeRead
+	expired chan struct{}
+	once    sync.Once
+}
+
+func newFakeReader(reads ...fakeRead) *fakeReader {
+	return &fakeReader{reads: reads, expired: make(chan struct{})}
+}
+
+func (f *fakeReader) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	f.mu.Lock()
+	if len(f.reads) == 0 {
+		f.mu.Unlock()
+		<-f.expired
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+	read := f.reads[0]
+	f.reads = f.reads[1:]
+	f.mu.Unlock()
+
+	if read.err != nil {
+		return 0, nil, read.err
+	}
+	return copy(b, read.data), &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1900}, nil
+}
+
+func (f *fakeReader) SetReadDeadline(t time.Time) error {
+	if !t.IsZero() {
+		f.once.Do(func() { close(f.expired) })
+	}
+	return nil
+}
+
+func transientReadError() error {
+	return &net.OpError{Op: "read", Net: "udp", Err: os.NewSyscallError("recvfrom", syscall.ENOBUFS)}
+}
+
+func receivePacket(t *testing.T, packets <-chan packet) packet {
+	t.Helper()
+	select {
+	case p := <-packets:
+		return p
+	case <-time.After(time.Second):
+		t.Fatal("no packet read")
+		return packet{}
+	}
+}
+
+func Test_ReadPacketsRetriesTransientErrors(t *testing.T) {
+	reader := newFakeReader(fakeRead{err: transientReadError()}, fakeRead{data: "HTTP/1.1 200 OK\r\n\r\n"})
+	packets := make(chan packet)
+	stop := readPackets(groupReader{reader, "239.255.255.250"}, packets)
+	defer stop()
+
+	if p := receivePacket(t, packets); !p.transient || p.err == nil {
+		t.Errorf("expected the transient error to be passed on, got %+v", p)
+	}
+	if p := receivePacket(t, packets); p.err != nil || string(p.data) != "HTTP/1.1 200 OK\r\n\r\n" {
+		t.Errorf("expected reading to go on after the transient error, got %+v", p)
+	}
+}
+
+func Test_ReadPacketsGivesUpAfterTransientErrors(t *testing.T) {
+	reads := make([]fakeRead, maxTransientErrors+1)
+	for i := range reads {
+		reads[i] = fakeRead{err: transientReadError()}
+	}
+	reader := newFakeReader(reads...)
+	packets := make(chan packet)
+	stop := readPackets(groupReader{reader, "239.255.255.250"}, packets)
+	defer stop()
+
+	for i := 0; i < maxTransientErrors; i++ {
+		if p := receivePacket(t, packets); !p.transient {
+			t.Fatalf("expected error %d to be transient, got %+v", i+1, p)
+		}
+	}
+	if p := receivePacket(t, packets); p.transient || p.err == nil {
+		t.Errorf("expected the search to fail after %d transient errors, got %+v", maxTransientErrors, p)
+	}
+}
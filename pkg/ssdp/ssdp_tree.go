@@ -0,0 +1,106 @@
+package ssdp
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// A DeviceNode is a device assembled from search responses, with the
+// services and embedded devices that answered for it.
+type DeviceNode struct {
+	UDN          string
+	DeviceTypes  []string
+	ServiceTypes []string
+	Embedded     []DeviceNode
+}
+
+// A DeviceTree is a root device assembled from search responses.
+type DeviceTree struct {
+	DeviceNode
+	Location *url.URL
+	Server   string
+	Addr     *net.UDPAddr
+}
+
+// GroupResponses groups the responses of an ssdp:all search into root devices.
+// Embedded devices share the description location of their root device, so
+// the device that answered as upnp:rootdevice at a location is the root and
+// the others at that location are embedded in it. Responses do not tell how
+// deeply a device is embedded, so all embedded devices are direct children of
+// the root. Responses without a location are left out.
+func GroupResponses(responses []SearchResponse) []DeviceTree {
+	trees := make(map[string]*DeviceTree)
+	nodes := make(map[string]map[string]*DeviceNode)
+	var order []string
+
+	for _, response := range responses {
+		if response.Location == nil {
+			continue
+		}
+
+		location := response.Location.String()
+		tree, ok := trees[location]
+		if !ok {
+			tree = &DeviceTree{Location: response.Location, Server: response.Server, Addr: response.ResponseAddr}
+			trees[location] = tree
+			nodes[location] = make(map[string]*DeviceNode)
+			order = append(order, location)
+		}
+
+		udn := udnFromUSN(response.USN)
+		node, ok := nodes[location][udn]
+		if !ok {
+			node = &DeviceNode{UDN: udn}
+			nodes[location][udn] = node
+		}
+
+		switch {
+		case response.ST == RootDevice:
+			tree.UDN = udn
+		case strings.Contains(response.ST, ":device:"):
+			node.DeviceTypes = appendUnique(node.DeviceTypes, response.ST)
+		case strings.Contains(response.ST, ":service:"):
+			node.ServiceTypes = appendUnique(node.ServiceTypes, response.ST)
+		}
+	}
+
+	result := make([]DeviceTree, 0, len(order))
+	for _, location := range order {
+		tree := trees[location]
+
+		udns := make([]string, 0, len(nodes[location]))
+		for udn := range nodes[location] {
+			udns = append(udns, udn)
+		}
+		sort.Strings(udns)
+
+		// Without a upnp:rootdevice answer the first device stands in for
+		// the root.
+		if tree.UDN == "" {
+			tree.UDN = udns[0]
+		}
+
+		for _, udn := range udns {
+			if udn == tree.UDN {
+				root := nodes[location][udn]
+				tree.DeviceTypes = root.DeviceTypes
+				tree.ServiceTypes = root.ServiceTypes
+				continue
+			}
+			tree.Embedded = append(tree.Embedded, *nodes[location][udn])
+		}
+
+		result = append(result, *tree)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if c := compareAddr(locationAddr(result[i].Location), locationAddr(result[j].Location)); c != 0 {
+			return c < 0
+		}
+		return urlString(result[i].Location) < urlString(result[j].Location)
+	})
+
+	return result
+}
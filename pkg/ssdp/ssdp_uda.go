@@ -0,0 +1,82 @@
+package ssdp
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// UDAVersion is a version of the UPnP Device Architecture.
+type UDAVersion int
+
+// The zero UDAVersion is lenient: it emits UDA 1.0 messages and accepts
+// anything that can be parsed.
+const (
+	UDA10 UDAVersion = 10
+	UDA11 UDAVersion = 11
+	UDA20 UDAVersion = 20
+)
+
+func (v UDAVersion) String() string {
+	if v == 0 {
+		return "lenient"
+	}
+	return fmt.Sprintf("%d.%d", int(v)/10, int(v)%10)
+}
+
+type udaVersionOption UDAVersion
+
+func (u udaVersionOption) apply(opts *options) {
+	opts.udaVersion = UDAVersion(u)
+}
+
+// WithUDAVersion selects the UDA version to follow. UDA 1.1 adds a
+// USER-AGENT to searches and BOOTID.UPNP.ORG and CONFIGID.UPNP.ORG to
+// announcements, UDA 2.0 adds the CPFN.UPNP.ORG and CPUUID.UPNP.ORG control
+// point headers to searches, see WithControlPoint.
+func WithUDAVersion(version UDAVersion) OptionSSDP {
+	return udaVersionOption(version)
+}
+
+type controlPointOption struct {
+	friendlyName string
+	uuid         string
+}
+
+func (c controlPointOption) apply(opts *options) {
+	opts.cpFriendlyName = c.friendlyName
+	opts.cpUUID = c.uuid
+}
+
+// WithControlPoint sets the friendly name and UUID the control point
+// identifies itself with in UDA 2.0 searches. The UUID is optional.
+func WithControlPoint(friendlyName string, uuid string) OptionSSDP {
+	return controlPointOption{friendlyName: friendlyName, uuid: uuid}
+}
+
+// userAgent returns the USER-AGENT header for the UDA version, in the
+// "OS/version UPnP/version product/version" form.
+func (v UDAVersion) userAgent() string {
+	return fmt.Sprintf("%s/1.0 UPnP/%s gossdp/1.0", runtime.GOOS, v)
+}
+
+// searchHeaders returns the version specific headers of an M-SEARCH.
+func (opts *options) searchHeaders() map[string]string {
+	headers := make(map[string]string)
+
+	if opts.udaVersion >= UDA11 {
+		headers["User-Agent"] = opts.udaVersion.userAgent()
+	}
+
+	if opts.udaVersion >= UDA20 {
+		friendlyName := opts.cpFriendlyName
+		if friendlyName == "" {
+			friendlyName = "gossdp"
+		}
+		headers["CPFN.UPNP.ORG"] = friendlyName
+		if opts.cpUUID != "" {
+			headers["CPUUID.UPNP.ORG"] = opts.cpUUID
+		}
+	}
+
+	return headers
+}
@@ -0,0 +1,63 @@
+package ssdp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrPortUnreachable is returned by Probe when the host of the device
+// rejected the search with ICMP port unreachable: it is up, but nothing
+// listens on the port or a firewall rejects the search. It wraps
+// ErrNoResponse. Only Linux reports the rejection; elsewhere the probe times
+// out with ErrNoResponse.
+var ErrPortUnreachable = fmt.Errorf("%w: port unreachable", ErrNoResponse)
+
+// An Unreachable is a destination that rejected unicast searches with ICMP
+// port unreachable.
+type Unreachable struct {
+	Addr  string
+	Count int
+	Last  time.Time
+}
+
+// unreachables counts the rejections of unicast searches by destination.
+type unreachables struct {
+	mu           sync.Mutex
+	destinations map[string]*Unreachable
+}
+
+func (u *unreachables) add(addr string, now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.destinations == nil {
+		u.destinations = make(map[string]*Unreachable)
+	}
+	destination, ok := u.destinations[addr]
+	if !ok {
+		destination = &Unreachable{Addr: addr}
+		u.destinations[addr] = destination
+	}
+	destination.Count++
+	destination.Last = now
+}
+
+// Unreachables returns the destinations that rejected probes with ICMP port
+// unreachable, sorted by address. A device that is gone doesn't answer at
+// all, while one behind a firewall rejecting SSDP, or whose SSDP stack
+// crashed, shows up here.
+func (ssdp *SSDP) Unreachables() []Unreachable {
+	ssdp.unreachable.mu.Lock()
+	defer ssdp.unreachable.mu.Unlock()
+
+	destinations := make([]Unreachable, 0, len(ssdp.unreachable.destinations))
+	for _, destination := range ssdp.unreachable.destinations {
+		destinations = append(destinations, *destination)
+	}
+	sort.Slice(destinations, func(i, j int) bool {
+		return destinations[i].Addr < destinations[j].Addr
+	})
+	return destinations
+}
@@ -0,0 +1,62 @@
+package ssdp
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// The MX bounds from the UPnP Device Architecture.
+const (
+	minMX = 1
+	maxMX = 5
+)
+
+// NewValidatedSSDP creates an SSDP client like NewSSDP, but returns an error
+// when the options are invalid.
+func NewValidatedSSDP(opts ...OptionSSDP) (*SSDP, error) {
+	ssdp := NewSSDP(opts...)
+
+	if err := ssdp.Validate(); err != nil {
+		return nil, err
+	}
+
+	return ssdp, nil
+}
+
+// Validate checks that the options describe a usable search configuration.
+func (opts *options) Validate() error {
+	if opts.port < 1 || opts.port > 65535 {
+		return fmt.Errorf("invalid port %d", opts.port)
+	}
+
+	for _, group := range opts.searchGroups() {
+		ip := net.ParseIP(group)
+		if ip == nil {
+			return fmt.Errorf("invalid broadcast ip %q", group)
+		}
+		if !ip.IsMulticast() {
+			return fmt.Errorf("broadcast ip %s is not a multicast address", ip)
+		}
+	}
+
+	if opts.timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %v", opts.timeout)
+	}
+
+	if mx := int(opts.timeout / time.Second); mx < minMX || mx > maxMX {
+		return fmt.Errorf("timeout of %v gives an MX of %d, must be between %d and %d", opts.timeout, mx, minMX, maxMX)
+	}
+
+	if opts.iface != "" {
+		if _, err := InterfaceIP(opts.iface); err != nil {
+			return fmt.Errorf("invalid interface %q: %w", opts.iface, err)
+		}
+	}
+
+	if opts.clock == nil {
+		return fmt.Errorf("clock must not be nil")
+	}
+
+	return nil
+}
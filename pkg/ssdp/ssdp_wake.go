@@ -0,0 +1,68 @@
+package ssdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// The port Wake-on-LAN packets are sent to.
+const wakePort = 9
+
+// MagicPacket returns the Wake-on-LAN packet waking the device with the MAC:
+// six 0xff bytes followed by the MAC repeated sixteen times.
+func MagicPacket(mac net.HardwareAddr) ([]byte, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("wake-on-lan needs a 48-bit MAC, got %s", mac)
+	}
+	return append(bytes.Repeat([]byte{0xff}, 6), bytes.Repeat(mac, 16)...), nil
+}
+
+// WakeAndDiscover wakes the device with the MAC, such as a TV or NAS found
+// earlier with WithResolveMAC, and probes it at addr until it answers. The
+// magic packet is broadcast on the subnet of addr before each probe, as a
+// sleeping network card may miss the first. Devices take a while to boot,
+// so WakeAndDiscover keeps trying until the context is done.
+func (ssdp *SSDP) WakeAndDiscover(ctx context.Context, mac net.HardwareAddr, addr *net.UDPAddr, search string) (*SearchResponse, error) {
+	packet, err := MagicPacket(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	broadcastAddr := &net.UDPAddr{IP: wakeBroadcast(addr.IP), Port: wakePort}
+	for {
+		if _, err := conn.WriteTo(packet, broadcastAddr); err != nil {
+			return nil, err
+		}
+
+		response, err := ssdp.Probe(ctx, addr, search)
+		if !errors.Is(err, ErrNoResponse) {
+			return response, err
+		}
+	}
+}
+
+// wakeBroadcast returns the broadcast address of the local subnet holding
+// the IP, or the limited broadcast address when it is not on link.
+func wakeBroadcast(ip net.IP) net.IP {
+	for _, prefix := range localPrefixes() {
+		network := prefix.IP.To4()
+		if network == nil || len(prefix.Mask) != net.IPv4len || !prefix.Contains(ip) {
+			continue
+		}
+		broadcast := make(net.IP, net.IPv4len)
+		for i := range broadcast {
+			broadcast[i] = network[i] | ^prefix.Mask[i]
+		}
+		return broadcast
+	}
+	return net.IPv4bcast
+}
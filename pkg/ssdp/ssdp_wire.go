@@ -0,0 +1,61 @@
+package ssdp
+
+import (
+	"context"
+	"regexp"
+)
+
+// A Redactor rewrites wire text before it is logged, e.g. to strip device
+// identifiers from diagnostics shared publicly.
+type Redactor func(wire string) string
+
+var (
+	uuidPattern   = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	serialPattern = regexp.MustCompile(`(?i)(<serialNumber>)[^<]*(</serialNumber>)`)
+	rinconPattern = regexp.MustCompile(`RINCON_[0-9A-Fa-f]+`)
+	macPattern    = regexp.MustCompile(`(?i)\b[0-9a-f]{2}([:-][0-9a-f]{2}){5}\b`)
+)
+
+// RedactUUIDs replaces UUIDs, such as those of UDNs and USNs.
+func RedactUUIDs(wire string) string {
+	return uuidPattern.ReplaceAllString(wire, "<uuid>")
+}
+
+// RedactSerials replaces serial numbers, including those Sonos devices use
+// as UDN.
+func RedactSerials(wire string) string {
+	wire = serialPattern.ReplaceAllString(wire, "${1}<serial>${2}")
+	return rinconPattern.ReplaceAllString(wire, "RINCON_<serial>")
+}
+
+// RedactMACs replaces MAC addresses.
+func RedactMACs(wire string) string {
+	return macPattern.ReplaceAllString(wire, "<mac>")
+}
+
+type wireLogOption []Redactor
+
+func (w wireLogOption) apply(opts *options) {
+	opts.wireLog = true
+	opts.redactors = w
+}
+
+// WithWireLog logs the wire text of the searches sent and of every packet
+// received to the logger of WithLogger, after passing it through the
+// redactors in order. The wire text is logged in the "wire" field.
+func WithWireLog(redactors ...Redactor) OptionSSDP {
+	return wireLogOption(redactors)
+}
+
+// logWire logs the wire text when wire logging is enabled.
+func (opts *options) logWire(ctx context.Context, msg string, wire []byte, keysAndValues ...interface{}) {
+	if !opts.wireLog || opts.logger == nil {
+		return
+	}
+
+	text := string(wire)
+	for _, redact := range opts.redactors {
+		text = redact(text)
+	}
+	opts.log(ctx, msg, append(keysAndValues, "wire", text)...)
+}
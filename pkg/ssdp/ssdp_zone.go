@@ -0,0 +1,42 @@
+package ssdp
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// splitZone parses an IP address that may carry an IPv6 zone, like
+// "fe80::1%eth0". The IP is nil when the host is not an address.
+func splitZone(host string) (net.IP, string) {
+	zone := ""
+	if i := strings.LastIndexByte(host, '%'); i >= 0 {
+		host, zone = host[:i], host[i+1:]
+	}
+	return net.ParseIP(host), zone
+}
+
+// addZone returns the location with the zone of the address the message came
+// from, when the location is a link-local IPv6 address without one. Devices
+// cannot know the zone of their own address on the receiving host, and
+// without it connections to the location fail.
+func addZone(location *url.URL, addr *net.UDPAddr) *url.URL {
+	if location == nil || addr == nil || addr.Zone == "" {
+		return location
+	}
+
+	ip, zone := splitZone(location.Hostname())
+	if ip == nil || ip.To4() != nil || zone != "" || !(ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()) {
+		return location
+	}
+
+	zoned := *location
+	host := ip.String() + "%" + addr.Zone
+	if port := location.Port(); port != "" {
+		zoned.Host = net.JoinHostPort(host, port)
+	} else {
+		zoned.Host = "[" + host + "]"
+	}
+
+	return &zoned
+}
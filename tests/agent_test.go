@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/Oleaintueri/gossdp/pkg/agent"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net"
+	"strings"
+	"testing"
+)
+
+// tokenAuthenticate names agents by the token in the metadata of their stream.
+func tokenAuthenticate(tokens map[string]string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, token := range md.Get("token") {
+			if name, ok := tokens[token]; ok {
+				return name, nil
+			}
+		}
+		return "", errors.New("unknown token")
+	}
+}
+
+// agentServer serves the agent service for the registry, returning a
+// connection to it.
+func agentServer(t *testing.T, registry *ssdp.Registry, opts ...agent.ServerOption) *grpc.ClientConn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	agent.Register(server, registry, opts...)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func forwardResponse(conn *grpc.ClientConn, token string, response ssdp.SearchResponse) error {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "token", token)
+	forwarder, err := agent.Dial(ctx, conn)
+	if err != nil {
+		return err
+	}
+	forwarder.Response(response)
+	return forwarder.Close()
+}
+
+func Test_AgentForwarding(t *testing.T) {
+	registry := ssdp.NewRegistry()
+	conn := agentServer(t, registry, agent.WithAuthenticate(tokenAuthenticate(map[string]string{"secret-7": "building-7"})))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "token", "secret-7")
+	forwarder, err := agent.Dial(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := forwarder.Response(registryResponse("uuid:printer::upnp:rootdevice", "upnp:rootdevice", "10.7.0.20")); err != nil {
+		t.Fatal(err)
+	}
+	location := registryResponse("", "", "10.7.0.21").Location
+	if err := forwarder.Notify(ssdp.Notify{NT: "upnp:rootdevice", NTS: ssdp.NTSAlive, USN: "uuid:camera::upnp:rootdevice", Control: "max-age=1800", Location: location}); err != nil {
+		t.Fatal(err)
+	}
+	if err := forwarder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if registry.Len() != 2 {
+		t.Fatalf("expected 2 devices, got %d", registry.Len())
+	}
+	if entry, _ := registry.ByUDN("uuid:printer"); entry.Addr == nil || entry.Addr.IP.String() != "10.7.0.20" || entry.Location.Host != "10.7.0.20:1400" || entry.Agent != "building-7" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if entry, _ := registry.ByUDN("uuid:camera"); entry.Agent != "building-7" {
+		t.Errorf("expected the agent to be named by its token, got %+v", entry)
+	}
+	if err := forwarder.Response(ssdp.SearchResponse{}); err != agent.ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func Test_AgentAuthenticate(t *testing.T) {
+	registry := ssdp.NewRegistry()
+	conn := agentServer(t, registry, agent.WithAuthenticate(tokenAuthenticate(map[string]string{"secret-7": "building-7"})))
+
+	if err := forwardResponse(conn, "guess", registryResponse("uuid:printer::upnp:rootdevice", "upnp:rootdevice", "10.7.0.20")); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected an unknown token to be unauthenticated, got %v", err)
+	}
+	if registry.Len() != 0 {
+		t.Errorf("expected nothing to be recorded for an unknown token, got %d devices", registry.Len())
+	}
+
+	refusing := agentServer(t, registry)
+	if err := forwardResponse(refusing, "secret-7", registryResponse("uuid:printer::upnp:rootdevice", "upnp:rootdevice", "10.7.0.20")); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected streams to be refused without WithAuthenticate, got %v", err)
+	}
+	if registry.Len() != 0 {
+		t.Errorf("expected nothing to be recorded without WithAuthenticate, got %d devices", registry.Len())
+	}
+}
+
+func Test_AgentObservationSize(t *testing.T) {
+	registry := ssdp.NewRegistry()
+	conn := agentServer(t, registry, agent.WithAuthenticate(tokenAuthenticate(map[string]string{"secret-7": "building-7"})))
+
+	response := registryResponse("uuid:printer::upnp:rootdevice", "upnp:rootdevice", "10.7.0.20")
+	response.Server = strings.Repeat("x", agent.MaxObservationSize)
+	if err := forwardResponse(conn, "secret-7", response); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected an oversized observation to be refused, got %v", err)
+	}
+	if registry.Len() != 0 {
+		t.Errorf("expected the oversized observation not to be recorded, got %d devices", registry.Len())
+	}
+}
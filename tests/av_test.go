@@ -0,0 +1,278 @@
+package tests
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/av"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const soapResponse = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+<s:Body><u:%[1]sResponse xmlns:u="%[2]s">%[3]s</u:%[1]sResponse></s:Body>
+</s:Envelope>`
+
+const soapFault = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+<s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring>
+<detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError></detail>
+</s:Fault></s:Body>
+</s:Envelope>`
+
+const didlResult = `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">
+<container id="64" parentID="0" restricted="1" childCount="3"><dc:title>Browse Folders</dc:title><upnp:class>object.container.storageFolder</upnp:class></container>
+<item id="64$0" parentID="64" restricted="1"><dc:title>Song</dc:title><upnp:class>object.item.audioItem.musicTrack</upnp:class><upnp:artist>Artist</upnp:artist><upnp:album>Album</upnp:album>
+<res size="3558000" duration="0:03:42.000" protocolInfo="http-get:*:audio/mpeg:DLNA.ORG_PN=MP3">http://192.168.1.40:8200/MediaItems/22.mp3</res></item>
+</DIDL-Lite>`
+
+// soapAction is a fake action of a fake service, answering with the given
+// arguments or a UPnP error code.
+type soapAction struct {
+	args      map[string]string
+	errorCode int
+}
+
+// newAVDevice serves the fake actions and returns a device with a service of
+// the given type pointing at them.
+func newAVDevice(t *testing.T, serviceType string, actions map[string]soapAction, requests map[string]string) (ssdp.Device, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		soapAction := strings.Trim(r.Header.Get("SOAPAction"), `"`)
+		name := soapAction[strings.Index(soapAction, "#")+1:]
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if requests != nil {
+			requests[name] = string(body)
+		}
+
+		action, ok := actions[name]
+		if !ok {
+			t.Errorf("unexpected action %s", soapAction)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if action.errorCode != 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, soapFault, action.errorCode, "Invalid Action")
+			return
+		}
+
+		args := &strings.Builder{}
+		for name, value := range action.args {
+			args.WriteString("<" + name + ">")
+			xml.EscapeText(args, []byte(value))
+			args.WriteString("</" + name + ">")
+		}
+		fmt.Fprintf(w, soapResponse, name, serviceType, args.String())
+	}))
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	device := ssdp.Device{
+		UDN:      "uuid:test",
+		Location: location,
+		Devices: []ssdp.EmbeddedDevice{{
+			Services: []ssdp.Service{{ServiceType: serviceType, ControlURL: "/ctl/service"}},
+		}},
+	}
+
+	return device, server.Close
+}
+
+func Test_ContentDirectoryBrowse(t *testing.T) {
+	requests := map[string]string{}
+	device, closeServer := newAVDevice(t, "urn:schemas-upnp-org:service:ContentDirectory:1", map[string]soapAction{
+		"Browse": {args: map[string]string{"Result": didlResult, "NumberReturned": "2", "TotalMatches": "2", "UpdateID": "7"}},
+		"Search": {errorCode: 708},
+	}, requests)
+	defer closeServer()
+
+	contentDirectory, err := av.NewContentDirectory(device, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := contentDirectory.Browse(context.Background(), av.RootObjectID, av.BrowseDirectChildren, "*", 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(requests["Browse"], "<ObjectID>0</ObjectID><BrowseFlag>BrowseDirectChildren</BrowseFlag>") {
+		t.Errorf("unexpected request %s", requests["Browse"])
+	}
+
+	if result.TotalMatches != 2 || result.UpdateID != 7 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	if len(result.DIDL.Containers) != 1 || result.DIDL.Containers[0].Title != "Browse Folders" || result.DIDL.Containers[0].ChildCount != 3 {
+		t.Errorf("unexpected containers %+v", result.DIDL.Containers)
+	}
+
+	if len(result.DIDL.Items) != 1 {
+		t.Fatalf("unexpected items %+v", result.DIDL.Items)
+	}
+
+	item := result.DIDL.Items[0]
+	if !item.IsAudio() || item.Artist != "Artist" || item.Resources[0].URL != "http://192.168.1.40:8200/MediaItems/22.mp3" ||
+		item.Resources[0].Size != 3558000 {
+		t.Errorf("unexpected item %+v", item)
+	}
+
+	_, err = contentDirectory.Search(context.Background(), av.RootObjectID, `upnp:class derivedfrom "object.item.audioItem"`, "*", 0, 0, "")
+
+	var upnpErr *soap.UPnPError
+	if !errors.As(err, &upnpErr) || upnpErr.Code != 708 {
+		t.Errorf("expected upnp error 708, got %v", err)
+	}
+}
+
+func Test_AVTransport(t *testing.T) {
+	requests := map[string]string{}
+	device, closeServer := newAVDevice(t, "urn:schemas-upnp-org:service:AVTransport:1", map[string]soapAction{
+		"SetAVTransportURI": {},
+		"Play":              {},
+		"Seek":              {},
+		"GetPositionInfo": {args: map[string]string{"Track": "1", "TrackDuration": "0:03:42", "RelTime": "0:01:30",
+			"AbsTime": "NOT_IMPLEMENTED", "RelCount": "2147483647", "AbsCount": "2147483647", "TrackURI": "http://example.com/a.mp3"}},
+	}, requests)
+	defer closeServer()
+
+	transport, err := av.NewAVTransport(device, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := transport.SetAVTransportURI(ctx, "http://example.com/a.mp3?x=1&y=2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(requests["SetAVTransportURI"], "<InstanceID>0</InstanceID><CurrentURI>http://example.com/a.mp3?x=1&amp;y=2</CurrentURI>") {
+		t.Errorf("unexpected request %s", requests["SetAVTransportURI"])
+	}
+
+	if err := transport.Play(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Seek(ctx, av.SeekRelTime, "0:01:30"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := transport.GetPositionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Track != 1 || info.RelTime != "0:01:30" || info.TrackURI != "http://example.com/a.mp3" {
+		t.Errorf("unexpected position info %+v", info)
+	}
+}
+
+func Test_ParseLastChange(t *testing.T) {
+	lastChange, err := av.ParseLastChange(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/">
+<InstanceID val="0"><TransportState val="PLAYING"/><CurrentTrackURI val="http://example.com/a.mp3"/></InstanceID>
+</Event>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, ok := lastChange.Instance(0)
+	if !ok {
+		t.Fatal("expected instance 0")
+	}
+
+	if state, _ := instance.Variable("TransportState"); state != "PLAYING" {
+		t.Errorf("expected PLAYING, got %q", state)
+	}
+}
+
+func Test_RenderingControl(t *testing.T) {
+	requests := map[string]string{}
+	device, closeServer := newAVDevice(t, "urn:schemas-upnp-org:service:RenderingControl:1", map[string]soapAction{
+		"GetVolume": {args: map[string]string{"CurrentVolume": "42"}},
+		"SetVolume": {},
+		"GetMute":   {args: map[string]string{"CurrentMute": "1"}},
+		"SetMute":   {},
+	}, requests)
+	defer closeServer()
+
+	renderingControl, err := av.NewRenderingControl(device, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if volume, err := renderingControl.GetVolume(ctx, av.ChannelMaster); err != nil || volume != 42 {
+		t.Errorf("expected volume 42, got %d (%v)", volume, err)
+	}
+
+	if err := renderingControl.SetVolume(ctx, av.ChannelMaster, 10); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(requests["SetVolume"], "<InstanceID>0</InstanceID><Channel>Master</Channel><DesiredVolume>10</DesiredVolume>") {
+		t.Errorf("unexpected request %s", requests["SetVolume"])
+	}
+
+	if mute, err := renderingControl.GetMute(ctx, av.ChannelMaster); err != nil || !mute {
+		t.Errorf("expected muted, got %v (%v)", mute, err)
+	}
+
+	if err := renderingControl.SetMute(ctx, av.ChannelMaster, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(requests["SetMute"], "<DesiredMute>0</DesiredMute>") {
+		t.Errorf("unexpected request %s", requests["SetMute"])
+	}
+}
+
+func Test_ConnectionManagerCanPlay(t *testing.T) {
+	device, closeServer := newAVDevice(t, "urn:schemas-upnp-org:service:ConnectionManager:1", map[string]soapAction{
+		"GetProtocolInfo": {args: map[string]string{
+			"Source": "",
+			"Sink": "http-get:*:audio/mpeg:DLNA.ORG_PN=MP3,http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_BL_CIF15_AAC_520;DLNA.ORG_OP=01," +
+				"http-get:*:image/*:*,rtsp-rtp-udp:*:video/h264:*",
+		}},
+	}, nil)
+	defer closeServer()
+
+	connectionManager, err := av.NewConnectionManager(device, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, sink, err := connectionManager.GetProtocolInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(source) != 0 || len(sink) != 4 {
+		t.Fatalf("unexpected protocol info %v %v", source, sink)
+	}
+
+	tests := []struct {
+		mimeType string
+		profile  string
+		canPlay  bool
+	}{
+		{"audio/mpeg", "", true},
+		{"audio/mpeg", "MP3", true},
+		{"audio/mpeg", "MP3X_44", false},
+		{"video/mp4", "AVC_MP4_BL_CIF15_AAC_520", true},
+		{"image/jpeg", "JPEG_LRG", true},
+		{"video/h264", "", false},
+		{"audio/flac", "", false},
+	}
+
+	for _, test := range tests {
+		if _, ok := av.CanPlay(sink, test.mimeType, test.profile); ok != test.canPlay {
+			t.Errorf("%s %s: expected %v", test.mimeType, test.profile, test.canPlay)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/certify"
+	"github.com/Oleaintueri/gossdp/pkg/gena"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const certifyDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+<specVersion><major>1</major><minor>0</minor></specVersion>
+<device>
+<deviceType>urn:schemas-upnp-org:device:BinaryLight:1</deviceType>
+<friendlyName>Hall light</friendlyName>
+<manufacturer>Example</manufacturer>
+<modelName>Light</modelName>
+<UDN>uuid:light</UDN>
+<serviceList>
+<service><serviceType>urn:schemas-upnp-org:service:SwitchPower:1</serviceType><serviceId>urn:upnp-org:serviceId:SwitchPower</serviceId><SCPDURL>/switch.xml</SCPDURL><controlURL>/switch/control</controlURL><eventSubURL>/switch/event</eventSubURL></service>
+</serviceList>
+</device>
+</root>`
+
+const switchPowerSCPD = `<?xml version="1.0"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+<actionList>
+<action><name>GetStatus</name><argumentList>
+<argument><name>ResultStatus</name><direction>out</direction><relatedStateVariable>Status</relatedStateVariable></argument>
+</argumentList></action>
+</actionList>
+<serviceStateTable>
+<stateVariable sendEvents="no"><name>Target</name><dataType>boolean</dataType></stateVariable>
+<stateVariable sendEvents="yes"><name>Status</name><dataType>boolean</dataType></stateVariable>
+</serviceStateTable>
+</scpd>`
+
+// certifyClient returns a client searching on the loopback interface, where
+// a fake device answers with the location.
+func certifyClient(t *testing.T, port int, location string) *ssdp.SSDP {
+	loopback := loopbackInterface(t)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		for {
+			_, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			response := "HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=1800\r\nEXT:\r\nLOCATION: " + location + "\r\n" +
+				"SERVER: Linux/5.10 UPnP/1.0 Light/1.0\r\nST: uuid:light\r\nUSN: uuid:light\r\n\r\n"
+			conn.WriteToUDP([]byte(response), addr)
+		}
+	}()
+
+	return ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(500),
+	)
+}
+
+func Test_Certify(t *testing.T) {
+	const port = 19438
+
+	scpd, err := soap.ParseSCPD(strings.NewReader(switchPowerSCPD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := soap.NewService("urn:schemas-upnp-org:service:SwitchPower:1", scpd)
+	err = soap.Handle(service, "GetStatus", func(ctx context.Context, in struct{}) (struct{ ResultStatus bool }, error) {
+		return struct{ ResultStatus bool }{true}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := gena.NewPublisher(map[string]string{"Status": "1"})
+	defer publisher.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/description.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, certifyDescription)
+	})
+	mux.HandleFunc("/switch.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, switchPowerSCPD)
+	})
+	mux.Handle("/switch/control", service)
+	mux.Handle("/switch/event", publisher)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ssdpClient := certifyClient(t, port, server.URL+"/description.xml")
+	report, err := certify.Run(context.Background(), ssdpClient, certify.Config{UDN: "uuid:light"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	report.WriteTo(&b)
+	if !report.Passed() {
+		t.Errorf("expected the device to pass:\n%s", b.String())
+	}
+	for _, line := range []string{
+		"PASS control: invalid action on urn:upnp-org:serviceId:SwitchPower",
+		"PASS control: GetStatus on urn:upnp-org:serviceId:SwitchPower",
+		"PASS eventing: initial event of urn:upnp-org:serviceId:SwitchPower",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("expected %q in the report:\n%s", line, b.String())
+		}
+	}
+}
+
+func Test_CertifyBrokenDescription(t *testing.T) {
+	const port = 19442
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<root><device><UDN>uuid:light")
+	}))
+	defer server.Close()
+
+	ssdpClient := certifyClient(t, port, server.URL+"/description.xml")
+	report, err := certify.Run(context.Background(), ssdpClient, certify.Config{UDN: "uuid:light"})
+	if err != nil {
+		t.Fatalf("expected a report on the broken description, got %v", err)
+	}
+
+	var b bytes.Buffer
+	report.WriteTo(&b)
+	if report.Passed() || !strings.Contains(b.String(), "FAIL description: fetch") {
+		t.Errorf("expected the fetch to fail in the report:\n%s", b.String())
+	}
+	if len(report.Results) < 2 || report.Results[0].Phase != certify.PhaseDiscovery {
+		t.Errorf("expected the discovery findings to be kept:\n%s", b.String())
+	}
+}
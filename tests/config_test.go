@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ConfigFromFile(t *testing.T) {
+	expected := config.Config{
+		Interface:      "eth0",
+		Port:           1900,
+		MulticastGroup: "239.255.255.250",
+		Timeout:        config.Duration(1500 * time.Millisecond),
+		SearchTargets:  []string{"upnp:rootdevice", "ssdp:all"},
+	}
+
+	files := map[string]string{
+		"ssdp.yaml": "interface: eth0\nport: 1900\nmulticast_group: 239.255.255.250\ntimeout: 1500ms\n" +
+			"search_targets:\n  - upnp:rootdevice\n  - ssdp:all\n",
+		"ssdp.toml": "interface = \"eth0\"\nport = 1900\nmulticast_group = \"239.255.255.250\"\ntimeout = \"1500ms\"\n" +
+			"search_targets = [\"upnp:rootdevice\", \"ssdp:all\"]\n",
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := config.FromFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: expected %+v, got %+v", name, expected, actual)
+		}
+
+		if len(actual.Options()) != 4 {
+			t.Errorf("%s: expected 4 options, got %d", name, len(actual.Options()))
+		}
+	}
+
+	if _, err := config.FromFile(filepath.Join(dir, "ssdp.ini")); err == nil {
+		t.Error("expected an error for an unsupported file")
+	}
+}
+
+func Test_ConfigFromEnv(t *testing.T) {
+	t.Setenv(config.EnvPort, "1900")
+	t.Setenv(config.EnvTimeout, "2s")
+	t.Setenv(config.EnvSearchTargets, "upnp:rootdevice, ssdp:all")
+
+	actual, err := config.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := config.Config{
+		Port:          1900,
+		Timeout:       config.Duration(2 * time.Second),
+		SearchTargets: []string{"upnp:rootdevice", "ssdp:all"},
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+
+	t.Setenv(config.EnvPort, "ssdp")
+	if _, err := config.FromEnv(); err == nil {
+		t.Error("expected an error for an invalid port")
+	}
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/fingerprint"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"testing"
+)
+
+func Test_FingerprintSurvivesReset(t *testing.T) {
+	before := ssdp.Device{
+		Manufacturer: "Signify",
+		ModelName:    "Philips hue bridge 2015",
+		ModelNumber:  "BSB002",
+		SerialNumber: "001788a1b2c3",
+		UDN:          "uuid:2f402f80-da50-11e1-9b23-001788a1b2c3",
+	}
+	after := before
+	after.UDN = "uuid:7a1c9e00-0b3d-11ee-8c11-001788a1b2c3"
+	after.SerialNumber = " 001788A1B2C3 "
+
+	a := fingerprint.FromDevice(before, "Hue/1.0 UPnP/1.0 IpBridge/1.50.0")
+	b := fingerprint.FromDevice(after, "Hue/1.0 UPnP/1.0 IpBridge/1.56.0")
+	if a.Hash() != b.Hash() {
+		t.Errorf("fingerprints differ: %+v and %+v", a, b)
+	}
+	if a.MAC != "00:17:88:a1:b2:c3" || a.Server != "hue upnp ipbridge" || !a.Unique() {
+		t.Errorf("unexpected fingerprint %+v", a)
+	}
+
+	other := before
+	other.SerialNumber = "001788d4e5f6"
+	if fingerprint.FromDevice(other, "").Hash() == fingerprint.FromDevice(before, "").Hash() {
+		t.Error("different serial numbers share a fingerprint")
+	}
+}
+
+func Test_MACFromUDN(t *testing.T) {
+	for udn, want := range map[string]string{
+		"uuid:2f402f80-da50-11e1-9b23-001788a1b2c3": "00:17:88:a1:b2:c3",
+		// Version 4, random
+		"uuid:4d696e69-444c-464e-9d41-b827eb54e939": "",
+		// Version 1 with a random node
+		"uuid:2f402f80-da50-11e1-9b23-011788a1b2c3": "",
+		"uuid:RINCON_000E58A1B2C301400":             "",
+	} {
+		mac, ok := fingerprint.MACFromUDN(udn)
+		if got := mac.String(); got != want || ok != (want != "") {
+			t.Errorf("%s: expected %q, got %q", udn, want, got)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/gena"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const propertySet = `<?xml version="1.0"?>
+<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">
+<e:property><Volume>42</Volume></e:property>
+<e:property><Mute>0</Mute></e:property>
+</e:propertyset>`
+
+// newEventedDevice serves a fake event subscription URL that sends one event
+// to each new subscriber and records the requests it receives.
+func newEventedDevice(t *testing.T, timeout string) (ssdp.Device, *[]string, *sync.Mutex, func()) {
+	var mu sync.Mutex
+	var requests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.Header.Get("SID"))
+		mu.Unlock()
+
+		switch {
+		case r.Method == "SUBSCRIBE" && r.Header.Get("SID") == "":
+			if r.Header.Get("NT") != "upnp:event" {
+				t.Errorf("unexpected NT %q", r.Header.Get("NT"))
+			}
+			callback := strings.Trim(r.Header.Get("CALLBACK"), "<>")
+			w.Header().Set("SID", "uuid:sub-1")
+			w.Header().Set("TIMEOUT", timeout)
+			w.WriteHeader(http.StatusOK)
+
+			go func() {
+				request, _ := http.NewRequest("NOTIFY", callback, strings.NewReader(propertySet))
+				request.Header.Set("NT", "upnp:event")
+				request.Header.Set("NTS", "upnp:propchange")
+				request.Header.Set("SID", "uuid:sub-1")
+				request.Header.Set("SEQ", "0")
+				if response, err := http.DefaultClient.Do(request); err == nil {
+					response.Body.Close()
+				}
+			}()
+		case r.Method == "SUBSCRIBE":
+			w.Header().Set("SID", r.Header.Get("SID"))
+			w.Header().Set("TIMEOUT", timeout)
+		case r.Method == "UNSUBSCRIBE":
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	device := ssdp.Device{
+		UDN: "uuid:renderer",
+		Services: []ssdp.Service{
+			{ServiceType: "urn:schemas-upnp-org:service:RenderingControl:1", ServiceID: "urn:upnp-org:serviceId:RenderingControl", EventSubURL: "/evt"},
+			{ServiceType: "urn:schemas-upnp-org:service:AVTransport:1", ServiceID: "urn:upnp-org:serviceId:AVTransport"},
+		},
+		Location: location,
+	}
+
+	return device, &requests, &mu, server.Close
+}
+
+func Test_SubscriberEvents(t *testing.T) {
+	device, requests, mu, closeDevice := newEventedDevice(t, "Second-2")
+	defer closeDevice()
+
+	subscriber, err := gena.NewSubscriber(gena.WithListenAddr("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := subscriber.SubscribeDevice(ctx, device, "urn:schemas-upnp-org:service:RenderingControl"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-subscriber.Events():
+		if event.UDN != "uuid:renderer" || event.ServiceID != "urn:upnp-org:serviceId:RenderingControl" || event.SID != "uuid:sub-1" {
+			t.Errorf("unexpected event %+v", event)
+		}
+		if event.Variables["Volume"] != "42" || event.Variables["Mute"] != "0" {
+			t.Errorf("unexpected variables %v", event.Variables)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	// The subscription is renewed after half of the granted second.
+	time.Sleep(1500 * time.Millisecond)
+
+	if err := subscriber.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	got := fmt.Sprint(*requests)
+	mu.Unlock()
+
+	if !strings.HasPrefix(got, "[SUBSCRIBE  SUBSCRIBE uuid:sub-1") || !strings.HasSuffix(got, "UNSUBSCRIBE uuid:sub-1]") {
+		t.Errorf("unexpected requests %s", got)
+	}
+
+	if _, ok := <-subscriber.Events(); ok {
+		t.Error("events channel not closed")
+	}
+}
+
+func Test_SubscriberEventBeforeResponse(t *testing.T) {
+	// Events sent before the SUBSCRIBE is answered wait for its SID, so a
+	// host guessing the callback URL cannot take over the subscription
+	statuses := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callback := strings.Trim(r.Header.Get("CALLBACK"), "<>")
+		notify := func(sid string) {
+			request, _ := http.NewRequest("NOTIFY", callback, strings.NewReader(propertySet))
+			request.Header.Set("NT", "upnp:event")
+			request.Header.Set("NTS", "upnp:propchange")
+			request.Header.Set("SID", sid)
+			request.Header.Set("SEQ", "0")
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				statuses <- err.Error()
+				return
+			}
+			response.Body.Close()
+			statuses <- sid + " " + response.Status
+		}
+		go notify("uuid:intruder")
+		go notify("uuid:sub-1")
+		time.Sleep(100 * time.Millisecond)
+
+		w.Header().Set("SID", "uuid:sub-1")
+		w.Header().Set("TIMEOUT", "Second-1800")
+	}))
+	defer server.Close()
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	device := ssdp.Device{
+		UDN:      "uuid:renderer",
+		Services: []ssdp.Service{{ServiceType: "urn:schemas-upnp-org:service:RenderingControl:1", ServiceID: "urn:upnp-org:serviceId:RenderingControl", EventSubURL: "/evt"}},
+		Location: location,
+	}
+
+	subscriber, err := gena.NewSubscriber(gena.WithListenAddr("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close(context.Background())
+
+	if err := subscriber.SubscribeDevice(context.Background(), device); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{<-statuses: true, <-statuses: true}
+	if !got["uuid:intruder 412 Precondition Failed"] || !got["uuid:sub-1 200 OK"] {
+		t.Errorf("unexpected statuses %v", got)
+	}
+	select {
+	case event := <-subscriber.Events():
+		if event.SID != "uuid:sub-1" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func Test_SubscriberCloseWithBlockedEvent(t *testing.T) {
+	device, _, _, closeDevice := newEventedDevice(t, "Second-1800")
+	defer closeDevice()
+
+	// Nobody reads the events, so delivering the initial one blocks
+	subscriber, err := gena.NewSubscriber(gena.WithListenAddr("127.0.0.1:0"), gena.WithEventBuffer(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subscriber.SubscribeDevice(context.Background(), device); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	subscriber.Close(ctx)
+	if _, ok := <-subscriber.Events(); ok {
+		t.Error("events channel not closed")
+	}
+}
+
+func Test_SubscriberNoEventedServices(t *testing.T) {
+	device, _, _, closeDevice := newEventedDevice(t, "Second-1800")
+	defer closeDevice()
+
+	subscriber, err := gena.NewSubscriber(gena.WithListenAddr("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close(context.Background())
+
+	if err := subscriber.SubscribeDevice(context.Background(), device, "urn:schemas-upnp-org:service:AVTransport"); err == nil {
+		t.Error("expected error for a service without event URL")
+	}
+}
+
+func Test_SubscriberStore(t *testing.T) {
+	device, requests, mu, closeDevice := newEventedDevice(t, "Second-1800")
+	defer closeDevice()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	store := gena.FileStore(filepath.Join(t.TempDir(), "subscriptions.json"))
+
+	subscriber, err := gena.NewSubscriber(gena.WithListenAddr(addr), gena.WithStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subscriber.SubscribeDevice(context.Background(), device); err != nil {
+		t.Fatal(err)
+	}
+	<-subscriber.Events()
+	if err := subscriber.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].SID != "uuid:sub-1" || records[0].UDN != "uuid:renderer" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	subscriber, err = gena.NewSubscriber(gena.WithListenAddr(addr), gena.WithStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close(context.Background())
+
+	// The stored subscription is renewed instead of subscribed to again.
+	for i := 0; i < 100; i++ {
+		mu.Lock()
+		got := fmt.Sprint(*requests)
+		mu.Unlock()
+		if got == "[SUBSCRIBE  SUBSCRIBE uuid:sub-1]" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	t.Errorf("unexpected requests %v", *requests)
+}
+
+func Test_SubscriberCallbackURL(t *testing.T) {
+	callbacks := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "SUBSCRIBE" {
+			callbacks <- r.Header.Get("CALLBACK")
+			w.Header().Set("SID", "uuid:sub-1")
+			w.Header().Set("TIMEOUT", "Second-1800")
+		}
+	}))
+	defer server.Close()
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	device := ssdp.Device{
+		UDN:      "uuid:renderer",
+		Services: []ssdp.Service{{ServiceID: "urn:upnp-org:serviceId:RenderingControl", EventSubURL: "/evt"}},
+		Location: location,
+	}
+
+	subscriber, err := gena.NewSubscriber(gena.WithListenAddr("127.0.0.1:0"), gena.WithCallbackHost("203.0.113.7"), gena.WithCallbackPort(8058))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close(context.Background())
+
+	if err := subscriber.SubscribeDevice(context.Background(), device); err != nil {
+		t.Fatal(err)
+	}
+
+	if callback := <-callbacks; callback != "<http://203.0.113.7:8058/events/1>" {
+		t.Errorf("unexpected callback %s", callback)
+	}
+}
+
+func Test_Publisher(t *testing.T) {
+	publisher := gena.NewPublisher(map[string]string{"Volume": "42", "Mute": "0"}, gena.WithModeration(20*time.Millisecond))
+	defer publisher.Close()
+	server := httptest.NewServer(publisher)
+	defer server.Close()
+
+	if err := publisher.Set("Balance", "0"); !errors.Is(err, gena.ErrUnknownVariable) {
+		t.Errorf("expected %v, got %v", gena.ErrUnknownVariable, err)
+	}
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	device := ssdp.Device{
+		UDN: "uuid:renderer",
+		Services: []ssdp.Service{
+			{ServiceType: "urn:schemas-upnp-org:service:RenderingControl:1", ServiceID: "urn:upnp-org:serviceId:RenderingControl", EventSubURL: "/evt"},
+		},
+		Location: location,
+	}
+
+	subscriber, err := gena.NewSubscriber(gena.WithListenAddr("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := subscriber.SubscribeDevice(ctx, device); err != nil {
+		t.Fatal(err)
+	}
+
+	next := func() gena.Event {
+		select {
+		case event := <-subscriber.Events():
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return gena.Event{}
+		}
+	}
+
+	initial := next()
+	if initial.Seq != 0 || !reflect.DeepEqual(initial.Variables, map[string]string{"Volume": "42", "Mute": "0"}) {
+		t.Errorf("unexpected initial event %+v", initial)
+	}
+
+	// Changes within the moderation interval are sent together
+	publisher.Set("Volume", "40")
+	publisher.Set("Volume", "<38>")
+	publisher.Set("Mute", "0")
+	changed := next()
+	if changed.Seq != 1 || changed.SID != initial.SID || !reflect.DeepEqual(changed.Variables, map[string]string{"Volume": "<38>"}) {
+		t.Errorf("unexpected event %+v", changed)
+	}
+
+	if err := subscriber.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if publisher.Subscribers() != 0 {
+		t.Errorf("expected the subscription to end, got %d subscribers", publisher.Subscribers())
+	}
+}
+
+func Test_PublisherCloseWithHangingCallback(t *testing.T) {
+	publisher := gena.NewPublisher(map[string]string{"Volume": "42"})
+	server := httptest.NewServer(publisher)
+	defer server.Close()
+
+	// A control point that never answers its events
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	request, _ := http.NewRequest("SUBSCRIBE", server.URL, nil)
+	request.Header.Set("CALLBACK", "<"+hanging.URL+"/events>")
+	request.Header.Set("NT", "upnp:event")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.Header.Get("SID") == "" {
+		t.Fatalf("unexpected response %v", response.Status)
+	}
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("no initial event sent")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		publisher.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected Close to cancel the event being sent")
+	}
+}
+
+func Test_SubscriberResubscribeOnReboot(t *testing.T) {
+	device, requests, mu, closeDevice := newEventedDevice(t, "Second-1800")
+	defer closeDevice()
+
+	subscriber, err := gena.NewSubscriber(gena.WithListenAddr("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close(context.Background())
+	if err := subscriber.SubscribeDevice(context.Background(), device); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := ssdp.NewRegistry(subscriber.ResubscribeOnReboot())
+	notify := ssdp.Notify{NT: "upnp:rootdevice", NTS: ssdp.NTSAlive, USN: "uuid:renderer::upnp:rootdevice", BootID: 1}
+	registry.AddNotify(notify)
+	notify.BootID = 2
+	registry.AddNotify(notify)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		got := fmt.Sprint(*requests)
+		mu.Unlock()
+		if got == "[SUBSCRIBE  SUBSCRIBE ]" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a new subscription after the reboot, got %s", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_SubscriberCloseDuringSubscribe(t *testing.T) {
+	// A SUBSCRIBE answered after Close is ended right away, as Close did not
+	// know its SID to unsubscribe
+	received := make(chan struct{})
+	release := make(chan struct{})
+	unsubscribed := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "SUBSCRIBE":
+			close(received)
+			<-release
+			w.Header().Set("SID", "uuid:sub-1")
+			w.Header().Set("TIMEOUT", "Second-1800")
+		case "UNSUBSCRIBE":
+			unsubscribed <- r.Header.Get("SID")
+		}
+	}))
+	defer server.Close()
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	device := ssdp.Device{
+		UDN:      "uuid:renderer",
+		Services: []ssdp.Service{{ServiceType: "urn:schemas-upnp-org:service:RenderingControl:1", ServiceID: "urn:upnp-org:serviceId:RenderingControl", EventSubURL: "/evt"}},
+		Location: location,
+	}
+
+	subscriber, err := gena.NewSubscriber(gena.WithListenAddr("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscribed := make(chan error, 1)
+	go func() {
+		subscribed <- subscriber.SubscribeDevice(context.Background(), device)
+	}()
+
+	<-received
+	subscriber.Close(context.Background())
+	close(release)
+
+	if err := <-subscribed; !errors.Is(err, gena.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	select {
+	case sid := <-unsubscribed:
+		if sid != "uuid:sub-1" {
+			t.Errorf("unexpected SID %q", sid)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the subscription to be ended")
+	}
+}
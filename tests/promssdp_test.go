@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/promssdp"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+	"time"
+)
+
+func gatherValues(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch {
+			case metric.Gauge != nil:
+				values[family.GetName()] += metric.Gauge.GetValue()
+			case metric.Counter != nil:
+				values[family.GetName()] += metric.Counter.GetValue()
+			case metric.Histogram != nil:
+				values[family.GetName()] += float64(metric.Histogram.GetSampleCount())
+			}
+		}
+	}
+	return values
+}
+
+func Test_PromCollector(t *testing.T) {
+	registry := ssdp.NewRegistry()
+	registry.AddResponse(registryResponse("uuid:renderer::upnp:rootdevice", "upnp:rootdevice", "192.168.1.20"))
+	registry.AddResponse(registryResponse("uuid:server::upnp:rootdevice", "upnp:rootdevice", "192.168.1.30"))
+
+	collector := promssdp.NewCollector(registry)
+	prom := prometheus.NewRegistry()
+	prom.MustRegister(collector)
+
+	receive := collector.SearchMiddleware().Receive(func(*ssdp.SearchResponse) {})
+	receive(&ssdp.SearchResponse{RTT: 40 * time.Millisecond})
+
+	values := gatherValues(t, prom)
+	if values["ssdp_registry_devices"] != 2 || values["ssdp_registry_devices_appeared_total"] != 2 || values["ssdp_search_response_seconds"] != 1 {
+		t.Errorf("unexpected metrics %v", values)
+	}
+
+	registry.Remove("uuid:renderer")
+	values = gatherValues(t, prom)
+	if values["ssdp_registry_devices"] != 1 || values["ssdp_registry_devices_disappeared_total"] != 1 {
+		t.Errorf("expected the removal to be counted, got %v", values)
+	}
+	if _, ok := values["ssdp_device_last_seen_age_seconds"]; !ok {
+		t.Errorf("expected the last seen age of the remaining device, got %v", values)
+	}
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"github.com/Oleaintueri/gossdp/pkg/scaffold"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_ScaffoldGenerate(t *testing.T) {
+	scpd, err := soap.ParseSCPD(strings.NewReader(renderingControlSCPD))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := scaffold.Generate(scaffold.Config{
+		Package:     "renderer",
+		ServiceType: "urn:schemas-upnp-org:service:RenderingControl:1",
+	}, scpd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "scaffold.go", source, 0)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, source)
+	}
+
+	declared := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.TypeSpec:
+			declared[node.Name.Name] = true
+		case *ast.FuncDecl:
+			declared[node.Name.Name] = true
+		}
+		return true
+	})
+	for _, name := range []string{
+		"RenderingControlGetVolumeIn", "RenderingControlGetVolumeOut", "RenderingControl",
+		"UnimplementedRenderingControl", "NewRenderingControlService", "RenderingControlClient", "GetVolume",
+	} {
+		if !declared[name] {
+			t.Errorf("expected %s to be declared in\n%s", name, source)
+		}
+	}
+
+	if !strings.Contains(string(source), "CurrentVolume uint16") || !strings.Contains(string(source), "InstanceID uint32") {
+		t.Errorf("expected the argument types of the state variables in\n%s", source)
+	}
+}
+
+func Test_ScaffoldGenerateClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(renderingControlSCPD))
+	}))
+	defer server.Close()
+
+	scpdURL, _ := url.Parse(server.URL + "/RenderingControl.xml")
+	scpd, err := soap.FetchSCPD(context.Background(), nil, scpdURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := scaffold.Generate(scaffold.Config{
+		Package:     "renderer",
+		ServiceType: "urn:schemas-upnp-org:service:RenderingControl:1",
+		ClientOnly:  true,
+	}, scpd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "scaffold.go", source, 0); err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, source)
+	}
+
+	code := string(source)
+	if !strings.Contains(code, "func (c *RenderingControlClient) GetVolume(") {
+		t.Errorf("expected the client method in\n%s", code)
+	}
+	if strings.Contains(code, "UnimplementedRenderingControl") || strings.Contains(code, "NewRenderingControlService") {
+		t.Errorf("expected no device host code in\n%s", code)
+	}
+}
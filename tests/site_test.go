@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"context"
+	"github.com/Oleaintueri/gossdp/pkg/config"
+	"github.com/Oleaintueri/gossdp/pkg/site"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"github.com/prometheus/client_golang/prometheus"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// respondWithMaxAge answers like respondOn, with a max-age keeping the
+// responder in a registry.
+func respondWithMaxAge(t *testing.T, ip string, port int) func() {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP(ip), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", ip, err)
+	}
+
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		for {
+			_, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=1800\r\nST: upnp:rootdevice\r\nUSN: "+ip+"\r\n\r\n"), addr)
+		}
+	}()
+
+	return func() { conn.Close() }
+}
+
+func Test_SiteGroup(t *testing.T) {
+	loopback := loopbackInterface(t)
+	defer respondWithMaxAge(t, "127.0.0.2", 19428)()
+	defer respondWithMaxAge(t, "127.0.0.3", 19429)()
+
+	path := filepath.Join(t.TempDir(), "sites.yaml")
+	content := "sites:\n" +
+		"  north:\n    interface: " + loopback.Name + "\n    port: 19428\n    multicast_group: 127.0.0.2\n    timeout: 300ms\n" +
+		"  south:\n    interface: " + loopback.Name + "\n    port: 19429\n    multicast_group: 127.0.0.3\n    timeout: 300ms\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sites, err := config.SitesFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := site.NewGroup(sites, ssdp.WithIncludeSelf(true))
+	if len(group.Sites) != 2 || group.Sites[0].Name != "north" {
+		t.Fatalf("unexpected sites %+v", group.Sites)
+	}
+
+	for i, ip := range []string{"127.0.0.2", "127.0.0.3"} {
+		if _, err := group.Sites[i].Round(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		entries := group.Sites[i].Registry.Snapshot()
+		if len(entries) != 1 || entries[0].UDN != ip {
+			t.Errorf("%s: expected only %s, got %+v", group.Sites[i].Name, ip, entries)
+		}
+	}
+
+	prom := prometheus.NewRegistry()
+	if err := group.Register(prom); err != nil {
+		t.Fatal(err)
+	}
+	families, err := prom.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "ssdp_registry_devices" {
+			continue
+		}
+		if len(family.GetMetric()) != 2 {
+			t.Errorf("expected a series for each site, got %v", family.GetMetric())
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/Oleaintueri/gossdp/pkg/soap"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const renderingControlSCPD = `<?xml version="1.0"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+<actionList>
+<action><name>GetVolume</name><argumentList>
+<argument><name>InstanceID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_InstanceID</relatedStateVariable></argument>
+<argument><name>Channel</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Channel</relatedStateVariable></argument>
+<argument><name>CurrentVolume</name><direction>out</direction><relatedStateVariable>Volume</relatedStateVariable></argument>
+<argument><name>CurrentMute</name><direction>out</direction><relatedStateVariable>Mute</relatedStateVariable></argument>
+</argumentList></action>
+</actionList>
+<serviceStateTable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_InstanceID</name><dataType>ui4</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_Channel</name><dataType>string</dataType>
+<allowedValueList><allowedValue>Master</allowedValue></allowedValueList></stateVariable>
+<stateVariable sendEvents="no"><name>Volume</name><dataType>ui2</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>Mute</name><dataType>boolean</dataType></stateVariable>
+</serviceStateTable>
+</scpd>`
+
+type getVolumeIn struct {
+	InstanceID uint32
+	Channel    string
+}
+
+type getVolumeOut struct {
+	Mute   bool   `soap:"CurrentMute"`
+	Volume uint16 `soap:"CurrentVolume"`
+}
+
+func Test_SoapService(t *testing.T) {
+	scpd, err := soap.ParseSCPD(strings.NewReader(renderingControlSCPD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const serviceType = "urn:schemas-upnp-org:service:RenderingControl:1"
+	service := soap.NewService(serviceType, scpd)
+
+	err = soap.Handle(service, "GetVolume", func(ctx context.Context, in getVolumeIn) (getVolumeOut, error) {
+		if in.InstanceID != 0 {
+			return getVolumeOut{}, &soap.UPnPError{Code: 702, Description: "Invalid InstanceID"}
+		}
+		return getVolumeOut{Volume: 42, Mute: true}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := soap.Handle(service, "GetVolume", func(ctx context.Context, in struct{ InstanceID uint32 }) (getVolumeOut, error) {
+		return getVolumeOut{}, nil
+	}); err == nil {
+		t.Error("expected binding fields missing an argument to fail")
+	}
+	if err := soap.Handle(service, "SetVolume", func(ctx context.Context, in getVolumeIn) (struct{}, error) {
+		return struct{}{}, nil
+	}); err == nil {
+		t.Error("expected an action missing from the SCPD to fail")
+	}
+
+	server := httptest.NewServer(service)
+	defer server.Close()
+	controlURL, _ := url.Parse(server.URL)
+	client := soap.NewClient(controlURL, serviceType, nil)
+	ctx := context.Background()
+
+	out, err := client.Invoke(ctx, "GetVolume", []soap.Arg{{Name: "InstanceID", Value: "0"}, {Name: "Channel", Value: "Master"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string]string{"CurrentVolume": "42", "CurrentMute": "1"}) {
+		t.Errorf("unexpected output %v", out)
+	}
+
+	typed, err := soap.Call[getVolumeIn, getVolumeOut](ctx, client, "GetVolume", getVolumeIn{Channel: "Master"})
+	if err != nil || typed != (getVolumeOut{Volume: 42, Mute: true}) {
+		t.Errorf("unexpected typed output %+v, %v", typed, err)
+	}
+
+	for _, c := range []struct {
+		action string
+		args   []soap.Arg
+		code   int
+	}{
+		{"GetVolume", []soap.Arg{{Name: "InstanceID", Value: "1"}, {Name: "Channel", Value: "Master"}}, 702},
+		{"GetVolume", []soap.Arg{{Name: "InstanceID", Value: "0"}, {Name: "Channel", Value: "LF"}}, soap.ErrorArgumentValueInvalid},
+		{"GetVolume", []soap.Arg{{Name: "InstanceID", Value: "zero"}, {Name: "Channel", Value: "Master"}}, soap.ErrorArgumentValueInvalid},
+		{"GetVolume", []soap.Arg{{Name: "InstanceID", Value: "0"}}, soap.ErrorInvalidArgs},
+		{"SetVolume", nil, soap.ErrorInvalidAction},
+	} {
+		_, err := client.Invoke(ctx, c.action, c.args)
+		var upnpErr *soap.UPnPError
+		if !errors.As(err, &upnpErr) || upnpErr.Code != c.code {
+			t.Errorf("%s %v: expected error %d, got %v", c.action, c.args, c.code, err)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_Advertiser(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19007), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+
+	monitor, err := ssdpClient.Monitor(ssdp.WithBufferedDelivery(32))
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer monitor.Close()
+
+	description, err := os.ReadFile("../example/responses/hue_description.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	advertiser, err := ssdpClient.NewAdvertiser(ssdp.HostedDevice{
+		UDN:         "uuid:01234567-89ab-cdef-0123-456789abcdef",
+		DeviceType:  "urn:schemas-upnp-org:device:Basic:1",
+		Description: description,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var alive ssdp.Notify
+	select {
+	case alive = <-monitor.Notifications():
+	case <-time.After(time.Second):
+		advertiser.Close()
+		t.Skip("multicast loopback not available")
+	}
+	if alive.NTS != ssdp.NTSAlive || alive.Location == nil || alive.Location.Port() != strconv.Itoa(advertiser.Port()) {
+		t.Fatalf("unexpected alive %+v", alive)
+	}
+
+	device, err := ssdpClient.FetchDescription(alive.Location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.UDN != "uuid:01234567-89ab-cdef-0123-456789abcdef" {
+		t.Errorf("unexpected device %+v", device)
+	}
+
+	if err := advertiser.Close(); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case notify := <-monitor.Notifications():
+			if notify.NTS == ssdp.NTSByeBye {
+				return
+			}
+		case <-timeout:
+			t.Fatal("expected a byebye after closing")
+		}
+	}
+}
+
+func Test_AdvertiserDevices(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19008), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+
+	monitor, err := ssdpClient.Monitor(ssdp.WithBufferedDelivery(64))
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer monitor.Close()
+
+	bulb := func(udn string) ssdp.HostedDevice {
+		return ssdp.HostedDevice{
+			UDN:         udn,
+			DeviceType:  "urn:schemas-upnp-org:device:DimmableLight:1",
+			Description: []byte("<root><device><UDN>" + udn + "</UDN></device></root>"),
+		}
+	}
+
+	advertiser, err := ssdpClient.NewAdvertiser(bulb("uuid:bulb-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer advertiser.Close()
+
+	// waitFor returns the first notification of the device with the NTS
+	waitFor := func(udn string, nts string) ssdp.Notify {
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case notify := <-monitor.Notifications():
+				if notify.USN == udn && notify.NTS == nts {
+					return notify
+				}
+			case <-timeout:
+				t.Skipf("no %s of %s, multicast loopback not available", nts, udn)
+			}
+		}
+	}
+	waitFor("uuid:bulb-1", ssdp.NTSAlive)
+
+	if err := advertiser.Add(bulb("uuid:bulb-2")); err != nil {
+		t.Fatal(err)
+	}
+	alive := waitFor("uuid:bulb-2", ssdp.NTSAlive)
+	device, err := ssdpClient.FetchDescription(alive.Location)
+	if err != nil || device.UDN != "uuid:bulb-2" {
+		t.Fatalf("expected the description of the added device, got %v, %v", device, err)
+	}
+	if devices := advertiser.Devices(); len(devices) != 2 {
+		t.Errorf("expected two devices, got %v", devices)
+	}
+
+	if !advertiser.Remove("uuid:bulb-2") {
+		t.Fatal("expected the device to be removed")
+	}
+	waitFor("uuid:bulb-2", ssdp.NTSByeBye)
+	if _, err := ssdpClient.FetchDescription(alive.Location); err == nil {
+		t.Error("expected the description of the removed device to be gone")
+	}
+	if advertiser.Remove("uuid:bulb-2") {
+		t.Error("expected removing twice to report false")
+	}
+}
+
+func Test_AdvertiserSchedule(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19010), ssdp.WithBroadcast(monitorGroup))
+
+	advertiser, err := ssdpClient.NewAdvertiser(ssdp.HostedDevice{
+		UDN:         "uuid:sensor",
+		DeviceType:  "urn:schemas-upnp-org:device:SensorManagement:1",
+		Description: []byte("<root><device><UDN>uuid:sensor</UDN></device></root>"),
+		MaxAge:      10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer advertiser.Close()
+
+	var schedule []ssdp.ScheduledAnnouncement
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if schedule = advertiser.Schedule(); len(schedule) == 3 && !schedule[2].Last.IsZero() {
+			break
+		}
+	}
+	if len(schedule) != 3 || schedule[2].Last.IsZero() {
+		t.Skipf("announcements not sent: %+v", schedule)
+	}
+
+	usns := make(map[string]bool)
+	for _, announcement := range schedule {
+		usns[announcement.USN] = true
+		if announcement.MaxAge != 10*time.Minute {
+			t.Errorf("unexpected max-age %v", announcement.MaxAge)
+		}
+		// Renewed at half the max-age
+		if gap := announcement.Next.Sub(announcement.Last); gap < 5*time.Minute-time.Second || gap > 5*time.Minute+time.Second {
+			t.Errorf("expected the next announcement 5 minutes after the last, got %v", gap)
+		}
+	}
+	for _, usn := range []string{"uuid:sensor", "uuid:sensor::upnp:rootdevice", "uuid:sensor::urn:schemas-upnp-org:device:SensorManagement:1"} {
+		if !usns[usn] {
+			t.Errorf("expected %s in the schedule %+v", usn, schedule)
+		}
+	}
+}
+
+func Test_AdvertiserLowPower(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(19011),
+		ssdp.WithBroadcast(monitorGroup),
+		ssdp.WithUDAVersion(ssdp.UDA11),
+		ssdp.WithPowerProfile(ssdp.LowPower(time.Minute)),
+	)
+
+	advertiser, err := ssdpClient.NewAdvertiser(ssdp.HostedDevice{
+		UDN:         "uuid:thermostat",
+		DeviceType:  "urn:schemas-upnp-org:device:HVAC_System:1",
+		Description: []byte("<root><device><UDN>uuid:thermostat</UDN></device></root>"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer advertiser.Close()
+
+	if port := advertiser.SearchPort(); port < 49152 {
+		t.Fatalf("unexpected search port %d", port)
+	}
+
+	response, err := ssdpClient.Probe(context.Background(), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: advertiser.SearchPort()}, ssdp.RootDevice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.USN != "uuid:thermostat::upnp:rootdevice" || response.MaxAge() != 2*time.Hour || response.SearchPort != advertiser.SearchPort() {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if response.Location == nil || response.Location.Hostname() != "127.0.0.1" {
+		t.Errorf("expected the location on the address searched from, got %v", response.Location)
+	}
+
+	for _, announcement := range advertiser.Schedule() {
+		if !announcement.Next.Equal(announcement.Next.Truncate(time.Minute)) || time.Until(announcement.Next) > time.Hour {
+			t.Errorf("expected the next announcement in a wake window within an hour, got %v", announcement.Next)
+		}
+	}
+}
+
+func Test_AdvertiserAnswersSearches(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19014), ssdp.WithBroadcast(monitorGroup))
+
+	advertiser, err := ssdpClient.NewAdvertiser(ssdp.HostedDevice{
+		UDN:         "uuid:speaker",
+		DeviceType:  "urn:schemas-upnp-org:device:MediaRenderer:1",
+		Description: []byte("<root><device><UDN>uuid:speaker</UDN></device></root>"),
+	})
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer advertiser.Close()
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	search := "M-SEARCH * HTTP/1.1\r\nHOST: " + monitorGroup + ":19014\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: urn:schemas-upnp-org:device:MediaRenderer:1\r\n\r\n"
+	sent := time.Now()
+	if _, err := conn.WriteToUDP([]byte(search), &net.UDPAddr{IP: net.ParseIP(monitorGroup), Port: 19014}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, ssdp.MaxMessageSize)
+	n, addr, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Skipf("multicast loopback not available: %v", err)
+	}
+	if time.Since(sent) > time.Second+100*time.Millisecond {
+		t.Errorf("expected the response within the MX, got it after %v", time.Since(sent))
+	}
+	response, err := ssdp.ParseSearchResponse(bytes.NewReader(buf[:n]), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.USN != "uuid:speaker::urn:schemas-upnp-org:device:MediaRenderer:1" || response.Location == nil || response.Location.Port() != strconv.Itoa(advertiser.Port()) {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if skew := response.ClockSkew(); response.Date.IsZero() || skew < -time.Second || skew > time.Second {
+		t.Errorf("expected the response dated now, got %v", response.Date)
+	}
+}
+
+func Test_AdvertiserShutdown(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19015), ssdp.WithBroadcast(monitorGroup))
+
+	advertiser, err := ssdpClient.NewAdvertiser(ssdp.HostedDevice{
+		UDN:         "uuid:speaker",
+		DeviceType:  "urn:schemas-upnp-org:device:MediaRenderer:1",
+		Description: []byte("<root><device><UDN>uuid:speaker</UDN></device></root>"),
+	})
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	release := make(chan struct{})
+	advertiser.Handle("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer close(release)
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// A response delayed by up to 5 seconds is sent right away on shutdown
+	search := "M-SEARCH * HTTP/1.1\r\nHOST: " + monitorGroup + ":19015\r\nMAN: \"ssdp:discover\"\r\nMX: 5\r\nST: ssdp:all\r\n\r\n"
+	if _, err := conn.WriteToUDP([]byte(search), &net.UDPAddr{IP: net.ParseIP(monitorGroup), Port: 19015}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	go http.Get("http://127.0.0.1:" + strconv.Itoa(advertiser.Port()) + "/slow")
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := advertiser.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected the slow request to outlast the context, got %v", err)
+	}
+	if err := advertiser.Close(); err != nil {
+		t.Errorf("expected closing after shutdown to do nothing, got %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	buf := make([]byte, ssdp.MaxMessageSize)
+	if _, _, err := conn.ReadFromUDP(buf); err != nil {
+		t.Skipf("multicast loopback not available: %v", err)
+	}
+}
+
+func Test_AdvertiserConn(t *testing.T) {
+	// A socket bound beforehand, as systemd binds those it passes on
+	groupConn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: 19016})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19016), ssdp.WithBroadcast(monitorGroup), ssdp.WithAdvertiserConn(groupConn))
+	advertiser, err := ssdpClient.NewAdvertiser(ssdp.HostedDevice{
+		UDN:         "uuid:speaker",
+		DeviceType:  "urn:schemas-upnp-org:device:MediaRenderer:1",
+		Description: []byte("<root><device><UDN>uuid:speaker</UDN></device></root>"),
+	})
+	if err != nil {
+		groupConn.Close()
+		t.Skipf("multicast not available: %v", err)
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	search := "M-SEARCH * HTTP/1.1\r\nHOST: " + monitorGroup + ":19016\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: uuid:speaker\r\n\r\n"
+	if _, err := conn.WriteToUDP([]byte(search), &net.UDPAddr{IP: net.ParseIP(monitorGroup), Port: 19016}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, ssdp.MaxMessageSize)
+	if _, _, err := conn.ReadFromUDP(buf); err != nil {
+		advertiser.Close()
+		t.Skipf("multicast loopback not available: %v", err)
+	}
+
+	advertiser.Close()
+	if _, err := groupConn.WriteToUDP([]byte("x"), conn.LocalAddr().(*net.UDPAddr)); err == nil {
+		t.Error("expected the advertiser to close the socket")
+	}
+}
+
+// frozenClock stands still and never fires its timers.
+type frozenClock struct {
+	now time.Time
+}
+
+func (c frozenClock) Now() time.Time {
+	return c.now
+}
+
+func (c frozenClock) After(d time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func Test_AdvertiserLongWakeInterval(t *testing.T) {
+	// Windows every 3 hours are too far apart for a max-age of 2 hours: the
+	// one after the renewal is due at 10:01 comes at 12:00, after the
+	// announcements of 09:01 expire
+	now := time.Date(2020, 1, 1, 9, 1, 0, 0, time.UTC)
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(19017),
+		ssdp.WithBroadcast(monitorGroup),
+		ssdp.WithClock(frozenClock{now}),
+		ssdp.WithPowerProfile(ssdp.LowPower(3*time.Hour)),
+	)
+
+	advertiser, err := ssdpClient.NewAdvertiser(ssdp.HostedDevice{
+		UDN:         "uuid:thermostat",
+		DeviceType:  "urn:schemas-upnp-org:device:HVAC_System:1",
+		Description: []byte("<root><device><UDN>uuid:thermostat</UDN></device></root>"),
+	})
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer advertiser.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for len(advertiser.Schedule()) == 0 || advertiser.Schedule()[0].Next.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("no renewal scheduled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, announcement := range advertiser.Schedule() {
+		if !announcement.Next.Equal(now.Add(time.Hour)) {
+			t.Errorf("expected the renewal when due at 10:01, got %v", announcement.Next)
+		}
+	}
+}
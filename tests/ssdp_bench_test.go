@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"bytes"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// corpusFiles returns the named file of each corpus capture that has one,
+// keyed by device.
+func corpusFiles(b *testing.B, name string) map[string][]byte {
+	paths, err := filepath.Glob(filepath.Join("testdata", "corpus", "*", name))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	files := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		files[filepath.Base(filepath.Dir(path))] = data
+	}
+	return files
+}
+
+func Benchmark_BuildSearchResponse(b *testing.B) {
+	config := ssdp.SearchResponseConfig{
+		ST:       "urn:schemas-upnp-org:device:MediaServer:1",
+		USN:      "uuid:4d696e69-444c-164e-9d41-b827eb54e939::urn:schemas-upnp-org:device:MediaServer:1",
+		Location: "http://192.168.1.30:8200/rootDesc.xml",
+		Server:   "Linux/5.10 UPnP/1.1 MiniDLNA/1.3",
+		Date:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		BootID:   7,
+		Version:  ssdp.UDA11,
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ssdp.BuildSearchResponse(config)
+	}
+}
+
+func Benchmark_ParseSearchResponse(b *testing.B) {
+	addr := &net.UDPAddr{IP: net.ParseIP("192.168.1.30"), Port: 1900}
+
+	for device, response := range corpusFiles(b, "response.txt") {
+		response := response
+		b.Run(device, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(response)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ssdp.ParseSearchResponse(bytes.NewReader(response), addr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func Benchmark_ParseNotify(b *testing.B) {
+	notify := []byte(notifySeed)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(notify)))
+	for i := 0; i < b.N; i++ {
+		if _, err := ssdp.ParseNotify(bytes.NewReader(notify), fuzzAddr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_ScanNotify(b *testing.B) {
+	notify := []byte(notifySeed)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(notify)))
+	for i := 0; i < b.N; i++ {
+		if _, err := ssdp.ScanNotify(notify, fuzzAddr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_ValidateSearchResponse(b *testing.B) {
+	for device, response := range corpusFiles(b, "response.txt") {
+		response := response
+		b.Run(device, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(response)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ssdp.ValidateSearchResponse(bytes.NewReader(response), ssdp.UDA11); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func Benchmark_ParseDescription(b *testing.B) {
+	for device, description := range corpusFiles(b, "description.xml") {
+		description := description
+		b.Run(device, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(description)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ssdp.ParseDescription(bytes.NewReader(description)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_DescriptionCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+	}))
+	defer server.Close()
+
+	info, err := os.Stat("../example/responses/hue_description.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Room for a single description
+	dir := t.TempDir()
+	cache, err := ssdp.NewDescriptionCache(dir, info.Size()+info.Size()/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithDescriptionCache(cache))
+	first, _ := url.Parse(server.URL + "/first.xml")
+	second, _ := url.Parse(server.URL + "/second.xml")
+
+	for i := 0; i < 2; i++ {
+		if _, err := ssdpClient.FetchDescription(first); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if atomic.LoadInt32(&requests) != 1 || cache.Len() != 1 || cache.Size() != info.Size() {
+		t.Errorf("expected the second fetch from the cache, got %d requests and %d cached", requests, cache.Len())
+	}
+
+	if _, err := ssdpClient.FetchDescription(second); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("expected the first description to be evicted, got %d cached", cache.Len())
+	}
+
+	reopened, err := ssdp.NewDescriptionCache(dir, info.Size()*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssdpClient = ssdp.NewSSDP(ssdp.WithDescriptionCache(reopened))
+	if _, err := ssdpClient.FetchDescription(second); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("expected the reopened cache to serve the second description, got %d requests", requests)
+	}
+
+	reopened.Remove(*second)
+	if reopened.Len() != 0 {
+		t.Errorf("expected the description to be removed, got %d cached", reopened.Len())
+	}
+}
+
+func Test_DescriptionCacheSkipsBrokenDescriptions(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Write([]byte("<root><device><friendlyName>truncated"))
+			return
+		}
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+	}))
+	defer server.Close()
+
+	cache, err := ssdp.NewDescriptionCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssdpClient := ssdp.NewSSDP(ssdp.WithDescriptionCache(cache))
+	location, _ := url.Parse(server.URL + "/description.xml")
+
+	if _, err := ssdpClient.FetchDescription(location); err == nil {
+		t.Fatal("expected the broken description to fail")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected the broken description not to be cached, got %d cached", cache.Len())
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := ssdpClient.FetchDescription(location); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if atomic.LoadInt32(&requests) != 2 || cache.Len() != 1 {
+		t.Errorf("expected the valid description to be fetched once and cached, got %d requests and %d cached", requests, cache.Len())
+	}
+}
+
+func Test_RegistryNLSChangeFlushesCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+	}))
+	defer server.Close()
+
+	cache, err := ssdp.NewDescriptionCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, _ := url.Parse(server.URL + "/description.xml")
+	if _, err := ssdp.NewSSDP(ssdp.WithDescriptionCache(cache)).FetchDescription(location); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := ssdp.NewRegistry(ssdp.WithRegistryCache(cache))
+	response := ssdp.SearchResponse{ST: "upnp:rootdevice", USN: "uuid:bridge::upnp:rootdevice", Control: "max-age=1800", Location: location, NLS: "first-network"}
+	registry.AddResponse(response)
+	if cache.Len() != 1 {
+		t.Fatalf("expected the description to stay cached, got %d cached", cache.Len())
+	}
+
+	response.NLS = "second-network"
+	registry.AddResponse(response)
+	if cache.Len() != 0 {
+		t.Errorf("expected the cached description to be removed after the NLS changed, got %d cached", cache.Len())
+	}
+}
+
+func Test_DescriptionCacheConfigID(t *testing.T) {
+	const port = 19439
+
+	var requests, configID int32 = 0, 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+	}))
+	defer server.Close()
+
+	loopback := loopbackInterface(t)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		for {
+			_, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			response := fmt.Sprintf("HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=1800\r\nLOCATION: %s/description.xml\r\n"+
+				"ST: upnp:rootdevice\r\nUSN: uuid:hue::upnp:rootdevice\r\nCONFIGID.UPNP.ORG: %d\r\n\r\n", server.URL, atomic.LoadInt32(&configID))
+			conn.WriteToUDP([]byte(response), addr)
+		}
+	}()
+
+	cache, err := ssdp.NewDescriptionCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(300),
+		ssdp.WithDescriptionCache(cache),
+	)
+
+	for _, expected := range []int32{1, 1, 2} {
+		if expected == 2 {
+			atomic.StoreInt32(&configID, 2)
+		}
+		if _, err := ssdpClient.SearchDevices(ssdp.RootDevice); err != nil {
+			t.Fatal(err)
+		}
+		if n := atomic.LoadInt32(&requests); n != expected {
+			t.Errorf("expected %d fetches, got %d", expected, n)
+		}
+	}
+}
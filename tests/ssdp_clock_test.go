@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func Test_SearchUsesClock(t *testing.T) {
+	// The fake clock fires timers immediately, so a minute long search window
+	// closes without sleeping.
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	var elapsed time.Duration
+	ssdpClient := ssdp.NewSSDP(ssdp.WithTimeout(60000), ssdp.WithClock(clock),
+		ssdp.WithProgress(func(progress ssdp.Progress) {
+			elapsed = progress.Elapsed
+		}))
+
+	start := time.Now()
+	_, err := ssdpClient.Search(ssdp.ALL.String())
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("expected search to use the injected clock, took %v", time.Since(start))
+	}
+
+	if elapsed < time.Minute {
+		t.Errorf("expected elapsed time from the fake clock, got %v", elapsed)
+	}
+}
+
+func Test_ProbeUsesClock(t *testing.T) {
+	const port = 19443
+
+	// Listening, so the probe is not rejected with port unreachable
+	silent, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.4"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer silent.Close()
+
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	ssdpClient := ssdp.NewSSDP(ssdp.WithTimeout(60000), ssdp.WithClock(clock))
+
+	start := time.Now()
+	_, err = ssdpClient.Probe(context.Background(), &net.UDPAddr{IP: net.ParseIP("127.0.0.4"), Port: port}, "uuid:alive")
+	if !errors.Is(err, ssdp.ErrNoResponse) {
+		t.Errorf("expected ErrNoResponse, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("expected the probe to time out on the injected clock, took %v", time.Since(start))
+	}
+}
+
+func Test_SearchResponseExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	response := ssdp.SearchResponse{
+		Control:  "no-cache, max-age = 100",
+		Received: clock.Now(),
+	}
+
+	if response.MaxAge() != 100*time.Second {
+		t.Errorf("expected max-age of 100s, got %v", response.MaxAge())
+	}
+
+	<-clock.After(99 * time.Second)
+	if response.Expired(clock.Now()) {
+		t.Error("expected response to still be valid")
+	}
+
+	<-clock.After(time.Second)
+	if !response.Expired(clock.Now()) {
+		t.Error("expected response to be expired")
+	}
+}
+
+func Test_ClockSkew(t *testing.T) {
+	message := "HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\nDATE: Wed, 01 Jan 2020 00:00:30 GMT\r\n\r\n"
+	response, err := ssdp.ParseSearchResponse(strings.NewReader(message), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.ClockSkew() != 0 {
+		t.Errorf("expected no skew before the response is received, got %v", response.ClockSkew())
+	}
+
+	response.Received = time.Date(2020, 1, 1, 0, 0, 0, 250, time.UTC)
+	if response.ClockSkew() != 30*time.Second {
+		t.Errorf("expected a skew of 30s, got %v", response.ClockSkew())
+	}
+}
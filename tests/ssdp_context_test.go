@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_FetchDescriptionContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang like a device with a stuck web server
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	ssdpClient := ssdp.NewSSDP(ssdp.WithFetchRetry(ssdp.RetryPolicy{Attempts: 5, Backoff: time.Second}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := ssdpClient.FetchDescriptionContext(ctx, location)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fetch took %v despite the deadline", elapsed)
+	}
+}
+
+func Test_SearchContextCanceled(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithTimeout(5000))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	_, err := ssdpClient.SearchDevicesContext(ctx, ssdp.ALL.String())
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the search to be canceled, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("search took %v despite the canceled context", elapsed)
+	}
+}
@@ -0,0 +1,1034 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loopbackInterface returns the loopback interface, whose 127/8 addresses let
+// fake devices listen on the search port next to the client.
+func loopbackInterface(t *testing.T) net.Interface {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface
+		}
+	}
+	t.Skip("no loopback interface")
+	return net.Interface{}
+}
+
+// respondOn answers every search sent to the address with a response whose
+// USN is the address.
+func respondOn(t *testing.T, ip string, port int) func() {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP(ip), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", ip, err)
+	}
+
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		for {
+			_, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\nUSN: "+ip+"\r\n\r\n"), addr)
+		}
+	}()
+
+	return func() { conn.Close() }
+}
+
+func Test_SearchReceivedOn(t *testing.T) {
+	const port = 19411
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+	defer respondOn(t, "127.0.0.3", port)()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithGroups("127.0.0.3"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(500),
+	)
+
+	responses, err := ssdpClient.Search(ssdp.ALL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(responses) != 2 {
+		t.Fatalf("expected 2 responses, got %d", len(responses))
+	}
+
+	for _, response := range responses {
+		if response.Group != response.USN {
+			t.Errorf("response from %s attributed to group %s", response.USN, response.Group)
+		}
+		if response.InterfaceIndex != loopback.Index {
+			t.Errorf("response from %s received on interface %d, expected %d", response.USN, response.InterfaceIndex, loopback.Index)
+		}
+		if response.LocalAddr == nil || !response.LocalAddr.IP.Equal(net.ParseIP("127.0.0.1")) {
+			t.Errorf("response from %s received on %v", response.USN, response.LocalAddr)
+		}
+	}
+}
+
+func Test_SearchIncludeSelf(t *testing.T) {
+	const port = 19441
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	for _, include := range []bool{false, true} {
+		ssdpClient := ssdp.NewSSDP(
+			ssdp.WithPort(port),
+			ssdp.WithInterface(loopback.Name),
+			ssdp.WithBroadcast("127.0.0.2"),
+			ssdp.WithIncludeSelf(include),
+			ssdp.WithTimeout(200),
+		)
+
+		responses, err := ssdpClient.Search(ssdp.ALL.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The responder runs on this machine, so it is only seen when
+		// responses from local addresses are included
+		expected := 0
+		if include {
+			expected = 1
+		}
+		if len(responses) != expected {
+			t.Errorf("expected %d responses with WithIncludeSelf(%v), got %d", expected, include, len(responses))
+		}
+	}
+}
+
+func Test_SearchOnLinkOnly(t *testing.T) {
+	const port = 19412
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	var progress ssdp.Progress
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithOnLinkOnly(true),
+		ssdp.WithTimeout(500),
+		ssdp.WithProgress(func(p ssdp.Progress) { progress = p }),
+	)
+
+	responses, err := ssdpClient.Search(ssdp.ALL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The loopback prefix is local, so the response is kept.
+	if len(responses) != 1 || progress.OffLink != 0 {
+		t.Errorf("expected the on-link response, got %d responses and %d dropped", len(responses), progress.OffLink)
+	}
+}
+
+func Test_SearchMaxResponses(t *testing.T) {
+	const port = 19413
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+	defer respondOn(t, "127.0.0.3", port)()
+
+	var progress ssdp.Progress
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithGroups("127.0.0.3"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithMaxResponses(1),
+		ssdp.WithTimeout(500),
+		ssdp.WithProgress(func(p ssdp.Progress) { progress = p }),
+	)
+
+	responses, err := ssdpClient.Search(ssdp.ALL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(responses) != 1 || progress.Overflow != 1 {
+		t.Errorf("expected 1 response and 1 overflow, got %d and %d", len(responses), progress.Overflow)
+	}
+}
+
+func Test_DiscoverPacketErrors(t *testing.T) {
+	const port = 19414
+
+	loopback := loopbackInterface(t)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		_, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		conn.WriteToUDP([]byte("not a response\r\n\r\n"), addr)
+		conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\nUSN: uuid:valid\r\n\r\n"), addr)
+	}()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(500),
+	)
+
+	responses, errs, err := ssdpClient.Discover(context.Background(), ssdp.ALL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var usns []string
+	for response := range responses {
+		usns = append(usns, response.USN)
+	}
+
+	var packetErrors []error
+	for err := range errs {
+		packetErrors = append(packetErrors, err)
+	}
+
+	if len(usns) != 1 || usns[0] != "uuid:valid" {
+		t.Errorf("unexpected responses %v", usns)
+	}
+
+	var packetError *ssdp.PacketError
+	if len(packetErrors) != 1 || !errors.As(packetErrors[0], &packetError) || packetError.Addr.Port != port {
+		t.Errorf("unexpected errors %v", packetErrors)
+	}
+}
+
+func Test_SearchUDAVersionHeaders(t *testing.T) {
+	const port = 19415
+
+	loopback := loopbackInterface(t)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	searches := make(chan string, 1)
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		n, _, err := conn.ReadFromUDP(buf)
+		if err == nil {
+			searches <- string(buf[:n])
+		}
+	}()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithTimeout(1000),
+		ssdp.WithUDAVersion(ssdp.UDA20),
+		ssdp.WithControlPoint("Living room panel", "uuid:panel"),
+	)
+
+	if _, err := ssdpClient.Search(ssdp.ALL.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	search := <-searches
+	for _, header := range []string{"User-Agent: ", "UPnP/2.0", "CPFN.UPNP.ORG: Living room panel\r\n", "CPUUID.UPNP.ORG: uuid:panel\r\n"} {
+		if !strings.Contains(search, header) {
+			t.Errorf("search lacks %q:\n%s", header, search)
+		}
+	}
+}
+
+func Test_SearchFilters(t *testing.T) {
+	const port = 19417
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+	defer respondOn(t, "127.0.0.3", port)()
+	defer respondOn(t, "127.0.0.4", port)()
+
+	_, excluded, _ := net.ParseCIDR("127.0.0.3/32")
+
+	var progress ssdp.Progress
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithGroups("127.0.0.3", "127.0.0.4"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithIncludeOnly(ssdp.DeviceFilter{UDNs: []string{"127.0.0.2", "127.0.0.3"}}),
+		ssdp.WithExclude(ssdp.DeviceFilter{Networks: []*net.IPNet{excluded}}),
+		ssdp.WithTimeout(500),
+		ssdp.WithProgress(func(p ssdp.Progress) { progress = p }),
+	)
+
+	responses, err := ssdpClient.Search(ssdp.ALL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(responses) != 1 || responses[0].USN != "127.0.0.2" || progress.Filtered != 2 {
+		t.Errorf("expected only the response of 127.0.0.2, got %v with %d filtered", responses, progress.Filtered)
+	}
+}
+
+func Test_SearchAdaptiveTimeout(t *testing.T) {
+	const port = 19418
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(200),
+		ssdp.WithAdaptiveTimeout(20*time.Millisecond),
+	)
+
+	// The full window is used until enough searches have been seen.
+	for i := 0; i < 8; i++ {
+		start := time.Now()
+		if _, err := ssdpClient.Search(ssdp.ALL.String()); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+			t.Fatalf("search %d took only %v", i, elapsed)
+		}
+	}
+
+	start := time.Now()
+	responses, err := ssdpClient.Search(ssdp.ALL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond || len(responses) != 1 {
+		t.Errorf("adapted search took %v and found %d responses", elapsed, len(responses))
+	}
+}
+
+func Test_SearchRateLimit(t *testing.T) {
+	loopback := loopbackInterface(t)
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(19419),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithTimeout(10),
+		ssdp.WithSearchRateLimit(10, 1),
+	)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := ssdpClient.Search(ssdp.ALL.String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first search uses the burst, the others wait 100ms each.
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("3 searches at 10 per second took only %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ssdpClient.SearchContext(ctx, ssdp.ALL.String()); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a canceled wait, got %v", err)
+	}
+}
+
+func Test_SearchWithRetry(t *testing.T) {
+	const port = 19420
+
+	loopback := loopbackInterface(t)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	// Ignore the first two searches like a device still booting.
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		for i := 0; ; i++ {
+			_, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if i >= 2 {
+				conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\nUSN: uuid:late\r\n\r\n"), addr)
+			}
+		}
+	}()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+	)
+
+	responses, err := ssdpClient.SearchWithRetry(context.Background(), ssdp.ALL.String(), ssdp.RetryPolicy{Attempts: 2, Backoff: 10 * time.Millisecond})
+	if err != nil || len(responses) != 0 {
+		t.Fatalf("expected no responses after 2 attempts, got %v, %v", responses, err)
+	}
+
+	responses, err = ssdpClient.SearchWithRetry(context.Background(), ssdp.ALL.String(), ssdp.RetryPolicy{Attempts: 3, Backoff: 10 * time.Millisecond})
+	if err != nil || len(responses) != 1 || responses[0].USN != "uuid:late" {
+		t.Errorf("expected the late response, got %v, %v", responses, err)
+	}
+}
+
+func Test_SearchMiddleware(t *testing.T) {
+	const port = 19421
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+	defer respondOn(t, "127.0.0.3", port)()
+
+	var seen []string
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithGroups("127.0.0.3"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(200),
+		ssdp.WithSearchMiddleware(ssdp.SearchMiddleware{
+			Send: func(next ssdp.SearchSender) ssdp.SearchSender {
+				return func(request *http.Request) error {
+					request.Header.Set("X-Test", "outer")
+					return next(request)
+				}
+			},
+			Receive: func(next ssdp.SearchReceiver) ssdp.SearchReceiver {
+				return func(response *ssdp.SearchResponse) {
+					if response.USN == "127.0.0.3" {
+						return
+					}
+					response.Server = "rewritten"
+					next(response)
+				}
+			},
+		}, ssdp.SearchMiddleware{
+			Send: func(next ssdp.SearchSender) ssdp.SearchSender {
+				return func(request *http.Request) error {
+					seen = append(seen, request.Header.Get("X-Test"))
+					return next(request)
+				}
+			},
+		}),
+	)
+
+	responses, err := ssdpClient.Search(ssdp.ALL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != 2 || seen[0] != "outer" || seen[1] != "outer" {
+		t.Errorf("expected both searches to pass the inner middleware after the outer, got %v", seen)
+	}
+	if len(responses) != 1 || responses[0].USN != "127.0.0.2" || responses[0].Server != "rewritten" {
+		t.Errorf("expected only the rewritten response of 127.0.0.2, got %v", responses)
+	}
+}
+
+func Test_Probe(t *testing.T) {
+	const port = 19422
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buf[:n])))
+			if err != nil || request.Header.Get("MX") != "" {
+				continue
+			}
+			conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nST: "+request.Header.Get("ST")+"\r\nUSN: uuid:alive\r\n\r\n"), addr)
+		}
+	}()
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithTimeout(300))
+
+	start := time.Now()
+	response, err := ssdpClient.Probe(context.Background(), &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port}, "uuid:alive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.USN != "uuid:alive" || time.Since(start) > 100*time.Millisecond {
+		t.Errorf("expected an immediate response, got %v after %v", response, time.Since(start))
+	}
+
+	// Listening, so the probes are not rejected with port unreachable
+	silent, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.3"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer silent.Close()
+
+	_, err = ssdpClient.Probe(context.Background(), &net.UDPAddr{IP: net.ParseIP("127.0.0.3"), Port: port}, "uuid:alive")
+	if !errors.Is(err, ssdp.ErrNoResponse) {
+		t.Errorf("expected ErrNoResponse from a silent address, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = ssdpClient.Probe(ctx, &net.UDPAddr{IP: net.ParseIP("127.0.0.3"), Port: port}, "uuid:alive")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+}
+
+func Test_ProbePortUnreachable(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("port unreachable is only reported on Linux")
+	}
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithTimeout(300))
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.4"), Port: 19434}
+
+	for i := 0; i < 2; i++ {
+		_, err := ssdpClient.Probe(context.Background(), addr, "uuid:alive")
+		if !errors.Is(err, ssdp.ErrPortUnreachable) || !errors.Is(err, ssdp.ErrNoResponse) {
+			t.Fatalf("expected ErrPortUnreachable, got %v", err)
+		}
+	}
+
+	unreachables := ssdpClient.Unreachables()
+	if len(unreachables) != 1 || unreachables[0].Addr != addr.String() || unreachables[0].Count != 2 {
+		t.Errorf("unexpected unreachables %+v", unreachables)
+	}
+}
+
+func Test_SearchMux(t *testing.T) {
+	const port = 19423
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(200),
+	)
+
+	mux, err := ssdpClient.NewSearchMux()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mux.Close()
+
+	// The responder answers both searches with upnp:rootdevice, the second
+	// starts once the first is registered
+	results := make(chan []ssdp.SearchResponse)
+	go func() {
+		responses, err := mux.Search(context.Background(), "upnp:rootdevice")
+		if err != nil {
+			t.Error(err)
+		}
+		results <- responses
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	responses, err := mux.Search(context.Background(), "uuid:nobody")
+	if err != nil || len(responses) != 0 {
+		t.Errorf("expected no responses for the unmatched target, got %v, %v", responses, err)
+	}
+	if responses := <-results; len(responses) != 2 {
+		t.Errorf("expected both rootdevice answers, got %v", responses)
+	}
+
+	stray, err := net.DialUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.3")}, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stray.Close()
+	stray.Write([]byte("HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\nUSN: uuid:stray\r\n\r\n"))
+
+	select {
+	case response := <-mux.Unmatched():
+		if response.USN != "uuid:stray" {
+			t.Errorf("expected the stray response, got %v", response)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the response without a search to be unmatched")
+	}
+
+	mux.Close()
+	if _, err := mux.Search(context.Background(), "upnp:rootdevice"); !errors.Is(err, ssdp.ErrMuxClosed) {
+		t.Errorf("expected ErrMuxClosed, got %v", err)
+	}
+}
+
+func Test_SearchDuplicates(t *testing.T) {
+	const port = 19424
+
+	loopback := loopbackInterface(t)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	// Answer every search twice, like a device behind a multicast loop
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		for {
+			_, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			for i := 0; i < 2; i++ {
+				conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\nUSN: uuid:twice\r\n\r\n"), addr)
+			}
+			conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nST: urn:schemas-upnp-org:device:Basic:1\r\nUSN: uuid:twice\r\n\r\n"), addr)
+		}
+	}()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+	)
+
+	responses, err := ssdpClient.Search(ssdp.ALL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	duplicates := []int{}
+	for _, response := range responses {
+		duplicates = append(duplicates, response.Duplicate)
+	}
+	if len(duplicates) != 3 || duplicates[0] != 0 || duplicates[1] != 1 || duplicates[2] != 0 {
+		t.Errorf("expected every copy with the second annotated, got %v", duplicates)
+	}
+}
+
+func Test_SearchCache(t *testing.T) {
+	const port = 19425
+
+	loopback := loopbackInterface(t)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	var searches int32
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		for {
+			_, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&searches, 1)
+			conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=1\r\nST: upnp:rootdevice\r\nUSN: uuid:cached\r\n\r\n"), addr)
+		}
+	}()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+	)
+	cache := ssdpClient.NewSearchCache()
+
+	for i := 0; i < 2; i++ {
+		responses, err := cache.Search(context.Background(), "upnp:rootdevice")
+		if err != nil || len(responses) != 1 {
+			t.Fatalf("expected the response, got %v, %v", responses, err)
+		}
+	}
+	if n := atomic.LoadInt32(&searches); n != 1 {
+		t.Errorf("expected the second search from the cache, got %d searches", n)
+	}
+
+	// Past half the max-age the cached result is served while refreshing
+	time.Sleep(600 * time.Millisecond)
+	start := time.Now()
+	if responses, err := cache.Search(context.Background(), "upnp:rootdevice"); err != nil || len(responses) != 1 || time.Since(start) > 50*time.Millisecond {
+		t.Errorf("expected the cached response at once, got %v, %v after %v", responses, err, time.Since(start))
+	}
+	time.Sleep(300 * time.Millisecond)
+	if n := atomic.LoadInt32(&searches); n != 2 {
+		t.Errorf("expected a background refresh, got %d searches", n)
+	}
+
+	cache.Invalidate("upnp:rootdevice")
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if responses, err := cache.Search(context.Background(), "upnp:rootdevice"); err != nil || len(responses) != 1 {
+				t.Errorf("expected the response, got %v, %v", responses, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&searches); n != 3 {
+		t.Errorf("expected concurrent searches to share one search, got %d searches", n)
+	}
+}
+
+func Test_SearchLogger(t *testing.T) {
+	const port = 19426
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	var mu sync.Mutex
+	var lines []string
+	var ids []interface{}
+	logger := ssdp.LoggerFunc(func(msg string, keysAndValues ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, msg)
+		if len(keysAndValues) >= 4 && keysAndValues[0] == "search" && keysAndValues[2] == "st" && keysAndValues[3] == "upnp:rootdevice" {
+			ids = append(ids, keysAndValues[1])
+		}
+	})
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+		ssdp.WithLogger(logger),
+	)
+
+	if _, err := ssdpClient.Search("upnp:rootdevice"); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	if len(lines) != 2 || lines[0] != "search sent" || lines[1] != "search done" || len(ids) != 2 || ids[0] != ids[1] {
+		t.Errorf("expected two lines of the same search, got %v with IDs %v", lines, ids)
+	}
+	mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := ssdpClient.SearchContext(ctx, "upnp:rootdevice")
+	var searchErr *ssdp.SearchError
+	if !errors.As(err, &searchErr) || searchErr.ST != "upnp:rootdevice" || searchErr.Interface != loopback.Name || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a SearchError wrapping the context error, got %v", err)
+	}
+}
+
+func Test_WakeAndDiscover(t *testing.T) {
+	const port = 19427
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	// Asleep for the first two probes
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		for probes := 1; ; probes++ {
+			_, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if probes > 2 {
+				conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\nUSN: uuid:tv::upnp:rootdevice\r\n\r\n"), addr)
+			}
+		}
+	}()
+
+	mac, _ := net.ParseMAC("a4:30:7a:01:02:03")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response, err := ssdp.NewSSDP(ssdp.WithTimeout(100)).WakeAndDiscover(ctx, mac, &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port}, "upnp:rootdevice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.USN != "uuid:tv::upnp:rootdevice" {
+		t.Errorf("unexpected response %v", response)
+	}
+
+	packet, _ := ssdp.MagicPacket(mac)
+	if len(packet) != 102 || !bytes.Equal(packet[:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) || !bytes.Equal(packet[96:], mac) {
+		t.Errorf("malformed magic packet %x", packet)
+	}
+}
+
+func Test_EventBus(t *testing.T) {
+	const port = 19431
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	bus := ssdp.NewEventBus(0)
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+		ssdp.WithEventBus(bus),
+	)
+	registry := ssdp.NewRegistry(ssdp.WithRegistryEvents(bus))
+
+	responses, err := ssdpClient.Search("upnp:rootdevice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, response := range responses {
+		registry.AddResponse(response)
+	}
+	registry.Remove("127.0.0.2")
+	bus.Close()
+
+	var types []ssdp.EventType
+	for event := range bus.Events() {
+		types = append(types, event.Type)
+		if event.Type == ssdp.EventSearchFinished && (event.ST != "upnp:rootdevice" || event.Responses != 1) {
+			t.Errorf("unexpected finished search %+v", event)
+		}
+		if event.Type == ssdp.EventDeviceAdded && event.Device.UDN != "127.0.0.2" {
+			t.Errorf("unexpected added device %+v", event.Device)
+		}
+	}
+
+	expected := []ssdp.EventType{ssdp.EventSearchStarted, ssdp.EventResponseReceived, ssdp.EventSearchFinished, ssdp.EventDeviceAdded, ssdp.EventDeviceRemoved}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected %v, got %v", expected, types)
+	}
+}
+
+func Test_SearchReverseDNS(t *testing.T) {
+	const port = 19432
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	expected := ""
+	if names, err := net.LookupAddr("127.0.0.2"); err == nil && len(names) > 0 {
+		expected = strings.TrimSuffix(names[0], ".")
+	}
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+		ssdp.WithReverseDNS(nil, time.Second),
+	)
+
+	for i := 0; i < 2; i++ {
+		responses, err := ssdpClient.Search("upnp:rootdevice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(responses) != 1 || responses[0].Hostname != expected {
+			t.Errorf("expected hostname %q, got %v", expected, responses)
+		}
+	}
+}
+
+func Test_SearchST(t *testing.T) {
+	const port = 19433
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+	)
+
+	for _, search := range []string{ssdp.ALL.String(), ssdp.RootDevice} {
+		responses, err := ssdpClient.Search(search)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(responses) != 1 || responses[0].SearchST != search || responses[0].ST != ssdp.RootDevice {
+			t.Errorf("expected a response to %s, got %v", search, responses)
+		}
+	}
+}
+
+func Test_AppendResponses(t *testing.T) {
+	const port = 19435
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+	)
+
+	responses := make([]ssdp.SearchResponse, 1, 4)
+	responses, err := ssdpClient.AppendResponses(context.Background(), responses, ssdp.RootDevice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 2 || responses[1].USN != "127.0.0.2" {
+		t.Fatalf("expected the response after the existing one, got %v", responses)
+	}
+	backing := &responses[0]
+
+	responses, err = ssdpClient.AppendResponses(context.Background(), responses[:0], ssdp.RootDevice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 1 || &responses[0] != backing {
+		t.Errorf("expected the response in the reused slice, got %v", responses)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if kept, err := ssdpClient.AppendResponses(ctx, responses, ssdp.RootDevice); err == nil || len(kept) != 1 {
+		t.Errorf("expected an error and the slice unchanged, got %v, %v", kept, err)
+	}
+}
+
+func Test_SearchDecoders(t *testing.T) {
+	const port = 19436
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+	)
+
+	type vendorResponse struct {
+		Vendor string
+		USN    string
+	}
+
+	decoders := ssdp.NewDecoders[vendorResponse]()
+	decoders.ForServer("Yamaha", func(response ssdp.SearchResponse, raw []byte) (vendorResponse, error) {
+		return vendorResponse{Vendor: "yamaha"}, nil
+	})
+	decoders.ForST(ssdp.RootDevice, func(response ssdp.SearchResponse, raw []byte) (vendorResponse, error) {
+		if !bytes.Contains(raw, []byte("USN: "+response.USN)) {
+			return vendorResponse{}, fmt.Errorf("unexpected message %q", raw)
+		}
+		return vendorResponse{Vendor: "generic", USN: response.USN}, nil
+	})
+
+	results, err := ssdp.Search(context.Background(), ssdpClient, decoders, ssdp.ALL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0] != (vendorResponse{Vendor: "generic", USN: "127.0.0.2"}) {
+		t.Errorf("expected the response decoded by the ST decoder, got %v", results)
+	}
+
+	failing := ssdp.NewDecoders[vendorResponse]()
+	failing.ForST(ssdp.RootDevice, func(response ssdp.SearchResponse, raw []byte) (vendorResponse, error) {
+		return vendorResponse{}, errors.New("undecodable")
+	})
+	results, err = ssdp.Search(context.Background(), ssdpClient, failing, ssdp.ALL.String())
+	if err != nil || len(results) != 0 {
+		t.Errorf("expected the undecodable response left out, got %v, %v", results, err)
+	}
+}
+
+func Test_SearchAs(t *testing.T) {
+	const port = 19437
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+	defer respondOn(t, "127.0.0.3", port)()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithGroups("127.0.0.3"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+	)
+
+	type host string
+	hosts, err := ssdp.SearchAs(context.Background(), ssdpClient, ssdp.RootDevice, func(response ssdp.SearchResponse) (host, error) {
+		if response.USN == "127.0.0.3" {
+			return "", errors.New("unwanted")
+		}
+		return host(response.USN), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hosts, []host{"127.0.0.2"}) {
+		t.Errorf("expected the wanted host, got %v", hosts)
+	}
+}
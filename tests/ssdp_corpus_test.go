@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"bytes"
+	"flag"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Run `go test ./tests -run Test_Corpus -update` to regenerate the expected
+// output after adding a capture to testdata/corpus.
+var update = flag.Bool("update", false, "update the expected output of the corpus tests")
+
+var corpusAddr = &net.UDPAddr{IP: net.ParseIP("192.168.1.2"), Port: 1900}
+
+func Test_Corpus(t *testing.T) {
+	captures, err := filepath.Glob(filepath.Join("testdata", "corpus", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, capture := range captures {
+		capture := capture
+		t.Run(filepath.Base(capture), func(t *testing.T) {
+			actual := describeCapture(t, capture)
+			expectedPath := filepath.Join(capture, "expected.txt")
+
+			if *update {
+				if err := ioutil.WriteFile(expectedPath, actual, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := ioutil.ReadFile(expectedPath)
+			if err != nil {
+				t.Fatalf("missing expected output, run with -update: %v", err)
+			}
+
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("output mismatch\nexpected:\n%s\nactual:\n%s", expected, actual)
+			}
+		})
+	}
+}
+
+// describeCapture parses the response.txt and description.xml of a capture,
+// either of which may be missing.
+func describeCapture(t *testing.T, capture string) []byte {
+	var out bytes.Buffer
+
+	response, err := ioutil.ReadFile(filepath.Join(capture, "response.txt"))
+	if err == nil {
+		searchResponse, err := ssdp.ParseSearchResponse(bytes.NewReader(response), corpusAddr)
+		if err != nil {
+			t.Fatalf("parsing response: %v", err)
+		}
+		searchResponse.Findings, err = ssdp.ValidateSearchResponse(bytes.NewReader(response), ssdp.UDA10)
+		if err != nil {
+			t.Fatalf("validating response: %v", err)
+		}
+		out.WriteString("[response]\n")
+		out.WriteString(searchResponse.Describe())
+	}
+
+	description, err := os.Open(filepath.Join(capture, "description.xml"))
+	if err == nil {
+		defer description.Close()
+
+		device, err := ssdp.ParseDescription(description)
+		if err != nil {
+			t.Fatalf("parsing description: %v", err)
+		}
+		out.WriteString("[description]\n")
+		out.WriteString(device.Describe())
+	}
+
+	if out.Len() == 0 {
+		t.Fatal("capture contains neither response.txt nor description.xml")
+	}
+
+	return out.Bytes()
+}
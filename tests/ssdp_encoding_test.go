@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_FetchCompressedDescription(t *testing.T) {
+	description, err := ioutil.ReadFile("../example/responses/hue_description.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compress := map[string]func(io.Writer) io.WriteCloser{
+		"gzip":      func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate":   func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		"raw-flate": func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.DefaultCompression); return fw },
+	}
+
+	for name, newWriter := range compress {
+		body := &bytes.Buffer{}
+		writer := newWriter(body)
+		writer.Write(description)
+		writer.Close()
+
+		encoding := strings.TrimPrefix(name, "raw-")
+		if encoding == "flate" {
+			encoding = "deflate"
+		}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+				t.Errorf("%s: missing Accept-Encoding, got %q", name, r.Header.Get("Accept-Encoding"))
+			}
+			w.Header().Set("Content-Encoding", encoding)
+			w.Write(body.Bytes())
+		}))
+
+		location, _ := url.Parse(server.URL)
+		device, err := ssdp.NewSSDP().FetchDescription(location)
+		server.Close()
+
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if device.FriendlyName != "Philips hue (192.168.0.21)" {
+			t.Errorf("%s: unexpected device %v", name, device)
+		}
+	}
+}
+
+func Test_FetchCompressedDescriptionLimit(t *testing.T) {
+	// A small compressed body that expands beyond the description limit
+	body := &bytes.Buffer{}
+	writer := gzip.NewWriter(body)
+	writer.Write([]byte("<root><device><friendlyName>"))
+	writer.Write(bytes.Repeat([]byte("x"), ssdp.MaxDescriptionSize))
+	writer.Write([]byte("</friendlyName></device></root>"))
+	writer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(body.Bytes())
+	}))
+	defer server.Close()
+
+	location, _ := url.Parse(server.URL)
+	_, err := ssdp.NewSSDP().FetchDescription(location)
+
+	if !errors.Is(err, ssdp.ErrDescriptionTooLarge) {
+		t.Errorf("expected %v, got %v", ssdp.ErrDescriptionTooLarge, err)
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func Test_FetchDescriptionFSFetcher(t *testing.T) {
+	location, _ := url.Parse("http://192.168.0.21:80/hue_description.xml")
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithFetcher(ssdp.FSFetcher(os.DirFS("../example/responses"))))
+
+	device, err := ssdpClient.FetchDescription(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.FriendlyName != "Philips hue (192.168.0.21)" || device.Location.String() != location.String() {
+		t.Errorf("unexpected device %v", device)
+	}
+
+	missing, _ := url.Parse("http://192.168.0.21:80/missing.xml")
+	var fetchErr *ssdp.FetchError
+	if _, err := ssdpClient.FetchDescription(missing); !errors.As(err, &fetchErr) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a FetchError wrapping fs.ErrNotExist, got %v", err)
+	}
+}
+
+func Test_FetchDescriptionFetcherRetry(t *testing.T) {
+	location, _ := url.Parse("proprietary://device/description.xml")
+
+	attempts := 0
+	fetcher := ssdp.FetcherFunc(func(ctx context.Context, location url.URL) (io.ReadCloser, error) {
+		attempts++
+		if attempts < 2 {
+			return nil, errors.New("busy")
+		}
+		return os.Open("../example/responses/hue_description.xml")
+	})
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithFetcher(fetcher), ssdp.WithFetchRetry(ssdp.RetryPolicy{Attempts: 3}))
+	if _, err := ssdpClient.FetchDescription(location); err != nil || attempts != 2 {
+		t.Errorf("expected success on the second attempt, got %v after %d", err, attempts)
+	}
+
+	attempts = 0
+	permanent := ssdp.FetcherFunc(func(ctx context.Context, location url.URL) (io.ReadCloser, error) {
+		attempts++
+		return nil, ssdp.Permanent(errors.New("unsupported"))
+	})
+	ssdpClient = ssdp.NewSSDP(ssdp.WithFetcher(permanent), ssdp.WithFetchRetry(ssdp.RetryPolicy{Attempts: 3}))
+	if _, err := ssdpClient.FetchDescription(location); err == nil || attempts != 1 {
+		t.Errorf("expected a permanent error without retries, got %v after %d", err, attempts)
+	}
+}
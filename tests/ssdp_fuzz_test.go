@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"bytes"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var fuzzAddr = &net.UDPAddr{IP: net.ParseIP("192.168.0.21"), Port: 1900}
+
+const searchResponseSeed = "HTTP/1.1 200 OK\r\n" +
+	"CACHE-CONTROL: max-age=100\r\n" +
+	"EXT:\r\n" +
+	"LOCATION: http://192.168.0.21:80/description.xml\r\n" +
+	"SERVER: Linux/3.14.0 UPnP/1.0 IpBridge/1.41.0\r\n" +
+	"ST: upnp:rootdevice\r\n" +
+	"USN: uuid:2f402f80-da50-11e1-9b23-001788255acc::upnp:rootdevice\r\n" +
+	"\r\n"
+
+const notifySeed = "NOTIFY * HTTP/1.1\r\n" +
+	"HOST: 239.255.255.250:1900\r\n" +
+	"CACHE-CONTROL: max-age=1800\r\n" +
+	"LOCATION: http://192.168.0.21:80/description.xml\r\n" +
+	"NT: upnp:rootdevice\r\n" +
+	"NTS: ssdp:alive\r\n" +
+	"SERVER: Linux/3.14.0 UPnP/1.0 IpBridge/1.41.0\r\n" +
+	"USN: uuid:2f402f80-da50-11e1-9b23-001788255acc::upnp:rootdevice\r\n" +
+	"\r\n"
+
+func Fuzz_ParseSearchResponse(f *testing.F) {
+	f.Add([]byte(searchResponseSeed))
+	f.Add([]byte("HTTP/1.1 200 OK\r\nLOCATION: /relative\r\nDATE: garbage\r\n\r\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = ssdp.ParseSearchResponse(bytes.NewReader(data), fuzzAddr)
+	})
+}
+
+func Fuzz_ParseNotify(f *testing.F) {
+	f.Add([]byte(notifySeed))
+	f.Add([]byte("NOTIFY * HTTP/1.1\r\nNTS: ssdp:byebye\r\n\r\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = ssdp.ParseNotify(bytes.NewReader(data), fuzzAddr)
+	})
+}
+
+func Fuzz_ParseDescription(f *testing.F) {
+	description, err := ioutil.ReadFile("../example/responses/hue_description.xml")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(description)
+	f.Add([]byte("<root><device><iconList><icon><width>x</width></icon></iconList></device></root>"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = ssdp.ParseDescription(bytes.NewReader(data))
+	})
+}
+
+func Test_ParseNotify(t *testing.T) {
+	notify, err := ssdp.ParseNotify(strings.NewReader(notifySeed), fuzzAddr)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if notify.NTS != ssdp.NTSAlive || notify.NT != "upnp:rootdevice" {
+		t.Errorf("unexpected notify %+v", notify)
+	}
+
+	if notify.Location == nil || notify.Location.Host != "192.168.0.21:80" {
+		t.Errorf("unexpected location %v", notify.Location)
+	}
+}
+
+func Test_ParseLimits(t *testing.T) {
+	tooMany := "HTTP/1.1 200 OK\r\n" + strings.Repeat("X-Header: value\r\n", ssdp.MaxHeaderCount+1) + "\r\n"
+	if _, err := ssdp.ParseSearchResponse(strings.NewReader(tooMany), fuzzAddr); err != ssdp.ErrTooManyHeaders {
+		t.Errorf("expected %v, got %v", ssdp.ErrTooManyHeaders, err)
+	}
+
+	tooLong := "HTTP/1.1 200 OK\r\nSERVER: " + strings.Repeat("x", ssdp.MaxHeaderLength) + "\r\n\r\n"
+	if _, err := ssdp.ParseSearchResponse(strings.NewReader(tooLong), fuzzAddr); err != ssdp.ErrHeaderTooLong {
+		t.Errorf("expected %v, got %v", ssdp.ErrHeaderTooLong, err)
+	}
+
+	tooLarge := strings.Repeat("x", ssdp.MaxMessageSize+1)
+	if _, err := ssdp.ParseSearchResponse(strings.NewReader(tooLarge), fuzzAddr); err != ssdp.ErrMessageTooLarge {
+		t.Errorf("expected %v, got %v", ssdp.ErrMessageTooLarge, err)
+	}
+
+	description := "<root><device><friendlyName>" + strings.Repeat("x", ssdp.MaxDescriptionSize) + "</friendlyName></device></root>"
+	if _, err := ssdp.ParseDescription(strings.NewReader(description)); err != ssdp.ErrDescriptionTooLarge {
+		t.Errorf("expected %v, got %v", ssdp.ErrDescriptionTooLarge, err)
+	}
+}
+
+func Fuzz_ScanNotify(f *testing.F) {
+	f.Add([]byte(notifySeed))
+	f.Add([]byte("NOTIFY * HTTP/1.1\r\nNTS: ssdp:byebye\r\n\r\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = ssdp.ScanNotify(data, fuzzAddr)
+	})
+}
+
+func Test_ScanNotify(t *testing.T) {
+	messages := []string{
+		notifySeed,
+		"NOTIFY * HTTP/1.1\nnt: upnp:rootdevice\nnts: ssdp:byebye\nusn: uuid:1::upnp:rootdevice\n\n",
+		"NOTIFY * HTTP/1.1\r\nNT : upnp:rootdevice\r\nNTS:ssdp:alive \r\nUSN: uuid:1\r\nUSN: uuid:2\r\n\r\n",
+		"NOTIFY * HTTP/1.1\r\nOPT: \"http://schemas.upnp.org/upnp/1/0/\"; ns=02\r\n01-NLS: first\r\n02-NLS: second\r\nNT: upnp:rootdevice\r\n\r\n",
+		"NOTIFY * HTTP/1.1\r\nNLS: bare\r\nSEARCHPORT.UPNP.ORG: 49200\r\nLOCATION: http://[fe80::1]:80/description.xml\r\n\r\n",
+	}
+
+	addr := &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 1900, Zone: "eth0"}
+	for _, message := range messages {
+		parsed, err := ssdp.ParseNotify(strings.NewReader(message), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanned, err := ssdp.ScanNotify([]byte(message), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(parsed, scanned) {
+			t.Errorf("expected %+v, got %+v", parsed, scanned)
+		}
+	}
+
+	for _, message := range []string{"GET / HTTP/1.1\r\n\r\n", "NOTIFY\r\n\r\n", "NOTIFY * HTTP/1.1\r\nbroken\r\n\r\n"} {
+		if _, err := ssdp.ScanNotify([]byte(message), addr); err == nil {
+			t.Errorf("expected an error for %q", message)
+		}
+	}
+
+	tooMany := "NOTIFY * HTTP/1.1\r\n" + strings.Repeat("X-Header: value\r\n", ssdp.MaxHeaderCount+1) + "\r\n"
+	if _, err := ssdp.ScanNotify([]byte(tooMany), addr); err != ssdp.ErrTooManyHeaders {
+		t.Errorf("expected %v, got %v", ssdp.ErrTooManyHeaders, err)
+	}
+}
@@ -0,0 +1,252 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func Test_FetchDescriptionDecorator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+	}))
+	defer server.Close()
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	transport := &countingTransport{}
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithTransport(transport), ssdp.WithRequestDecorator(func(request *http.Request) error {
+		if request.URL.Host == location.Host {
+			request.SetBasicAuth("admin", "secret")
+		}
+		return nil
+	}))
+
+	if _, err := ssdpClient.FetchDescription(location); err != nil {
+		t.Fatal(err)
+	}
+
+	if transport.requests != 1 {
+		t.Errorf("expected the custom transport to be used once, got %d", transport.requests)
+	}
+
+	if _, err := ssdp.NewSSDP().FetchDescription(location); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
+
+func Test_FetchDescriptionLocationRewriter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+	}))
+	defer server.Close()
+
+	reachable, _ := url.Parse(server.URL)
+	internal, _ := url.Parse("http://172.17.0.2:" + reachable.Port() + "/description.xml")
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithLocationRewriter(ssdp.RewriteHost(map[string]string{
+		"172.17.0.2": reachable.Hostname(),
+	})))
+
+	device, err := ssdpClient.FetchDescription(internal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Location.Host != reachable.Host {
+		t.Errorf("expected the device location to be rewritten to %s, got %s", reachable.Host, device.Location.Host)
+	}
+
+	rewrite := ssdp.RewriteHost(map[string]string{"10.0.0.1": "example.com:8080"})
+	if got := rewrite(url.URL{Scheme: "http", Host: "10.0.0.1:49152"}); got.Host != "example.com:8080" {
+		t.Errorf("expected the port of the replacement to win, got %s", got.Host)
+	}
+	if got := rewrite(url.URL{Scheme: "http", Host: "10.0.0.2:49152"}); got.Host != "10.0.0.2:49152" {
+		t.Errorf("expected unmapped hosts to be kept, got %s", got.Host)
+	}
+}
+
+func Test_FetchDescriptionProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	location, _ := url.Parse("http://device.invalid:49152/description.xml")
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithProxy(http.ProxyURL(proxyURL)))
+	if _, err := ssdpClient.FetchDescription(location); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(proxied) != 1 || proxied[0] != location.String() {
+		t.Errorf("expected the fetch to go through the proxy, got %v", proxied)
+	}
+}
+
+func Test_FetchDescriptionNoKeepAlive(t *testing.T) {
+	var closed []bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		closed = append(closed, r.Close)
+		w.Header().Set("Server", "Linux/2.6 UPnP/1.0 Fragile/1.0")
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+	}))
+	defer server.Close()
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	ssdpClient := ssdp.NewSSDP(ssdp.WithQuirks(ssdp.Quirk{Name: "fragile", Server: "Fragile/1.0", NoKeepAlive: true}))
+
+	for i := 0; i < 2; i++ {
+		if _, err := ssdpClient.FetchDescription(location); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(closed) != 2 || closed[0] || !closed[1] {
+		t.Errorf("expected the connection to be closed once the quirk was learned, got %v", closed)
+	}
+
+	closed = nil
+	ssdpClient = ssdp.NewSSDP(ssdp.WithHTTPTuning(ssdp.HTTPTuning{DisableKeepAlives: true}))
+	if _, err := ssdpClient.FetchDescription(location); err != nil {
+		t.Fatal(err)
+	}
+	if len(closed) != 1 || !closed[0] {
+		t.Errorf("expected keep-alive to be disabled, got %v", closed)
+	}
+}
+
+func Test_Reachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	location, _ := url.Parse(server.URL + "/description.xml")
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithReachabilityCheck(time.Second))
+	if err := ssdpClient.Reachable(context.Background(), *location); err != nil {
+		t.Errorf("expected the server to be reachable, got %v", err)
+	}
+
+	server.Close()
+	if err := ssdpClient.Reachable(context.Background(), *location); !errors.Is(err, ssdp.ErrUnreachable) {
+		t.Errorf("expected ErrUnreachable from a closed server, got %v", err)
+	}
+}
+
+func Test_ReachableChecksBeforeDialing(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	location, _ := url.Parse(server.URL + "/description.xml")
+	server.Close()
+
+	proxied := &http.Transport{Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: "proxy.invalid:3128"})}
+	ssdpClient := ssdp.NewSSDP(ssdp.WithReachabilityCheck(time.Second), ssdp.WithTransport(proxied))
+	if err := ssdpClient.Reachable(context.Background(), *location); err != nil {
+		t.Errorf("expected a location behind the proxy of the transport not to be checked, got %v", err)
+	}
+
+	named, _ := url.Parse("http://device.invalid/description.xml")
+	ssdpClient = ssdp.NewSSDP(ssdp.WithReachabilityCheck(time.Second), ssdp.WithNumericAddresses(true))
+	if err := ssdpClient.Reachable(context.Background(), *named); !errors.Is(err, ssdp.ErrHostnameLookup) {
+		t.Errorf("expected ErrHostnameLookup for a hostname in numeric mode, got %v", err)
+	}
+}
+
+func Test_FetchDescriptionPerHostLimit(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	for _, limit := range []int{1, 2} {
+		ssdpClient := ssdp.NewSSDP(ssdp.WithMaxFetchesPerHost(limit))
+		peak = 0
+
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				location, _ := url.Parse(fmt.Sprintf("%s/description-%d.xml", server.URL, i))
+				if _, err := ssdpClient.FetchDescription(location); err != nil {
+					t.Error(err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if peak != limit {
+			t.Errorf("expected at most %d concurrent requests, got %d", limit, peak)
+		}
+	}
+}
+
+func Test_DescriptionHooks(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("../example/responses")))
+	defer server.Close()
+	location, _ := url.Parse(server.URL + "/hue_description.xml")
+
+	var called []string
+	tag := func(device *ssdp.Device, response *ssdp.SearchResponse) error {
+		called = append(called, "tag")
+		if response != nil {
+			t.Errorf("expected no response for a direct fetch, got %v", response)
+		}
+		device.FriendlyName = strings.ToUpper(device.FriendlyName)
+		return nil
+	}
+	reject := func(device *ssdp.Device, response *ssdp.SearchResponse) error {
+		called = append(called, "reject")
+		return errors.New("unknown vendor")
+	}
+
+	device, err := ssdp.NewSSDP(ssdp.WithDescriptionHooks(tag)).FetchDescription(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.FriendlyName != strings.ToUpper(device.FriendlyName) {
+		t.Errorf("expected the hook to normalize the name, got %q", device.FriendlyName)
+	}
+
+	called = nil
+	if _, err := ssdp.NewSSDP(ssdp.WithDescriptionHooks(reject, tag)).FetchDescription(location); err == nil || err.Error() != "unknown vendor" {
+		t.Errorf("expected the error of the hook, got %v", err)
+	}
+	if !reflect.DeepEqual(called, []string{"reject"}) {
+		t.Errorf("expected the hooks to stop at the error, got %v", called)
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const lintDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+<specVersion><major>1</major><minor>0</minor></specVersion>
+<device>
+<deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType>
+<friendlyName>Kitchen speaker</friendlyName>
+<manufacturer>Example</manufacturer>
+<UDN>uuid:speaker</UDN>
+<serviceList>
+<service><serviceType>urn:schemas-upnp-org:service:RenderingControl:1</serviceType><serviceId>urn:upnp-org:serviceId:RenderingControl</serviceId><SCPDURL>/rc.xml</SCPDURL><controlURL>/rc/control</controlURL><eventSubURL>/rc/event</eventSubURL></service>
+<service><serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType><serviceId>urn:upnp-org:serviceId:AVTransport</serviceId><SCPDURL>/missing.xml</SCPDURL><controlURL>/avt/control</controlURL><eventSubURL>/avt/event</eventSubURL></service>
+</serviceList>
+</device>
+</root>`
+
+func Test_Lint(t *testing.T) {
+	const port = 19416
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/description.xml":
+			fmt.Fprint(w, lintDescription)
+		case "/rc.xml":
+			fmt.Fprint(w, "<scpd/>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	loopback := loopbackInterface(t)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.2"), Port: port})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, ssdp.MaxMessageSize)
+		for {
+			_, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			response := "HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=1800\r\nEXT:\r\nLOCATION: " + server.URL + "/description.xml\r\n" +
+				"SERVER: Linux/5.10 UPnP/1.0 Speaker/1.0\r\nST: uuid:speaker\r\nUSN: uuid:speaker\r\n\r\n"
+			conn.WriteToUDP([]byte(response), addr)
+		}
+	}()
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(1000),
+	)
+
+	report, err := ssdpClient.Lint(context.Background(), ssdp.LintConfig{UDN: "uuid:speaker"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var findings []string
+	for _, finding := range report.Findings {
+		findings = append(findings, finding.String())
+	}
+
+	expected := []string{
+		"error: modelName: missing",
+		`error: service urn:upnp-org:serviceId:AVTransport SCPDURL: ` + server.URL + `/missing.xml answered "404 Not Found"`,
+	}
+	if strings.Join(findings, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected findings:\n%s", strings.Join(findings, "\n"))
+	}
+	if report.Errors() != 2 {
+		t.Errorf("expected 2 errors, got %d", report.Errors())
+	}
+}
@@ -0,0 +1,407 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const monitorGroup = "239.255.255.250"
+
+func sendNotifies(t *testing.T, port int, count int) {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.ParseIP(monitorGroup), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < count; i++ {
+		notify := strings.Replace(notifySeed, "upnp:rootdevice\r\nNTS", fmt.Sprintf("uuid:%d\r\nNTS", i), 1)
+		if _, err := conn.Write([]byte(notify)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_MonitorBufferedDelivery(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19001), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+
+	monitor, err := ssdpClient.Monitor(ssdp.WithBufferedDelivery(2))
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer monitor.Close()
+
+	// Nobody reads while the notifications arrive, so only the newest two
+	// are kept.
+	sendNotifies(t, 19001, 5)
+	time.Sleep(200 * time.Millisecond)
+
+	stats := monitor.Stats()
+	if stats.Received == 0 {
+		t.Skip("multicast loopback not available")
+	}
+
+	if stats.Dropped != stats.Received-2 {
+		t.Errorf("expected %d dropped notifications, got %+v", stats.Received-2, stats)
+	}
+
+	notify := <-monitor.Notifications()
+	if notify.NT != "uuid:3" {
+		t.Errorf("expected the oldest notifications to be dropped, got %s", notify.NT)
+	}
+}
+
+func Test_MonitorCallbackDelivery(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19002), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+
+	var mu sync.Mutex
+	received := 0
+	monitor, err := ssdpClient.Monitor(ssdp.WithCallbackDelivery(func(notify ssdp.Notify) {
+		mu.Lock()
+		received++
+		mu.Unlock()
+	}, 2, 10))
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+
+	sendNotifies(t, 19002, 5)
+	time.Sleep(200 * time.Millisecond)
+
+	if err := monitor.Close(); err != nil {
+		t.Error(err)
+	}
+
+	stats := monitor.Stats()
+	if uint64(received)+stats.Dropped != stats.Received {
+		t.Errorf("expected every notification to be delivered or dropped, got %d delivered and %+v", received, stats)
+	}
+}
+
+func Test_AnnounceAndRevoke(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19003), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+
+	monitor, err := ssdpClient.Monitor(ssdp.WithBufferedDelivery(8))
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer monitor.Close()
+
+	config := ssdp.AnnounceConfig{
+		NT:       "urn:example-com:service:Thing:1",
+		USN:      "uuid:thing::urn:example-com:service:Thing:1",
+		Location: "http://192.0.2.5:8080/thing",
+		MaxAge:   time.Minute,
+	}
+
+	if err := ssdpClient.Announce(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	if err := ssdpClient.Revoke(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []ssdp.Notify
+	timeout := time.After(time.Second)
+	for len(received) < 4 {
+		select {
+		case notify := <-monitor.Notifications():
+			received = append(received, notify)
+		case <-timeout:
+			if len(received) == 0 {
+				t.Skip("multicast loopback not available")
+			}
+			t.Fatalf("received only %d notifications", len(received))
+		}
+	}
+
+	alive, byebye := received[0], received[len(received)-1]
+	if alive.NTS != ssdp.NTSAlive || alive.USN != config.USN || alive.MaxAge() != time.Minute || alive.Location.String() != config.Location {
+		t.Errorf("unexpected alive %+v", alive)
+	}
+	if byebye.NTS != ssdp.NTSByeBye || byebye.NT != config.NT || byebye.Location != nil {
+		t.Errorf("unexpected byebye %+v", byebye)
+	}
+}
+
+func Test_MonitorDiagnostics(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19004), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+
+	var mu sync.Mutex
+	var kinds []string
+	monitor, err := ssdpClient.Monitor(ssdp.WithBufferedDelivery(16), ssdp.WithDiagnostics(func(diagnostic ssdp.Diagnostic) {
+		mu.Lock()
+		kinds = append(kinds, diagnostic.Kind.String())
+		mu.Unlock()
+	}))
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer monitor.Close()
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.ParseIP(monitorGroup), Port: 19004})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	messages := []string{
+		// A byebye for a device never announced alive
+		"NOTIFY * HTTP/1.1\r\nNT: upnp:rootdevice\r\nNTS: ssdp:byebye\r\nUSN: uuid:ghost::upnp:rootdevice\r\n\r\n",
+		// An alive with a USN for another NT and without a max-age
+		strings.NewReplacer("max-age=1800", "no-cache", "::upnp:rootdevice", "::urn:schemas-upnp-org:device:Basic:1").Replace(notifySeed),
+	}
+	// A consistent alive and byebye of the whole set of a device: its root
+	// device, UUID, device type and service type
+	const udn = "uuid:2f402f80-da50-11e1-9b23-001788255acc"
+	for _, nts := range []string{"ssdp:alive", "ssdp:byebye"} {
+		for _, nt := range []string{"upnp:rootdevice", udn, "urn:schemas-upnp-org:device:Basic:1", "urn:schemas-upnp-org:service:Dimming:1"} {
+			usn := udn + "::" + nt
+			if nt == udn {
+				usn = udn
+			}
+			message := strings.NewReplacer("ssdp:alive", nts, "NT: upnp:rootdevice", "NT: "+nt, "USN: "+udn+"::upnp:rootdevice", "USN: "+usn).Replace(notifySeed)
+			messages = append(messages, message)
+		}
+	}
+	for _, message := range messages {
+		if _, err := conn.Write([]byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if monitor.Stats().Received == 0 {
+		t.Skip("multicast loopback not available")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(kinds, " ") != "unknown-byebye usn-mismatch zero-max-age" {
+		t.Errorf("unexpected diagnostics %v", kinds)
+	}
+}
+
+func Test_MonitorConn(t *testing.T) {
+	// A socket bound beforehand, as systemd binds those it passes on
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: 19005})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19005), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+	monitor, err := ssdpClient.Monitor(ssdp.WithMonitorConn(conn), ssdp.WithBufferedDelivery(4))
+	if err != nil {
+		conn.Close()
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer monitor.Close()
+
+	sendNotifies(t, 19005, 1)
+
+	select {
+	case notify := <-monitor.Notifications():
+		if notify.NT != "uuid:0" {
+			t.Errorf("unexpected notification %v", notify)
+		}
+	case <-time.After(time.Second):
+		t.Skip("multicast loopback not available")
+	}
+
+	if conns, err := ssdp.ActivationConns(); err != nil || conns != nil {
+		t.Errorf("expected no inherited sockets outside socket activation, got %v, %v", conns, err)
+	}
+}
+
+func Test_MonitorCloseTwice(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19018), ssdp.WithBroadcast(monitorGroup))
+	monitor, err := ssdpClient.Monitor()
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = monitor.Close()
+		}(i)
+	}
+	wg.Wait()
+
+	if errs[0] != nil || errs[1] != nil {
+		t.Errorf("expected both closes to succeed, got %v", errs)
+	}
+	if err := monitor.Close(); err != nil {
+		t.Errorf("expected closing again to succeed, got %v", err)
+	}
+	if _, ok := <-monitor.Notifications(); ok {
+		t.Error("expected the notifications to be closed")
+	}
+}
+
+func Test_AnnounceLocationTemplate(t *testing.T) {
+	if location := ssdp.ExpandLocation("http://{ifaddr}:8080/thing", net.ParseIP("fe80::1")); location != "http://[fe80::1]:8080/thing" {
+		t.Errorf("unexpected location %s", location)
+	}
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19006), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+
+	monitor, err := ssdpClient.Monitor(ssdp.WithBufferedDelivery(8))
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer monitor.Close()
+
+	config := ssdp.AnnounceConfig{
+		NT:       "urn:example-com:service:Thing:1",
+		USN:      "uuid:thing::urn:example-com:service:Thing:1",
+		Location: "http://" + ssdp.LocationIfAddr + ":8080/thing",
+	}
+	if err := ssdpClient.Announce(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case notify := <-monitor.Notifications():
+		ip := net.ParseIP(notify.Location.Hostname())
+		if ip == nil || !ip.IsGlobalUnicast() && !ip.IsPrivate() || notify.Location.Port() != "8080" {
+			t.Errorf("expected the address of an interface, got %s", notify.Location)
+		}
+	case <-time.After(time.Second):
+		t.Skip("multicast loopback not available")
+	}
+}
+
+func Test_MonitorCoalescing(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19009), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+
+	monitor, err := ssdpClient.Monitor(ssdp.WithBufferedDelivery(16), ssdp.WithCoalescing(time.Minute))
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer monitor.Close()
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.ParseIP(monitorGroup), Port: 19009})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	moved := strings.Replace(notifySeed, "192.168.0.21", "192.168.0.22", 1)
+	for _, notify := range []string{notifySeed, notifySeed, notifySeed, moved, moved} {
+		if _, err := conn.Write([]byte(notify)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	stats := monitor.Stats()
+	if stats.Received == 0 {
+		t.Skip("multicast loopback not available")
+	}
+	if stats.Coalesced != stats.Received-2 {
+		t.Errorf("expected all but 2 announcements to be coalesced, got %+v", stats)
+	}
+
+	for _, host := range []string{"192.168.0.21", "192.168.0.22"} {
+		if notify := <-monitor.Notifications(); notify.Location.Hostname() != host {
+			t.Errorf("expected the announcement from %s, got %s", host, notify.Location)
+		}
+	}
+}
+
+func Test_MonitorMaxTracked(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19019), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+
+	monitor, err := ssdpClient.Monitor(ssdp.WithBufferedDelivery(16), ssdp.WithCoalescing(time.Minute), ssdp.WithMonitorMaxTracked(2))
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer monitor.Close()
+
+	// Only the first two of five devices are tracked, the announcements of
+	// the others are delivered again rather than coalesced
+	sendNotifies(t, 19019, 5)
+	sendNotifies(t, 19019, 5)
+	time.Sleep(200 * time.Millisecond)
+
+	stats := monitor.Stats()
+	if stats.Received == 0 {
+		t.Skip("multicast loopback not available")
+	}
+	if stats.Received != 10 || stats.Coalesced != 2 || stats.Overflow != 6 {
+		t.Errorf("expected 2 coalesced and 6 overflowing announcements, got %+v", stats)
+	}
+}
+
+func Test_Diagnose(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19012), ssdp.WithBroadcast(monitorGroup), ssdp.WithTimeout(500))
+
+	interfaces, err := ssdpClient.Diagnose(context.Background())
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+
+	healthy := 0
+	for _, health := range interfaces {
+		if health.Healthy() {
+			healthy++
+			if !health.Joined || !health.Sent || !health.Received || health.IP == nil {
+				t.Errorf("unexpected health %+v", health)
+			}
+			continue
+		}
+		if !strings.HasPrefix(health.Err.Error(), "iface "+health.Interface+": ") {
+			t.Errorf("expected the error to name the interface, got %v", health.Err)
+		}
+		if health.Sent && !health.Received && !errors.Is(health.Err, ssdp.ErrMulticastRX) {
+			t.Errorf("expected ErrMulticastRX, got %v", health.Err)
+		}
+	}
+	if healthy == 0 {
+		t.Skipf("multicast loopback not available: %+v", interfaces)
+	}
+}
+
+func Test_MonitorCheckTraffic(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(19013), ssdp.WithBroadcast(monitorGroup), ssdp.WithIncludeSelf(true))
+
+	monitor, err := ssdpClient.Monitor(ssdp.WithBufferedDelivery(1))
+	if err != nil {
+		t.Skipf("multicast not available: %v", err)
+	}
+	defer monitor.Close()
+
+	err = monitor.CheckTraffic(context.Background())
+	hints := ssdp.HintsOf(err)
+	if err == nil || len(hints) == 0 || hints[0] != ssdp.HintNoTraffic {
+		t.Fatalf("expected the no-traffic hint, got %v %v", err, hints)
+	}
+	for _, hint := range hints {
+		if hint.Guidance() == string(hint) {
+			t.Errorf("expected guidance for %s", hint)
+		}
+	}
+
+	sendNotifies(t, 19013, 1)
+	deadline := time.Now().Add(time.Second)
+	for monitor.Stats().Received == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if monitor.Stats().Received == 0 {
+		t.Skip("multicast loopback not available")
+	}
+	if err := monitor.CheckTraffic(context.Background()); err != nil {
+		t.Errorf("expected no error once packets arrived, got %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package tests
+
+import (
+	"errors"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net/url"
+	"testing"
+)
+
+func Test_NumericAddresses(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithNumericAddresses(true), ssdp.WithBroadcast("ssdp.invalid"))
+
+	if _, err := ssdpClient.Search(ssdp.ALL.String()); !errors.Is(err, ssdp.ErrHostnameLookup) {
+		t.Errorf("expected %v for the multicast address, got %v", ssdp.ErrHostnameLookup, err)
+	}
+
+	location, _ := url.Parse("http://device.invalid:80/description.xml")
+	if _, err := ssdpClient.FetchDescription(location); !errors.Is(err, ssdp.ErrHostnameLookup) {
+		t.Errorf("expected %v for the location, got %v", ssdp.ErrHostnameLookup, err)
+	}
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_ConnPool(t *testing.T) {
+	pool := ssdp.NewConnPool()
+	defer pool.Close()
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(9001), ssdp.WithTimeout(200), ssdp.WithConnPool(pool))
+
+	// Concurrent searches on the same port share the pooled socket instead
+	// of failing to bind it.
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ssdpClient.Search(ssdp.ALL.String())
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	pool.Close()
+	if _, err := ssdpClient.Search(ssdp.ALL.String()); err != ssdp.ErrPoolClosed {
+		t.Errorf("expected %v, got %v", ssdp.ErrPoolClosed, err)
+	}
+}
+
+func Test_ConnPoolDrainsLateResponses(t *testing.T) {
+	const port = 19440
+	pool := ssdp.NewConnPool()
+	defer pool.Close()
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithPort(port), ssdp.WithTimeout(100), ssdp.WithConnPool(pool), ssdp.WithIncludeSelf(true))
+	if _, err := ssdpClient.Search(ssdp.ALL.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A response arriving after the search ended waits on the pooled socket
+	late, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Close()
+	if _, err := late.Write([]byte("HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=1800\r\nST: upnp:rootdevice\r\nUSN: uuid:late::upnp:rootdevice\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	responses, err := ssdpClient.Search(ssdp.ALL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, response := range responses {
+		if response.USN == "uuid:late::upnp:rootdevice" {
+			t.Errorf("expected the late response of the previous search to be discarded, got %+v", response)
+		}
+	}
+}
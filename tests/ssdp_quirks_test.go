@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_Quirks(t *testing.T) {
+	ssdpClient := ssdp.NewSSDP(ssdp.WithQuirks(
+		ssdp.Quirk{Name: "first", Server: "Vendor/1.0", DescriptionPorts: []int{8080}},
+		ssdp.Quirk{Name: "second", ModelName: "Model X", DescriptionPorts: []int{8081}},
+	))
+
+	quirk := ssdpClient.Quirks("Linux UPnP/1.0 Vendor/1.0", "Model X")
+	if quirk.Name != "first,second" || len(quirk.DescriptionPorts) != 2 {
+		t.Errorf("expected both quirks to be combined, got %+v", quirk)
+	}
+
+	quirk = ssdpClient.Quirks("Linux UPnP/1.0 Other/1.0", "")
+	if quirk.Name != "" {
+		t.Errorf("expected no quirks, got %+v", quirk)
+	}
+
+	quirk = ssdp.NewSSDP().Quirks("Unspecified, UPnP/1.0, Unspecified", "")
+	if quirk.Name != "wemo" {
+		t.Errorf("expected default wemo quirk, got %+v", quirk)
+	}
+}
+
+func Test_QuirksByModelName(t *testing.T) {
+	var closed []bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		closed = append(closed, r.Close)
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+	}))
+	defer server.Close()
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	ssdpClient := ssdp.NewSSDP(ssdp.WithQuirks(ssdp.Quirk{Name: "bridge", ModelName: "hue bridge", NoKeepAlive: true}))
+
+	for i := 0; i < 2; i++ {
+		if _, err := ssdpClient.FetchDescription(location); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(closed) != 2 || closed[0] || !closed[1] {
+		t.Errorf("expected the quirk to be learned from the model name, got %v", closed)
+	}
+}
+
+func Test_ParseDescriptionWindows1252(t *testing.T) {
+	description := "<?xml version=\"1.0\" encoding=\"windows-1252\"?>\n" +
+		"<root xmlns=\"urn:schemas-upnp-org:device-1-0\"><device>" +
+		"<friendlyName>Caf\xe9 \x80 \x96 Speaker</friendlyName>" +
+		"<UDN>uuid:1</UDN></device></root>"
+
+	device, err := ssdp.ParseDescription(strings.NewReader(description))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.FriendlyName != "Café € – Speaker" {
+		t.Errorf("expected windows-1252 to be decoded, got %q", device.FriendlyName)
+	}
+}
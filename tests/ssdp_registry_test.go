@@ -0,0 +1,278 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func registryResponse(usn string, st string, ip string) ssdp.SearchResponse {
+	location, _ := url.Parse("http://" + ip + ":1400/xml/device_description.xml")
+	return ssdp.SearchResponse{
+		Control:      "max-age=1800",
+		ST:           st,
+		USN:          usn,
+		Location:     location,
+		ResponseAddr: &net.UDPAddr{IP: net.ParseIP(ip), Port: 1900},
+	}
+}
+
+func Test_RegistryQueries(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	registry := ssdp.NewRegistry(ssdp.WithRegistryClock(clock))
+
+	registry.AddResponse(registryResponse("uuid:renderer::urn:schemas-upnp-org:device:MediaRenderer:1", "urn:schemas-upnp-org:device:MediaRenderer:1", "192.168.1.20"))
+	registry.AddResponse(registryResponse("uuid:renderer::urn:schemas-upnp-org:service:AVTransport:1", "urn:schemas-upnp-org:service:AVTransport:1", "192.168.1.20"))
+	registry.AddResponse(registryResponse("uuid:server::upnp:rootdevice", "upnp:rootdevice", "192.168.1.30"))
+	registry.SetDescription(ssdp.Device{
+		UDN:          "uuid:server",
+		DeviceType:   "urn:schemas-upnp-org:device:MediaServer:1",
+		Manufacturer: "Justin Maggard",
+		Services:     []ssdp.Service{{ServiceType: "urn:schemas-upnp-org:service:ContentDirectory:1"}},
+	})
+
+	if registry.Len() != 2 {
+		t.Fatalf("expected 2 devices, got %d", registry.Len())
+	}
+
+	if entry, ok := registry.ByUDN("uuid:renderer"); !ok || entry.Location.Host != "192.168.1.20:1400" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+
+	queries := []struct {
+		name    string
+		entries []ssdp.RegistryEntry
+		udn     string
+	}{
+		{"device type", registry.ByDeviceType("urn:schemas-upnp-org:device:MediaRenderer:1"), "uuid:renderer"},
+		{"described device type", registry.ByDeviceType("urn:schemas-upnp-org:device:MediaServer:1"), "uuid:server"},
+		{"service type", registry.ByServiceType("urn:schemas-upnp-org:service:AVTransport:1"), "uuid:renderer"},
+		{"described service type", registry.ByServiceType("urn:schemas-upnp-org:service:ContentDirectory:1"), "uuid:server"},
+		{"manufacturer", registry.ByManufacturer("justin maggard"), "uuid:server"},
+		{"address", registry.ByAddress(net.ParseIP("192.168.1.30")), "uuid:server"},
+	}
+
+	for _, query := range queries {
+		if len(query.entries) != 1 || query.entries[0].UDN != query.udn {
+			t.Errorf("%s: unexpected entries %+v", query.name, query.entries)
+		}
+	}
+
+	registry.AddNotify(ssdp.Notify{NTS: ssdp.NTSByeBye, USN: "uuid:renderer::upnp:rootdevice"})
+
+	if len(registry.ByAddress(net.ParseIP("192.168.1.20"))) != 0 || len(registry.ByDeviceType("urn:schemas-upnp-org:device:MediaRenderer:1")) != 0 {
+		t.Error("byebye left the device in the indexes")
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	if expired := registry.Expire(); len(expired) != 1 || expired[0] != "uuid:server" {
+		t.Errorf("unexpected expired devices %v", expired)
+	}
+	if len(registry.Snapshot()) != 0 {
+		t.Error("expected an empty registry")
+	}
+}
+
+func Test_RegistryHysteresis(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	registry := ssdp.NewRegistry(ssdp.WithRegistryClock(clock), ssdp.WithGracePeriod(5*time.Minute), ssdp.WithMissedRounds(2))
+
+	registry.AddResponse(registryResponse("uuid:speaker::upnp:rootdevice", "upnp:rootdevice", "192.168.1.40"))
+
+	// Within the grace period after the max-age of 30 minutes.
+	clock.now = clock.now.Add(32 * time.Minute)
+	if expired := registry.AddRound(nil); len(expired) != 0 {
+		t.Errorf("removed %v within the grace period", expired)
+	}
+
+	// Past the grace period, but only one round has been missed so far.
+	clock.now = clock.now.Add(5 * time.Minute)
+	if expired := registry.Expire(); len(expired) != 0 {
+		t.Errorf("removed %v after a single missed round", expired)
+	}
+
+	if expired := registry.AddRound(nil); len(expired) != 1 || expired[0] != "uuid:speaker" {
+		t.Errorf("unexpected removed devices %v", expired)
+	}
+}
+
+func Test_RegistryNLSChangeFlushesDescription(t *testing.T) {
+	registry := ssdp.NewRegistry()
+
+	response := registryResponse("uuid:plug::upnp:rootdevice", "upnp:rootdevice", "192.168.1.50")
+	response.NLS = "first-network"
+	registry.AddResponse(response)
+	registry.SetDescription(ssdp.Device{UDN: "uuid:plug", Manufacturer: "Belkin International Inc."})
+
+	registry.AddResponse(response)
+	if entry, _ := registry.ByUDN("uuid:plug"); entry.Device == nil {
+		t.Fatal("description flushed although the NLS is unchanged")
+	}
+
+	response.NLS = "second-network"
+	registry.AddResponse(response)
+	if entry, _ := registry.ByUDN("uuid:plug"); entry.Device != nil || entry.NLS != "second-network" {
+		t.Errorf("description kept after the NLS changed: %+v", entry)
+	}
+	if len(registry.ByManufacturer("Belkin International Inc.")) != 0 {
+		t.Error("flushed description still indexed by manufacturer")
+	}
+}
+
+func Test_BuildSearchResponseNLS(t *testing.T) {
+	built := ssdp.BuildSearchResponse(ssdp.SearchResponseConfig{
+		ST:       "upnp:rootdevice",
+		USN:      "uuid:plug::upnp:rootdevice",
+		Location: "http://192.168.1.50:49153/setup.xml",
+		NLS:      "b9200ebb-736d-4b93-bf03-835149d13983",
+	})
+	response, err := ssdp.ParseSearchResponse(strings.NewReader(string(built)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.NLS != "b9200ebb-736d-4b93-bf03-835149d13983" {
+		t.Errorf("expected the NLS to round trip, got %q in\n%s", response.NLS, built)
+	}
+}
+
+func Test_RegistryMaxTracked(t *testing.T) {
+	registry := ssdp.NewRegistry(ssdp.WithMaxTracked(1))
+
+	registry.AddResponse(registryResponse("uuid:first::upnp:rootdevice", "upnp:rootdevice", "192.168.1.60"))
+	registry.AddResponse(registryResponse("uuid:second::upnp:rootdevice", "upnp:rootdevice", "192.168.1.61"))
+	registry.SetDescription(ssdp.Device{UDN: "uuid:third"})
+	registry.SetDescription(ssdp.Device{UDN: "uuid:first", Manufacturer: "Signify"})
+
+	if registry.Len() != 1 || registry.Overflow() != 2 {
+		t.Errorf("expected 1 device and 2 overflows, got %d and %d", registry.Len(), registry.Overflow())
+	}
+	if len(registry.ByManufacturer("Signify")) != 1 {
+		t.Error("known device not updated while full")
+	}
+}
+
+func Test_RegistryDeviceKey(t *testing.T) {
+	registry := ssdp.NewRegistry(ssdp.WithDeviceKey(ssdp.HostKey))
+
+	// A bridge making up a new UUID on every boot
+	registry.AddResponse(registryResponse("uuid:boot-1::upnp:rootdevice", "upnp:rootdevice", "192.168.1.70"))
+	registry.AddResponse(registryResponse("uuid:boot-2::urn:schemas-upnp-org:device:Basic:1", "urn:schemas-upnp-org:device:Basic:1", "192.168.1.70"))
+
+	if registry.Len() != 1 {
+		t.Fatalf("expected a single device, got %d", registry.Len())
+	}
+	entry, ok := registry.ByUDN("uuid:boot-2")
+	if !ok || entry.Key != "192.168.1.70" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if len(registry.ByDeviceType("urn:schemas-upnp-org:device:Basic:1")) != 1 {
+		t.Error("device not indexed under its key")
+	}
+
+	registry.Remove("192.168.1.70")
+	if registry.Len() != 0 {
+		t.Error("device not removed by its key")
+	}
+}
+
+func Test_RegistryDeviceKeyByeBye(t *testing.T) {
+	registry := ssdp.NewRegistry(ssdp.WithDeviceKey(ssdp.HostKey))
+
+	location := registryResponse("", "", "192.168.1.71").Location
+	registry.AddNotify(ssdp.Notify{NT: "upnp:rootdevice", NTS: ssdp.NTSAlive, USN: "uuid:boot-3::upnp:rootdevice", Control: "max-age=1800", Location: location})
+	if registry.Len() != 1 {
+		t.Fatalf("expected a single device, got %d", registry.Len())
+	}
+
+	// The byebye has no location to derive the host from
+	registry.AddNotify(ssdp.Notify{NT: "upnp:rootdevice", NTS: ssdp.NTSByeBye, USN: "uuid:boot-3::upnp:rootdevice"})
+	if registry.Len() != 0 {
+		t.Errorf("expected the byebye to remove the device tracked by its host, got %d devices", registry.Len())
+	}
+}
+
+func Test_RegistryMAC(t *testing.T) {
+	registry := ssdp.NewRegistry()
+
+	response := registryResponse("uuid:tv::upnp:rootdevice", "upnp:rootdevice", "192.168.1.80")
+	response.MAC, _ = net.ParseMAC("a4:30:7a:01:02:03")
+	registry.AddResponse(response)
+	registry.AddNotify(ssdp.Notify{NT: "upnp:rootdevice", NTS: ssdp.NTSAlive, USN: "uuid:tv::upnp:rootdevice", Control: "max-age=1800"})
+
+	if entry, _ := registry.ByUDN("uuid:tv"); entry.MAC.String() != "a4:30:7a:01:02:03" {
+		t.Errorf("unexpected MAC %v", entry.MAC)
+	}
+}
+
+func Test_RegistrySearchPort(t *testing.T) {
+	raw := "HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=1800\r\nST: upnp:rootdevice\r\nUSN: uuid:nas::upnp:rootdevice\r\nSEARCHPORT.UPNP.ORG: 49200\r\n\r\n"
+	response, err := ssdp.ParseSearchResponse(strings.NewReader(raw), &net.UDPAddr{IP: net.ParseIP("192.168.1.90"), Port: 38012})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := response.SearchAddr(); addr.String() != "192.168.1.90:49200" {
+		t.Errorf("unexpected search address %v", addr)
+	}
+
+	registry := ssdp.NewRegistry()
+	registry.AddResponse(*response)
+	if entry, _ := registry.ByUDN("uuid:nas"); entry.SearchAddr().String() != "192.168.1.90:49200" {
+		t.Errorf("unexpected search address %v", entry.SearchAddr())
+	}
+
+	// Not a port UDA 1.1 allows
+	raw = strings.Replace(raw, "49200", "80", 1)
+	response, _ = ssdp.ParseSearchResponse(strings.NewReader(raw), &net.UDPAddr{IP: net.ParseIP("192.168.1.90"), Port: 38012})
+	if addr := response.SearchAddr(); addr.Port != ssdp.DefaultSearchPort {
+		t.Errorf("expected the default port, got %v", addr)
+	}
+}
+
+func Test_RegistryReboot(t *testing.T) {
+	bus := ssdp.NewEventBus(0)
+	registry := ssdp.NewRegistry(ssdp.WithRegistryEvents(bus))
+
+	raw := "NOTIFY * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nCACHE-CONTROL: max-age=1800\r\nNT: upnp:rootdevice\r\nNTS: ssdp:alive\r\n" +
+		"USN: uuid:tv::upnp:rootdevice\r\nBOOTID.UPNP.ORG: 7\r\n\r\n"
+	notify, err := ssdp.ParseNotify(strings.NewReader(raw), &net.UDPAddr{IP: net.ParseIP("192.168.1.80"), Port: 1900})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notify.BootID != 7 {
+		t.Fatalf("unexpected boot ID %d", notify.BootID)
+	}
+
+	registry.AddNotify(*notify)
+	registry.SetDescription(ssdp.Device{UDN: "uuid:tv", DeviceType: "urn:schemas-upnp-org:device:MediaRenderer:1"})
+	registry.AddNotify(*notify)
+
+	// An update announces the next boot ID without rebooting
+	update := *notify
+	update.NTS, update.NextBootID = ssdp.NTSUpdate, 8
+	registry.AddNotify(update)
+	notify.BootID = 8
+	registry.AddNotify(*notify)
+	if entry, _ := registry.ByUDN("uuid:tv"); entry.BootID != 8 || entry.Device == nil {
+		t.Fatalf("unexpected entry after an update %+v", entry)
+	}
+
+	notify.BootID = 9
+	registry.AddNotify(*notify)
+	if entry, _ := registry.ByUDN("uuid:tv"); entry.BootID != 9 || entry.Device != nil {
+		t.Errorf("expected the description of the rebooted device to be dropped, got %+v", entry)
+	}
+
+	bus.Close()
+	var rebooted []string
+	for event := range bus.Events() {
+		if event.Type == ssdp.EventDeviceRebooted {
+			rebooted = append(rebooted, event.Device.UDN)
+		}
+	}
+	if len(rebooted) != 1 || rebooted[0] != "uuid:tv" {
+		t.Errorf("expected a single reboot, got %v", rebooted)
+	}
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"bytes"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_BuildSearchResponse(t *testing.T) {
+	for _, version := range []ssdp.UDAVersion{ssdp.UDA10, ssdp.UDA11, ssdp.UDA20} {
+		raw := ssdp.BuildSearchResponse(ssdp.SearchResponseConfig{
+			ST:       "upnp:rootdevice",
+			USN:      "uuid:4d696e69-444c-164e-9d41-b827eb54e939::upnp:rootdevice",
+			Location: "http://192.168.1.30:8200/rootDesc.xml",
+			MaxAge:   time.Hour,
+			Server:   "Linux/5.10 UPnP/" + version.String() + " MiniDLNA/1.3",
+			Date:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			BootID:   7,
+			ConfigID: 1,
+			Version:  version,
+		})
+
+		findings, err := ssdp.ValidateSearchResponse(bytes.NewReader(raw), version)
+		if err != nil || len(findings) != 0 {
+			t.Errorf("UDA %s: expected a valid response, got %v, %v", version, findings, err)
+		}
+
+		response, err := ssdp.ParseSearchResponse(bytes.NewReader(raw), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.MaxAge() != time.Hour || response.Location.Host != "192.168.1.30:8200" || !response.Date.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("UDA %s: unexpected response %+v", version, response)
+		}
+	}
+}
+
+func Test_SearchResponseStatusLine(t *testing.T) {
+	raw := "HTTP/1.0 200 Okay\r\nCACHE-CONTROL: max-age=1800\r\nEXT:\r\nLOCATION: http://192.168.1.30:8200/rootDesc.xml\r\n" +
+		"SERVER: Linux/2.6 UPnP/1.0 NAS/1.0\r\nST: upnp:rootdevice\r\nUSN: uuid:nas::upnp:rootdevice\r\n\r\n"
+
+	response, err := ssdp.ParseSearchResponse(strings.NewReader(raw), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Proto != "HTTP/1.0" || response.StatusLine != "HTTP/1.0 200 Okay" {
+		t.Errorf("unexpected protocol %q and status line %q", response.Proto, response.StatusLine)
+	}
+
+	findings, err := ssdp.ValidateSearchResponse(strings.NewReader(raw), ssdp.UDA10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 || findings[0].Header != "status" || findings[1].Header != "status" {
+		t.Errorf("expected findings for the reason phrase and version, got %v", findings)
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"errors"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_FetchDescriptionRetry(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail like a device that just booted
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeFile(w, r, "../example/responses/hue_description.xml")
+	}))
+	defer server.Close()
+
+	location, _ := url.Parse(server.URL + "/description.xml")
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithClock(clock), ssdp.WithFetchRetry(ssdp.RetryPolicy{
+		Attempts: 3,
+		Backoff:  time.Second,
+	}))
+
+	device, err := ssdpClient.FetchDescription(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if device.FriendlyName != "Philips hue (192.168.0.21)" {
+		t.Errorf("unexpected device %v", device)
+	}
+
+	// Backoff of 1s and 2s
+	if waited := clock.Now().Sub(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)); waited != 3*time.Second {
+		t.Errorf("expected to back off for 3s, got %v", waited)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	_, err = ssdp.NewSSDP(ssdp.WithFetchRetry(ssdp.RetryPolicy{Attempts: 2})).FetchDescription(location)
+
+	var fetchErr *ssdp.FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.Attempts != 2 {
+		t.Errorf("expected a fetch error after 2 attempts, got %v", err)
+	}
+}
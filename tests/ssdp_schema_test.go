@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_ValidateDescription(t *testing.T) {
+	file, err := os.Open("../example/responses/hue_description.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	device, err := ssdp.ParseDescription(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The bridge lists a placeholder service
+	findings := ssdp.ValidateDescription(device)
+	if len(findings) != 2 || findings[0].String() != `error: service (null): not a service type URN: "(null)"` {
+		t.Errorf("unexpected findings for the hue bridge: %v", findings)
+	}
+
+	device.Services = nil
+	device.UDN = "4d696e69-444c-164e-9d41-b827eb54e939"
+	device.Icons = []ssdp.Icon{{MIMEType: "image/png", URL: "/icon.png"}}
+	device.Devices = []ssdp.EmbeddedDevice{{
+		DeviceType:   "urn:schemas-upnp-org:device:Basic:1",
+		FriendlyName: "Embedded",
+		Manufacturer: "Example",
+		UDN:          "uuid:embedded",
+		Services:     []ssdp.Service{{ServiceType: "urn:schemas-upnp-org:service:Dimming", ServiceID: "urn:upnp-org:serviceId:Dimming", SCPDURL: "/dim.xml", ControlURL: "/dim"}},
+	}}
+
+	var lines []string
+	for _, finding := range ssdp.ValidateDescription(device) {
+		lines = append(lines, finding.String())
+	}
+
+	expected := []string{
+		`error: UDN: not a uuid: UDN: "4d696e69-444c-164e-9d41-b827eb54e939"`,
+		"error: device 1 modelName: missing",
+		`error: device 1 service urn:upnp-org:serviceId:Dimming: not a service type URN: "urn:schemas-upnp-org:service:Dimming"`,
+		"error: icon 1: missing or invalid size 0x0",
+		"error: icon 1: missing or invalid depth 0",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected findings:\n%s", strings.Join(lines, "\n"))
+	}
+}
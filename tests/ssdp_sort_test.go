@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_SortResponses(t *testing.T) {
+	responses := []ssdp.SearchResponse{
+		{USN: "c", RTT: 30 * time.Millisecond, ResponseAddr: &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 1900}},
+		{USN: "a", RTT: 10 * time.Millisecond, ResponseAddr: &net.UDPAddr{IP: net.ParseIP("192.168.1.9"), Port: 1900}},
+		{USN: "b", RTT: 20 * time.Millisecond},
+	}
+
+	ssdp.SortResponsesByRTT(responses)
+	if usns := responses[0].USN + responses[1].USN + responses[2].USN; usns != "abc" {
+		t.Errorf("expected order abc by rtt, got %s", usns)
+	}
+
+	ssdp.SortResponsesByAddress(responses)
+	if usns := responses[0].USN + responses[1].USN + responses[2].USN; usns != "acb" {
+		t.Errorf("expected order acb by address, got %s", usns)
+	}
+}
+
+func Test_SortDevices(t *testing.T) {
+	location := func(raw string) *url.URL {
+		u, _ := url.Parse(raw)
+		return u
+	}
+
+	devices := []ssdp.Device{
+		{FriendlyName: "kitchen", DeviceType: "urn:schemas-upnp-org:device:ZonePlayer:1", Location: location("http://192.168.1.10:1400/xml")},
+		{FriendlyName: "Bridge", DeviceType: "urn:schemas-upnp-org:device:Basic:1", Location: location("http://192.168.1.9:80/description.xml")},
+		{FriendlyName: "Router", DeviceType: "urn:schemas-upnp-org:device:InternetGatewayDevice:1", Location: location("http://192.168.1.9:49000/igd.xml")},
+	}
+
+	ssdp.SortDevicesByAddress(devices)
+	if names := devices[0].FriendlyName + devices[1].FriendlyName + devices[2].FriendlyName; names != "BridgeRouterkitchen" {
+		t.Errorf("unexpected order by address: %s", names)
+	}
+
+	ssdp.SortDevicesByFriendlyName(devices)
+	if names := devices[0].FriendlyName + devices[1].FriendlyName + devices[2].FriendlyName; names != "BridgekitchenRouter" {
+		t.Errorf("unexpected order by friendly name: %s", names)
+	}
+
+	ssdp.SortDevicesByDeviceType(devices)
+	if names := devices[0].FriendlyName + devices[1].FriendlyName + devices[2].FriendlyName; names != "BridgeRouterkitchen" {
+		t.Errorf("unexpected order by device type: %s", names)
+	}
+}
+
+func Test_GroupResponses(t *testing.T) {
+	location := "http://192.168.1.10:49000/igd.xml"
+	response := func(usn string, st string, location string) ssdp.SearchResponse {
+		u, _ := url.Parse(location)
+		return ssdp.SearchResponse{USN: usn, ST: st, Location: u}
+	}
+
+	trees := ssdp.GroupResponses([]ssdp.SearchResponse{
+		response("uuid:wan::urn:schemas-upnp-org:device:WANDevice:1", "urn:schemas-upnp-org:device:WANDevice:1", location),
+		response("uuid:igd::upnp:rootdevice", "upnp:rootdevice", location),
+		response("uuid:igd::urn:schemas-upnp-org:device:InternetGatewayDevice:1", "urn:schemas-upnp-org:device:InternetGatewayDevice:1", location),
+		response("uuid:wan::urn:schemas-upnp-org:service:WANCommonInterfaceConfig:1", "urn:schemas-upnp-org:service:WANCommonInterfaceConfig:1", location),
+		response("uuid:igd::urn:schemas-upnp-org:service:Layer3Forwarding:1", "urn:schemas-upnp-org:service:Layer3Forwarding:1", location),
+		response("uuid:hue::upnp:rootdevice", "upnp:rootdevice", "http://192.168.1.5:80/description.xml"),
+		{USN: "uuid:nowhere::upnp:rootdevice", ST: "upnp:rootdevice"},
+	})
+
+	if len(trees) != 2 {
+		t.Fatalf("expected 2 root devices, got %+v", trees)
+	}
+
+	if trees[0].UDN != "uuid:hue" || len(trees[0].Embedded) != 0 {
+		t.Errorf("unexpected first root %+v", trees[0])
+	}
+
+	igd := trees[1]
+	if igd.UDN != "uuid:igd" || fmt.Sprint(igd.DeviceTypes, igd.ServiceTypes) != "[urn:schemas-upnp-org:device:InternetGatewayDevice:1] [urn:schemas-upnp-org:service:Layer3Forwarding:1]" {
+		t.Errorf("unexpected root %+v", igd.DeviceNode)
+	}
+
+	if len(igd.Embedded) != 1 || igd.Embedded[0].UDN != "uuid:wan" || fmt.Sprint(igd.Embedded[0].ServiceTypes) != "[urn:schemas-upnp-org:service:WANCommonInterfaceConfig:1]" {
+		t.Errorf("unexpected embedded devices %+v", igd.Embedded)
+	}
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_SearchResponseString(t *testing.T) {
+	location, _ := url.Parse("http://192.168.1.2:80/description.xml")
+
+	response := ssdp.SearchResponse{
+		ST:           "upnp:rootdevice",
+		USN:          "uuid:2f402f80-da50-11e1-9b23-001788255acc::upnp:rootdevice",
+		Location:     location,
+		ResponseAddr: &net.UDPAddr{IP: net.ParseIP("192.168.1.2"), Port: 1900},
+	}
+
+	expected := "uuid:2f402f80-da50-11e1-9b23-001788255acc::upnp:rootdevice (upnp:rootdevice) " +
+		"from 192.168.1.2:1900 at http://192.168.1.2:80/description.xml"
+
+	if response.String() != expected {
+		t.Errorf("expected %q, got %q", expected, response.String())
+	}
+
+	if !strings.Contains(response.Describe(), "Location:") {
+		t.Errorf("expected location in description, got %q", response.Describe())
+	}
+}
+
+func Test_DeviceString(t *testing.T) {
+	device := ssdp.Device{
+		FriendlyName: "Philips hue",
+		Manufacturer: "Royal Philips Electronics",
+		ModelName:    "Philips hue bridge 2015",
+		UDN:          "uuid:2f402f80-da50-11e1-9b23-001788255acc",
+	}
+
+	expected := "Philips hue (Royal Philips Electronics Philips hue bridge 2015) " +
+		"uuid:2f402f80-da50-11e1-9b23-001788255acc at -"
+
+	if device.String() != expected {
+		t.Errorf("expected %q, got %q", expected, device.String())
+	}
+
+	if strings.Contains(device.Describe(), "Serial number") {
+		t.Errorf("expected empty fields to be omitted, got %q", device.Describe())
+	}
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_MatchesST(t *testing.T) {
+	tests := []struct {
+		response string
+		search   string
+		matches  bool
+	}{
+		{"urn:schemas-upnp-org:device:MediaServer:1", "ssdp:all", true},
+		{"upnp:rootdevice", "upnp:rootdevice", true},
+		{"uuid:4d696e69-444c-164e-9d41-b827eb54e939", "uuid:4d696e69-444c-164e-9d41-b827eb54e939", true},
+		{"uuid:4d696e69-444c-164e-9d41-b827eb54e939", "uuid:other", false},
+		{"urn:schemas-upnp-org:device:MediaServer:2", "urn:schemas-upnp-org:device:MediaServer:1", true},
+		{"urn:schemas-upnp-org:device:MediaServer:1", "urn:schemas-upnp-org:device:MediaServer:2", false},
+		{"urn:schemas-upnp-org:service:ContentDirectory:4", "urn:schemas-upnp-org:service:ContentDirectory:1", true},
+		{"urn:schemas-upnp-org:device:MediaRenderer:2", "urn:schemas-upnp-org:device:MediaServer:1", false},
+		{"urn:schemas-upnp-org:service:MediaServer:2", "urn:schemas-upnp-org:device:MediaServer:1", false},
+		{"urn:schemas-upnp-org:device:MediaServer:x", "urn:schemas-upnp-org:device:MediaServer:1", false},
+	}
+
+	for _, test := range tests {
+		if got := ssdp.MatchesST(test.response, test.search); got != test.matches {
+			t.Errorf("MatchesST(%q, %q) = %v, expected %v", test.response, test.search, got, test.matches)
+		}
+	}
+}
+
+func Test_RegistryByTarget(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	registry := ssdp.NewRegistry(ssdp.WithRegistryClock(clock))
+
+	registry.AddResponse(registryResponse("uuid:v1::urn:schemas-upnp-org:device:MediaServer:1", "urn:schemas-upnp-org:device:MediaServer:1", "192.168.1.20"))
+	registry.AddResponse(registryResponse("uuid:v2::urn:schemas-upnp-org:device:MediaServer:2", "urn:schemas-upnp-org:device:MediaServer:2", "192.168.1.30"))
+
+	if entries := registry.ByTarget("urn:schemas-upnp-org:device:MediaServer:1"); len(entries) != 2 {
+		t.Errorf("expected both versions to answer a search for version 1, got %v", entries)
+	}
+	if entries := registry.ByTarget("urn:schemas-upnp-org:device:MediaServer:2"); len(entries) != 1 || entries[0].UDN != "uuid:v2" {
+		t.Errorf("expected only version 2 to answer a search for version 2, got %v", entries)
+	}
+	if entries := registry.ByTarget("uuid:v1"); len(entries) != 1 {
+		t.Errorf("expected the UDN to match, got %v", entries)
+	}
+}
+
+func Test_DeviceFindService(t *testing.T) {
+	location, _ := url.Parse("http://192.168.1.30:8200/rootDesc.xml")
+	device := ssdp.Device{
+		UDN:      "uuid:server",
+		Location: location,
+		Services: []ssdp.Service{
+			{ServiceType: "urn:schemas-upnp-org:service:ContentDirectory:1", ControlURL: "/ctl/v1"},
+		},
+		Devices: []ssdp.EmbeddedDevice{{
+			Services: []ssdp.Service{
+				{ServiceType: "urn:schemas-upnp-org:service:ContentDirectory:3", ControlURL: "/ctl/v3", SCPDURL: "/scpd/v3.xml"},
+				{ServiceType: "urn:schemas-upnp-org:service:ConnectionManager:1", ControlURL: "/ctl/cm"},
+			},
+		}},
+	}
+
+	endpoint, err := device.FindService("schemas-upnp-org", "ContentDirectory", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.Version != 3 || endpoint.ControlURL.String() != "http://192.168.1.30:8200/ctl/v3" || endpoint.SCPDURL.Path != "/scpd/v3.xml" || endpoint.EventSubURL != nil {
+		t.Errorf("expected the resolved version 3 service, got %+v", endpoint)
+	}
+
+	if endpoint, err := device.FindService("schemas-upnp-org", "ContentDirectory", 1); err != nil || endpoint.Version != 3 {
+		t.Errorf("expected the highest version for a version 1 request, got %+v, %v", endpoint, err)
+	}
+	if _, err := device.FindService("schemas-upnp-org", "ConnectionManager", 2); err == nil {
+		t.Error("expected an error for a version higher than offered")
+	}
+}
@@ -1,7 +1,9 @@
 package tests
 
 import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
 	"testing"
+	"time"
 )
 
 func Test_SsdpDevices(t *testing.T) {
@@ -31,3 +33,27 @@ func Test_SsdpResponses(t *testing.T) {
 		t.Logf("Response: %v", responses[i])
 	}
 }
+
+func Test_SsdpProgress(t *testing.T) {
+	var last ssdp.Progress
+	calls := 0
+
+	ssdpClient := ssdp.NewSSDP(ssdp.WithTimeout(600), ssdp.WithProgress(func(progress ssdp.Progress) {
+		last = progress
+		calls++
+	}))
+
+	_, err := ssdpClient.Search(ssdp.ALL.String())
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if calls < 2 {
+		t.Errorf("expected periodic progress reports, got %d", calls)
+	}
+
+	if last.Elapsed < 600*time.Millisecond {
+		t.Errorf("expected final report after the search window, got %v", last.Elapsed)
+	}
+}
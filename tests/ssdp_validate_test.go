@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"testing"
+)
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []ssdp.OptionSSDP
+		valid bool
+	}{
+		{"valid", []ssdp.OptionSSDP{ssdp.WithTimeout(2000)}, true},
+		{"port out of range", []ssdp.OptionSSDP{ssdp.WithTimeout(2000), ssdp.WithPort(70000)}, false},
+		{"unparseable ip", []ssdp.OptionSSDP{ssdp.WithTimeout(2000), ssdp.WithBroadcast("ssdp.local")}, false},
+		{"unicast ip", []ssdp.OptionSSDP{ssdp.WithTimeout(2000), ssdp.WithBroadcast("192.168.1.1")}, false},
+		{"further groups", []ssdp.OptionSSDP{ssdp.WithTimeout(2000), ssdp.WithGroups("239.192.0.99")}, true},
+		{"unicast further group", []ssdp.OptionSSDP{ssdp.WithTimeout(2000), ssdp.WithGroups("192.168.1.1")}, false},
+		{"defaults", nil, true},
+		{"zero timeout", []ssdp.OptionSSDP{ssdp.WithTimeout(0)}, false},
+		{"mx too small", []ssdp.OptionSSDP{ssdp.WithTimeout(500)}, false},
+		{"mx too large", []ssdp.OptionSSDP{ssdp.WithTimeout(10000)}, false},
+	}
+
+	for _, test := range tests {
+		_, err := ssdp.NewValidatedSSDP(test.opts...)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_Redactors(t *testing.T) {
+	wire := "USN: uuid:4D696E69-444C-164E-9D41-B827EB54E939::upnp:rootdevice\r\n" +
+		"X-RINCON-HOUSEHOLD: RINCON_000E58A1B2C301400\r\n" +
+		"<serialNumber>SN-1234</serialNumber><macAddress>b8:27:eb:54:e9:39</macAddress>"
+
+	redacted := ssdp.RedactMACs(ssdp.RedactSerials(ssdp.RedactUUIDs(wire)))
+	expected := "USN: uuid:<uuid>::upnp:rootdevice\r\n" +
+		"X-RINCON-HOUSEHOLD: RINCON_<serial>\r\n" +
+		"<serialNumber><serial></serialNumber><macAddress><mac></macAddress>"
+	if redacted != expected {
+		t.Errorf("expected %q, got %q", expected, redacted)
+	}
+}
+
+func Test_WireLog(t *testing.T) {
+	const port = 19430
+
+	loopback := loopbackInterface(t)
+	defer respondOn(t, "127.0.0.2", port)()
+
+	var mu sync.Mutex
+	wires := make(map[string]string)
+	logger := ssdp.LoggerFunc(func(msg string, keysAndValues ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		for i := 0; i+1 < len(keysAndValues); i += 2 {
+			if keysAndValues[i] == "wire" {
+				wires[msg] = keysAndValues[i+1].(string)
+			}
+		}
+	})
+
+	ssdpClient := ssdp.NewSSDP(
+		ssdp.WithPort(port),
+		ssdp.WithInterface(loopback.Name),
+		ssdp.WithBroadcast("127.0.0.2"),
+		ssdp.WithIncludeSelf(true),
+		ssdp.WithTimeout(100),
+		ssdp.WithLogger(logger),
+		ssdp.WithWireLog(func(wire string) string {
+			return strings.ReplaceAll(wire, "127.0.0.2", "<ip>")
+		}),
+	)
+
+	if _, err := ssdpClient.Search("upnp:rootdevice"); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.HasPrefix(wires["search wire"], "M-SEARCH ") {
+		t.Errorf("unexpected search wire %q", wires["search wire"])
+	}
+	if !strings.Contains(wires["packet received"], "USN: <ip>") {
+		t.Errorf("unexpected response wire %q", wires["packet received"])
+	}
+}
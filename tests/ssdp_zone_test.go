@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"github.com/Oleaintueri/gossdp/pkg/ssdp"
+	"net"
+	"strings"
+	"testing"
+)
+
+func Test_ParseSearchResponseZone(t *testing.T) {
+	addr := &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 1900, Zone: "eth0"}
+
+	tests := []struct {
+		location string
+		host     string
+		url      string
+	}{
+		{"http://[fe80::1]:8080/description.xml", "fe80::1%eth0", "http://[fe80::1%25eth0]:8080/description.xml"},
+		{"http://[fe80::1%25eth1]:8080/description.xml", "fe80::1%eth1", "http://[fe80::1%25eth1]:8080/description.xml"},
+		{"http://[2001:db8::1]:8080/description.xml", "2001:db8::1", "http://[2001:db8::1]:8080/description.xml"},
+		{"http://192.168.1.20:8080/description.xml", "192.168.1.20", "http://192.168.1.20:8080/description.xml"},
+	}
+
+	for _, test := range tests {
+		message := "HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\nLOCATION: " + test.location + "\r\n\r\n"
+		response, err := ssdp.ParseSearchResponse(strings.NewReader(message), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Location.Hostname() != test.host || response.Location.String() != test.url {
+			t.Errorf("%s: got host %s and url %s", test.location, response.Location.Hostname(), response.Location)
+		}
+	}
+}